@@ -0,0 +1,64 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerGetAudit(writer http.ResponseWriter, request *http.Request) {
+	filter, err := parseAuditFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.GetAuditEntries(request.Context(), filter)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+// parseAuditFilter reads manager_id, entity, entity_id, from, to (RFC 3339
+// times), limit and offset.
+func parseAuditFilter(query url.Values) (*managers.AuditFilter, error) {
+	filter := &managers.AuditFilter{Entity: query.Get("entity"), Limit: listing.DefaultLimit}
+	var err error
+	for name, dst := range map[string]*int64{"manager_id": &filter.ManagerID, "entity_id": &filter.EntityID} {
+		if value := query.Get(name); value != "" {
+			*dst, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, err
+			}
+			*dst = &t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit < 1 || filter.Limit > listing.MaxLimit {
+			return nil, listing.ErrInvalid
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		filter.Offset, err = strconv.Atoi(value)
+		if err != nil || filter.Offset < 0 {
+			return nil, listing.ErrInvalid
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// CartChange is the body of the requests adding or updating a cart item.
+type CartChange struct {
+	Product_id int64 `json:"product_id"`
+	Qty        int   `json:"qty"`
+}
+
+func (s *Server) handleCustomerGetCart(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	cart, err := s.managersSvc.GetCart(request.Context(), customerID)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, cart)
+}
+
+func (s *Server) handleCustomerClearCart(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	cart, err := s.managersSvc.ClearCart(request.Context(), customerID)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, cart)
+}
+
+func (s *Server) handleCustomerAddCartItem(writer http.ResponseWriter, request *http.Request) {
+	var change *CartChange
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&change)
+	if err != nil || change == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	cart, err := s.managersSvc.AddToCart(request.Context(), customerID, change.Product_id, change.Qty)
+	writeCart(writer, cart, err)
+}
+
+func (s *Server) handleCustomerSetCartItem(writer http.ResponseWriter, request *http.Request) {
+	var change *CartChange
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	productID, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&change)
+	if err != nil || change == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	cart, err := s.managersSvc.SetCartQty(request.Context(), customerID, productID, change.Qty)
+	writeCart(writer, cart, err)
+}
+
+func (s *Server) handleCustomerRemoveCartItem(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	productID, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	cart, err := s.managersSvc.RemoveFromCart(request.Context(), customerID, productID)
+	writeCart(writer, cart, err)
+}
+
+func (s *Server) handleCustomerCheckout(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	// the body with a promo code is optional
+	var body PromoRequest
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil && err != io.EOF {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	sale, err := s.managersSvc.Checkout(request.Context(), customerID, body.Promo)
+	switch err {
+	case nil:
+	case managers.ErrEmptyCart:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrProductNotFound, managers.ErrPromoNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrProductInactive, managers.ErrInsufficientStock, managers.ErrCustomerInactive,
+		managers.ErrPromoNotActive, managers.ErrPromoExhausted, managers.ErrPromoMinBasket,
+		managers.ErrPromoNotApplicable:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, sale)
+}
+
+// writeCart sends the cart changed by one of the cart handlers or the error
+// the change failed with.
+func writeCart(writer http.ResponseWriter, cart *managers.Cart, err error) {
+	switch err {
+	case nil:
+	case managers.ErrInvalidQty:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrProductNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrProductInactive:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, cart)
+}
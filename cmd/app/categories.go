@@ -0,0 +1,195 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerChangeCategory(writer http.ResponseWriter, request *http.Request) {
+	var category *managers.Category
+
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&category)
+	if err != nil || category == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.SaveCategory(request.Context(), managerID, category)
+	switch err {
+	case nil:
+	case managers.ErrInvalidCategory:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrCategoryCycle:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerGetCategories(writer http.ResponseWriter, request *http.Request) {
+	_, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	items, err := s.managersSvc.GetCategories(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
+
+func (s *Server) handleManagerRemoveCategoryByID(writer http.ResponseWriter, request *http.Request) {
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.RemoveCategoryByID(request.Context(), managerID, id)
+	switch err {
+	case nil:
+	case managers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrCategoryHasChildren, managers.ErrCategoryInUse:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerSetProductCategories(writer http.ResponseWriter, request *http.Request) {
+	var body struct {
+		Category_ids []int64 `json:"category_ids"`
+	}
+
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.SetProductCategories(request.Context(), managerID, id, body.Category_ids)
+	switch err {
+	case nil:
+	case managers.ErrProductNotFound, managers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
+
+func (s *Server) handleManagerGetProductCategories(writer http.ResponseWriter, request *http.Request) {
+	_, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.GetProductCategories(request.Context(), id)
+	switch err {
+	case nil:
+	case managers.ErrProductNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
+
+func (s *Server) handleCustomerGetCategories(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.customersSvc.Categories(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
+
+func (s *Server) handleCustomerGetCategoryProducts(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	filter, err := listing.ParseProductFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.customersSvc.CategoryProducts(request.Context(), id, filter)
+	switch err {
+	case nil:
+	case customers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		log.Print(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, page)
+}
@@ -7,7 +7,7 @@ import (
 
 	"github.com/khiki1995/crud/cmd/app/middleware"
 	"github.com/khiki1995/crud/pkg/customers"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/khiki1995/crud/pkg/listing"
 )
 
 func (s *Server) handleCustomerRegistration(writer http.ResponseWriter, request *http.Request) {
@@ -18,12 +18,6 @@ func (s *Server) handleCustomerRegistration(writer http.ResponseWriter, request
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(item.Password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Print(err)
-		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-	}
-	item.Password = string(hash)
 	customer, err := s.customersSvc.Register(request.Context(), item)
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -74,7 +68,13 @@ func (s *Server) handleCustomerValidateToken(writer http.ResponseWriter, request
 	return
 }
 func (s *Server) handleCustomerGetProducts(writer http.ResponseWriter, request *http.Request) {
-	items, err := s.customersSvc.Products(request.Context())
+	filter, err := listing.ParseProductFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.customersSvc.Products(request.Context(), filter)
 	if err != nil {
 		log.Print(err)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -94,6 +94,22 @@ func (s *Server) handleCustomerGetProducts(writer http.ResponseWriter, request *
 		log.Print(err)
 	}
 }
+func (s *Server) handleCustomerSearchProducts(writer http.ResponseWriter, request *http.Request) {
+	search, err := listing.ParseSearch(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.customersSvc.SearchProducts(request.Context(), search)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
 func (s *Server) handleCustomerGetPurchases(writer http.ResponseWriter, request *http.Request) {
 	id, err := middleware.Authentication(request.Context())
 	if err != nil {
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleCustomerGetLoyalty(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	loyalty, err := s.managersSvc.GetLoyalty(request.Context(), customerID)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, loyalty)
+}
+
+func (s *Server) handleManagerGetLoyalty(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	loyalty, err := s.managersSvc.GetLoyalty(request.Context(), id)
+	switch err {
+	case nil:
+	case customers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, loyalty)
+}
+
+func (s *Server) handleManagerGetLoyaltyRules(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.managersSvc.GetLoyaltyRules(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+func (s *Server) handleManagerCreateLoyaltyRule(writer http.ResponseWriter, request *http.Request) {
+	var rule *managers.LoyaltyRule
+
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&rule)
+	if err != nil || rule == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.CreateLoyaltyRule(request.Context(), adminID, rule)
+	switch err {
+	case nil:
+	case managers.ErrInvalidLoyaltyRule:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerRemoveLoyaltyRule(writer http.ResponseWriter, request *http.Request) {
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.RemoveLoyaltyRule(request.Context(), adminID, id)
+	switch err {
+	case nil:
+	case managers.ErrLoyaltyRuleNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
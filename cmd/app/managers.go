@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -9,27 +10,35 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/khiki1995/crud/cmd/app/middleware"
 	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
 	"github.com/khiki1995/crud/pkg/managers"
 )
 
 func (s *Server) handleManagerRegistration(writer http.ResponseWriter, request *http.Request) {
 	var reg *managers.Registration
-	id, err := middleware.Authentication(request.Context())
+	adminID, err := middleware.Authentication(request.Context())
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	if !s.managersSvc.IsAdmin(request.Context(), id) {
-		http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-		return
-	}
 	err = json.NewDecoder(request.Body).Decode(&reg)
-	if err != nil {
+	if err != nil || reg == nil {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
-	token, err := s.managersSvc.Register(request.Context(), reg)
-	if err != nil {
+	token, err := s.managersSvc.Register(request.Context(), adminID, reg)
+	switch err {
+	case nil:
+	case managers.ErrInvalidRole, managers.ErrInvalidPlan:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrBossNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrPhoneUsed:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -55,20 +64,28 @@ func (s *Server) handleManagerGetToken(writer http.ResponseWriter, request *http
 
 func (s *Server) handleManagerChangeProduct(writer http.ResponseWriter, request *http.Request) {
 	var product *managers.Product
-	_, err := middleware.Authentication(request.Context())
+	id, err := middleware.Authentication(request.Context())
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
 
 	err = json.NewDecoder(request.Body).Decode(&product)
-	if err != nil {
+	if err != nil || product == nil {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	item, err := s.managersSvc.SaveProduct(request.Context(), product)
-	if err != nil {
+	item, err := s.managersSvc.SaveProduct(request.Context(), id, product)
+	switch err {
+	case nil:
+	case managers.ErrInvalidProduct:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrProductNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
 		log.Print(err)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -87,7 +104,7 @@ func (s *Server) handleManagerMakeSale(writer http.ResponseWriter, request *http
 	}
 
 	err = json.NewDecoder(request.Body).Decode(&sale)
-	if err != nil {
+	if err != nil || sale == nil {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
@@ -95,7 +112,25 @@ func (s *Server) handleManagerMakeSale(writer http.ResponseWriter, request *http
 	sale.Manager_id = id
 
 	item, err := s.managersSvc.MakeSale(request.Context(), sale)
-	if err != nil {
+	switch err {
+	case nil:
+	case managers.ErrEmptySale, managers.ErrInvalidQty, managers.ErrInvalidDiscount, managers.ErrInvalidStatus,
+		managers.ErrInvalidPayment:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrDiscountForbidden:
+		http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case managers.ErrProductNotFound, customers.ErrUserNotFound, managers.ErrPromoNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrProductInactive, managers.ErrInsufficientStock, managers.ErrCustomerInactive,
+		managers.ErrSaleNotPayable, managers.ErrPaymentExceedsDue, managers.ErrInsufficientFunds,
+		managers.ErrInsufficientPoints, managers.ErrPromoNotActive, managers.ErrPromoExhausted,
+		managers.ErrPromoMinBasket, managers.ErrPromoNotApplicable:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
 		log.Print(err)
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -128,7 +163,13 @@ func (s *Server) handleManagerGetProducts(writer http.ResponseWriter, request *h
 		return
 	}
 
-	products, err := s.managersSvc.GetProducts(request.Context())
+	filter, err := listing.ParseProductFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	products, err := s.managersSvc.GetProducts(request.Context(), filter)
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -137,13 +178,44 @@ func (s *Server) handleManagerGetProducts(writer http.ResponseWriter, request *h
 	responseJSON(writer, 200, products)
 }
 
-func (s *Server) handleManagerRemoveProductByID(writer http.ResponseWriter, request *http.Request) {
+func (s *Server) handleManagerSearchProducts(writer http.ResponseWriter, request *http.Request) {
 	_, err := middleware.Authentication(request.Context())
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
 
+	search, err := listing.ParseSearch(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.SearchProducts(request.Context(), search)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+func (s *Server) handleManagerRemoveProductByID(writer http.ResponseWriter, request *http.Request) {
+	s.handleManagerSetProductActive(writer, request, s.managersSvc.RemoveProductByID)
+}
+
+func (s *Server) handleManagerRestoreProductByID(writer http.ResponseWriter, request *http.Request) {
+	s.handleManagerSetProductActive(writer, request, s.managersSvc.RestoreProductByID)
+}
+
+func (s *Server) handleManagerSetProductActive(writer http.ResponseWriter, request *http.Request,
+	set func(ctx context.Context, managerID int64, id int64) (*managers.Product, error)) {
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
 	idParam, ok := mux.Vars(request)["id"]
 	if !ok {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
@@ -151,13 +223,17 @@ func (s *Server) handleManagerRemoveProductByID(writer http.ResponseWriter, requ
 	}
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		log.Println(err)
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	product, err := s.managersSvc.RemoveProductByID(request.Context(), id)
-	if err != nil {
-		log.Print(err)
+	product, err := set(request.Context(), managerID, id)
+	switch err {
+	case nil:
+	case managers.ErrProductNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -168,23 +244,32 @@ func (s *Server) handleManagerRemoveProductByID(writer http.ResponseWriter, requ
 func (s *Server) handleManagerChangeCustomer(writer http.ResponseWriter, request *http.Request) {
 	var customer *customers.Customer
 
-	_, err := middleware.Authentication(request.Context())
+	managerID, err := middleware.Authentication(request.Context())
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
 
 	err = json.NewDecoder(request.Body).Decode(&customer)
-	if err != nil {
+	if err != nil || customer == nil {
 		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	item, err := s.managersSvc.ChangeCustomer(request.Context(), customer)
-	if err != nil {
+	item, err := s.managersSvc.ChangeCustomer(request.Context(), managerID, customer)
+	switch err {
+	case nil:
+	case managers.ErrInvalidCustomer:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case customers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+
 	responseJSON(writer, 200, item)
 }
 
@@ -195,7 +280,13 @@ func (s *Server) handleManagerGetCustomers(writer http.ResponseWriter, request *
 		return
 	}
 
-	customers, err := s.managersSvc.GetCustomers(request.Context())
+	filter, err := listing.ParseCustomerFilter(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	customers, err := s.managersSvc.GetCustomers(request.Context(), filter)
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -205,7 +296,16 @@ func (s *Server) handleManagerGetCustomers(writer http.ResponseWriter, request *
 }
 
 func (s *Server) handleManagerRemoveCustomerByID(writer http.ResponseWriter, request *http.Request) {
-	_, err := middleware.Authentication(request.Context())
+	s.handleManagerSetCustomerActive(writer, request, s.managersSvc.RemoveCustomerByID)
+}
+
+func (s *Server) handleManagerRestoreCustomerByID(writer http.ResponseWriter, request *http.Request) {
+	s.handleManagerSetCustomerActive(writer, request, s.managersSvc.RestoreCustomerByID)
+}
+
+func (s *Server) handleManagerSetCustomerActive(writer http.ResponseWriter, request *http.Request,
+	set func(ctx context.Context, managerID int64, id int64) (*customers.Customer, error)) {
+	managerID, err := middleware.Authentication(request.Context())
 	if err != nil {
 		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
@@ -222,8 +322,13 @@ func (s *Server) handleManagerRemoveCustomerByID(writer http.ResponseWriter, req
 		return
 	}
 
-	customer, err := s.managersSvc.RemoveCustomerByID(request.Context(), id)
-	if err != nil {
+	customer, err := set(request.Context(), managerID, id)
+	switch err {
+	case nil:
+	case customers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
 		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,18 @@
+package middleware
+
+import "net/http"
+
+// MaxBodySize rejects request bodies larger than limit bytes: reads past the
+// limit fail, so handlers answer them as bad requests.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.ContentLength > limit {
+				http.Error(writer, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			request.Body = http.MaxBytesReader(writer, request.Body, limit)
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
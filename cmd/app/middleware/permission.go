@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+type PermissionsFunc func(ctx context.Context, id int64) ([]string, error)
+
+// Require lets the request through only if it is authenticated and the
+// caller is granted every one of permissions; it must run after Authenticate.
+func Require(permissionsFunc PermissionsFunc, permissions ...string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			id, err := Authentication(request.Context())
+			if err != nil || id == 0 {
+				http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			granted, err := permissionsFunc(request.Context(), id)
+			if err != nil {
+				http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			for _, permission := range permissions {
+				if !contains(granted, permission) {
+					http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+			}
+
+			handler.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func contains(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/khiki1995/crud/pkg/requestid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID takes the request id from the X-Request-ID header or generates
+// one, echoes it in the response and stores it in the request context.
+func RequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			buffer := make([]byte, 16)
+			_, err := rand.Read(buffer)
+			if err != nil {
+				http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			id = hex.EncodeToString(buffer)
+		}
+		writer.Header().Set(RequestIDHeader, id)
+		handler.ServeHTTP(writer, request.WithContext(requestid.NewContext(request.Context(), id)))
+	})
+}
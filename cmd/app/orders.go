@@ -0,0 +1,84 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerSetSaleStatus(writer http.ResponseWriter, request *http.Request) {
+	var body *managers.SaleStatus
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || body == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	sale, err := s.managersSvc.SetSaleStatus(request.Context(), managerID, id, body.Status)
+	switch err {
+	case nil:
+	case managers.ErrSaleNotFound, managers.ErrProductNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrStatusTransition, managers.ErrProductInactive, managers.ErrInsufficientStock,
+		managers.ErrSaleHasPayments:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, sale)
+}
+
+func (s *Server) handleManagerGetSaleStatus(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	status, err := s.managersSvc.GetSaleStatus(request.Context(), id)
+	switch err {
+	case nil:
+	case managers.ErrSaleNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, status)
+}
+
+func (s *Server) handleManagerGetOrders(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.managersSvc.GetOrders(request.Context(), request.URL.Query().Get("status"))
+	switch err {
+	case nil:
+	case managers.ErrInvalidStatus:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
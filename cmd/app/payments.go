@@ -0,0 +1,190 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+	"github.com/khiki1995/crud/pkg/payments"
+)
+
+// PaymentRequest is the body of a sale payment: one tender, or several
+// for a split payment.
+type PaymentRequest struct {
+	Tenders []*managers.Tender `json:"tenders"`
+}
+
+type RefundRequest struct {
+	Amount int `json:"amount"`
+}
+
+func (s *Server) handleManagerPaySale(writer http.ResponseWriter, request *http.Request) {
+	var body *PaymentRequest
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || body == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.PaySale(request.Context(), managerID, id, body.Tenders)
+	switch err {
+	case nil:
+	case managers.ErrInvalidPayment:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrSaleNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrSaleNotPayable, managers.ErrPaymentExceedsDue, managers.ErrInsufficientFunds:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	case managers.ErrPaymentDeclined:
+		responseJSON(writer, http.StatusPaymentRequired, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
+
+func (s *Server) handleManagerGetPayments(writer http.ResponseWriter, request *http.Request) {
+	_, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.GetPayments(request.Context(), id)
+	switch err {
+	case nil:
+	case managers.ErrSaleNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
+
+func (s *Server) handleManagerRefundPayment(writer http.ResponseWriter, request *http.Request) {
+	var body *RefundRequest
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || body == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	payment, err := s.managersSvc.RefundPayment(request.Context(), managerID, id, body.Amount)
+	switch err {
+	case nil:
+	case managers.ErrInvalidPayment:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrPaymentNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrRefundExceeded, managers.ErrRefundPending:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, payment)
+}
+
+func (s *Server) handleManagerGetReconciliation(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	var period managers.Period
+	var err error
+	period.From, period.To, err = listing.ParseRange(query, "from", "to")
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.Reconcile(request.Context(), period, query.Get("mismatched") == "true")
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if query.Get("format") != "csv" {
+		responseJSON(writer, 200, map[string]interface{}{"period": period, "items": items})
+		return
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []string{
+			strconv.FormatInt(item.Sale_id, 10), strconv.FormatInt(item.Receipt_no, 10), item.Status,
+			item.Created.Format("2006-01-02 15:04:05"), strconv.Itoa(item.Total), strconv.Itoa(item.Returned),
+			strconv.Itoa(item.Paid), strconv.Itoa(item.Difference),
+		})
+	}
+	responseCSV(writer, "reconciliation.csv",
+		[]string{"sale_id", "receipt_no", "status", "created", "total", "returned", "paid", "difference"}, rows)
+}
+
+// handlePaymentsWebhook takes notifications of the payment gateway, which
+// authenticates them by their signature rather than a token.
+func (s *Server) handlePaymentsWebhook(writer http.ResponseWriter, request *http.Request) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = s.managersSvc.HandlePaymentWebhook(request.Context(), request.Header, body)
+	switch err {
+	case nil:
+	case payments.ErrInvalidSignature:
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	case managers.ErrPaymentNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"status": "ok"})
+}
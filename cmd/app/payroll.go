@@ -0,0 +1,200 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerGetCommissionRules(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.managersSvc.GetCommissionRules(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+func (s *Server) handleManagerCreateCommissionRule(writer http.ResponseWriter, request *http.Request) {
+	var rule *managers.CommissionRule
+
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&rule)
+	if err != nil || rule == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.CreateCommissionRule(request.Context(), adminID, rule)
+	switch err {
+	case nil:
+	case managers.ErrInvalidCommissionRule:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerRemoveCommissionRule(writer http.ResponseWriter, request *http.Request) {
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.RemoveCommissionRule(request.Context(), adminID, id)
+	switch err {
+	case nil:
+	case managers.ErrCommissionRuleNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+// handleManagerGetPayroll returns the statements of every manager for the
+// month, as JSON or, with format=csv, as one CSV row per statement.
+func (s *Server) handleManagerGetPayroll(writer http.ResponseWriter, request *http.Request) {
+	month, err := parseMonth(request.URL.Query().Get("month"))
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.GetStatements(request.Context(), month)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if request.URL.Query().Get("format") != "csv" {
+		responseJSON(writer, 200, map[string]interface{}{"items": items})
+		return
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []string{
+			strconv.FormatInt(item.Manager_id, 10), item.Name, item.Month.Format("2006-01"), item.Status,
+			strconv.Itoa(item.Salary), strconv.Itoa(item.Plan), strconv.Itoa(item.Actual),
+			strconv.Itoa(item.Attainment), strconv.Itoa(item.Commission), strconv.Itoa(item.Total),
+		})
+	}
+	responseCSV(writer, "payroll-"+month.Format("2006-01")+".csv",
+		[]string{"manager_id", "name", "month", "status", "salary", "plan", "actual", "attainment", "commission", "total"}, rows)
+}
+
+// handleManagerGetStatement returns the statement of one manager for the
+// month, as JSON or, with format=csv, as one CSV row per commission line.
+func (s *Server) handleManagerGetStatement(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	month, err := parseMonth(request.URL.Query().Get("month"))
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.GetStatement(request.Context(), id, month)
+	switch err {
+	case nil:
+	case managers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if request.URL.Query().Get("format") != "csv" {
+		responseJSON(writer, 200, item)
+		return
+	}
+	rows := make([][]string, 0, len(item.Lines))
+	for _, line := range item.Lines {
+		ruleID := ""
+		if line.Rule_id != nil {
+			ruleID = strconv.FormatInt(*line.Rule_id, 10)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(line.Sale_id, 10), strconv.FormatInt(line.Position_id, 10),
+			strconv.FormatInt(line.Product_id, 10), line.Name, strconv.Itoa(line.Qty), strconv.Itoa(line.Amount),
+			ruleID, strconv.Itoa(line.Rate_bp), strconv.Itoa(line.Commission),
+		})
+	}
+	responseCSV(writer, "payroll-"+strconv.FormatInt(id, 10)+"-"+month.Format("2006-01")+".csv",
+		[]string{"sale_id", "position_id", "product_id", "name", "qty", "amount", "rule_id", "rate_bp", "commission"}, rows)
+}
+
+func (s *Server) handleManagerFinalizeStatement(writer http.ResponseWriter, request *http.Request) {
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	month, err := parseMonth(request.URL.Query().Get("month"))
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.FinalizeStatement(request.Context(), adminID, id, month)
+	switch err {
+	case nil:
+	case managers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrStatementFinalized:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+// parseMonth parses YYYY-MM, defaulting to the current month.
+func parseMonth(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	return time.Parse("2006-01", value)
+}
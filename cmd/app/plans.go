@@ -0,0 +1,149 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerGetPlan(writer http.ResponseWriter, request *http.Request) {
+	id, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	period, date, err := parsePlanQuery(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.GetPlanProgress(request.Context(), id, period, date)
+	switch err {
+	case nil:
+	case managers.ErrInvalidPlan:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerGetPlans(writer http.ResponseWriter, request *http.Request) {
+	period, date, err := parsePlanQuery(request.URL.Query())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.GetPlansProgress(request.Context(), period, date)
+	switch err {
+	case nil:
+	case managers.ErrInvalidPlan:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+func (s *Server) handleManagerSetPlan(writer http.ResponseWriter, request *http.Request) {
+	var body struct {
+		Period string `json:"period"`
+		Start  string `json:"start"`
+		Amount int    `json:"amount"`
+	}
+
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse("2006-01-02", body.Start)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	plan := &managers.Plan{Manager_id: id, Period: body.Period, Start: start, Amount: body.Amount}
+	item, err := s.managersSvc.SetPlan(request.Context(), adminID, plan)
+	switch err {
+	case nil:
+	case managers.ErrInvalidPlan:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerGetPlanHistory(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.managersSvc.GetPlanHistory(request.Context(), id)
+	switch err {
+	case nil:
+	case managers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+// parsePlanQuery reads period (month by default) and date (YYYY-MM-DD,
+// today by default) choosing the plan period to report on.
+func parsePlanQuery(query url.Values) (period string, date time.Time, err error) {
+	period = query.Get("period")
+	if period == "" {
+		period = managers.PlanMonth
+	}
+	date = time.Now()
+	if value := query.Get("date"); value != "" {
+		date, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	return period, date, nil
+}
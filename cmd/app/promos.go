@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// PromoRequest is the body of the promo code endpoints of customers.
+type PromoRequest struct {
+	Promo string `json:"promo"`
+}
+
+func (s *Server) handleCustomerValidatePromo(writer http.ResponseWriter, request *http.Request) {
+	var body *PromoRequest
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || body == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	quote, err := s.managersSvc.ValidateCartPromo(request.Context(), customerID, body.Promo)
+	writePromoQuote(writer, quote, err)
+}
+
+func (s *Server) handleManagerValidatePromo(writer http.ResponseWriter, request *http.Request) {
+	var sale *managers.Sale
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&sale)
+	if err != nil || sale == nil || len(sale.Positions) == 0 {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	sale.Manager_id = managerID
+
+	quote, err := s.managersSvc.ValidatePromo(request.Context(), sale)
+	writePromoQuote(writer, quote, err)
+}
+
+// writePromoQuote sends the quote of one of the validation handlers or the
+// error the validation failed with.
+func writePromoQuote(writer http.ResponseWriter, quote *managers.PromoQuote, err error) {
+	switch err {
+	case nil:
+	case managers.ErrEmptyCart, managers.ErrInvalidQty, managers.ErrInvalidDiscount:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrDiscountForbidden:
+		http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case managers.ErrPromoNotFound, managers.ErrProductNotFound, customers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrPromoNotActive, managers.ErrPromoExhausted, managers.ErrPromoMinBasket,
+		managers.ErrPromoNotApplicable, managers.ErrProductInactive, managers.ErrCustomerInactive:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, quote)
+}
+
+func (s *Server) handleManagerGetPromos(writer http.ResponseWriter, request *http.Request) {
+	items, err := s.managersSvc.GetPromos(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"items": items})
+}
+
+func (s *Server) handleManagerCreatePromo(writer http.ResponseWriter, request *http.Request) {
+	var promo *managers.Promo
+
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&promo)
+	if err != nil || promo == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.CreatePromo(request.Context(), adminID, promo)
+	switch err {
+	case nil:
+	case managers.ErrInvalidPromo:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrProductNotFound, managers.ErrCategoryNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrPromoCodeUsed:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerGetPromoUsage(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	usage, err := s.managersSvc.GetPromoUsage(request.Context(), id)
+	switch err {
+	case nil:
+	case managers.ErrPromoNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, usage)
+}
+
+func (s *Server) handleManagerRemovePromoByID(writer http.ResponseWriter, request *http.Request) {
+	s.handleManagerSetPromoActive(writer, request, s.managersSvc.RemovePromoByID)
+}
+
+func (s *Server) handleManagerRestorePromoByID(writer http.ResponseWriter, request *http.Request) {
+	s.handleManagerSetPromoActive(writer, request, s.managersSvc.RestorePromoByID)
+}
+
+func (s *Server) handleManagerSetPromoActive(writer http.ResponseWriter, request *http.Request,
+	set func(ctx context.Context, adminID int64, id int64) (*managers.Promo, error)) {
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	promo, err := set(request.Context(), adminID, id)
+	switch err {
+	case nil:
+	case managers.ErrPromoNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, promo)
+}
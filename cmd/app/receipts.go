@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/receipts"
+)
+
+func (s *Server) handleManagerGetReceipt(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := s.managersSvc.GetReceipt(request.Context(), id)
+	switch err {
+	case nil:
+	case receipts.ErrNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeReceipt(writer, request, receipt)
+}
+
+func (s *Server) handleCustomerGetReceipt(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := s.customersSvc.Receipt(request.Context(), customerID, id)
+	switch err {
+	case nil:
+	case receipts.ErrNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeReceipt(writer, request, receipt)
+}
+
+// writeReceipt renders the receipt in the format query parameter: html (the
+// default), text for ESC/POS printers (width characters wide), pdf or json.
+func writeReceipt(writer http.ResponseWriter, request *http.Request, receipt *receipts.Receipt) {
+	query := request.URL.Query()
+	width := receipts.DefaultWidth
+	if value := query.Get("width"); value != "" {
+		var err error
+		width, err = strconv.Atoi(value)
+		if err != nil || width < receipts.MinWidth || width > receipts.MaxWidth {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var body bytes.Buffer
+	var contentType string
+	var err error
+	switch query.Get("format") {
+	case "", "html":
+		contentType = "text/html; charset=utf-8"
+		err = receipts.HTML(&body, receipt)
+	case "text":
+		contentType = "text/plain; charset=utf-8"
+		err = receipts.Text(&body, receipt, width)
+	case "pdf":
+		contentType = "application/pdf"
+		err = receipts.PDF(&body, receipt)
+		writer.Header().Set("Content-Disposition",
+			`inline; filename="receipt-`+strconv.FormatInt(receipt.Number, 10)+`.pdf"`)
+	case "json":
+		responseJSON(writer, 200, receipt)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(http.StatusOK)
+	_, err = writer.Write(body.Bytes())
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,226 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerGetTeamReport(writer http.ResponseWriter, request *http.Request) {
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	query := request.URL.Query()
+	bossID := managerID
+	if value := query.Get("boss_id"); value != "" {
+		bossID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	var period managers.Period
+	period.From, period.To, err = listing.ParseRange(query, "from", "to")
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.managersSvc.TeamReport(request.Context(), managerID, bossID, period)
+	switch err {
+	case nil:
+	case managers.ErrReportForbidden:
+		http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case managers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, report)
+}
+
+func (s *Server) handleManagerGetDepartmentsReport(writer http.ResponseWriter, request *http.Request) {
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var period managers.Period
+	period.From, period.To, err = listing.ParseRange(request.URL.Query(), "from", "to")
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.managersSvc.DepartmentsReport(request.Context(), managerID, period)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, report)
+}
+
+func (s *Server) handleManagerSetTeam(writer http.ResponseWriter, request *http.Request) {
+	var team *managers.Team
+
+	adminID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&team)
+	if err != nil || team == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	item, err := s.managersSvc.SetTeam(request.Context(), adminID, id, team)
+	switch err {
+	case nil:
+	case managers.ErrUserNotFound, managers.ErrBossNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrHierarchyCycle:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, map[string]interface{}{"id": id, "boss_id": item.Boss_id, "department": item.Department})
+}
+
+// handleManagerGetSalesReport serves the sales report as JSON or, with
+// format=csv or format=ndjson, streams it row by row so that large ranges
+// do not have to be buffered.
+func (s *Server) handleManagerGetSalesReport(writer http.ResponseWriter, request *http.Request) {
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	query := request.URL.Query()
+	report := &managers.SalesReportQuery{Group_by: query.Get("group_by"), Location: time.UTC}
+	if report.Group_by == "" {
+		report.Group_by = managers.ReportByDay
+	}
+	if value := query.Get("tz"); value != "" {
+		report.Location, err = time.LoadLocation(value)
+		if err != nil {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	report.Period.From, report.Period.To, err = listing.ParseRangeIn(query, "from", "to", report.Location)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" && format != "ndjson" {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	items := make([]*managers.SalesReportRow, 0)
+	out := csv.NewWriter(writer)
+	encoder := json.NewEncoder(writer)
+	started := false
+	// start sends the headers of a streamed report once, before its first row.
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		if format == "csv" {
+			writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			writer.WriteHeader(http.StatusOK)
+			return out.Write([]string{"key", "label", "sales_count", "units", "revenue"})
+		}
+		writer.Header().Set("Content-Type", "application/x-ndjson")
+		writer.WriteHeader(http.StatusOK)
+		return nil
+	}
+	write := func(row *managers.SalesReportRow) error {
+		switch format {
+		case "", "json":
+			items = append(items, row)
+			return nil
+		case "csv":
+			err := start()
+			if err != nil {
+				return err
+			}
+			err = out.Write([]string{row.Key, row.Label, strconv.Itoa(row.Sales_count),
+				strconv.Itoa(row.Units), strconv.Itoa(row.Revenue)})
+			if err != nil {
+				return err
+			}
+			out.Flush()
+		default:
+			err := start()
+			if err != nil {
+				return err
+			}
+			err = encoder.Encode(row)
+			if err != nil {
+				return err
+			}
+		}
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	err = s.managersSvc.SalesReport(request.Context(), managerID, report, write)
+	switch {
+	case err == nil:
+	case started:
+		// The status is already sent; the client sees a truncated stream.
+		log.Print(err)
+		return
+	case err == managers.ErrInvalidReport:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "" || format == "json" {
+		responseJSON(writer, 200, map[string]interface{}{"group_by": report.Group_by, "tz": report.Location.String(),
+			"period": report.Period, "items": items})
+		return
+	}
+	err = start()
+	out.Flush()
+	if err != nil {
+		log.Print(err)
+	}
+}
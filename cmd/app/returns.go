@@ -0,0 +1,74 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (s *Server) handleManagerMakeReturn(writer http.ResponseWriter, request *http.Request) {
+	var ret *managers.Return
+
+	id, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&ret)
+	if err != nil || ret == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret.Manager_id = id
+
+	item, err := s.managersSvc.MakeReturn(request.Context(), ret)
+	switch err {
+	case nil:
+	case managers.ErrEmptyReturn, managers.ErrInvalidQty:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case managers.ErrSaleNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrPositionNotFound, managers.ErrReturnQtyExceeded, managers.ErrSaleNotFulfilled:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		log.Print(err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, item)
+}
+
+func (s *Server) handleManagerGetReturns(writer http.ResponseWriter, request *http.Request) {
+	_, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var saleID int64
+	if param := request.URL.Query().Get("sale_id"); param != "" {
+		saleID, err = strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, err := s.managersSvc.GetReturns(request.Context(), saleID)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, items)
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -37,6 +38,8 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 }
 
 func (s *Server) Init() {
+	s.mux.HandleFunc("/api/payments/webhook", s.handlePaymentsWebhook).Methods(POST)
+
 	customersAuth := middleware.Authenticate(s.customersSvc.IDByToken)
 	customersSR := s.mux.PathPrefix("/api/customers").Subrouter()
 	customersSR.Use(customersAuth)
@@ -44,21 +47,104 @@ func (s *Server) Init() {
 	customersSR.HandleFunc("/token", s.handleCustomerGetToken).Methods(POST)
 	customersSR.HandleFunc("/token/validate", s.handleCustomerValidateToken).Methods(POST)
 	customersSR.HandleFunc("/products", s.handleCustomerGetProducts).Methods(GET)
+	customersSR.HandleFunc("/products/search", s.handleCustomerSearchProducts).Methods(GET)
+	customersSR.HandleFunc("/categories", s.handleCustomerGetCategories).Methods(GET)
+	customersSR.HandleFunc("/categories/{id}/products", s.handleCustomerGetCategoryProducts).Methods(GET)
 	customersSR.HandleFunc("/purchases", s.handleCustomerGetPurchases).Methods(GET)
+	customersSR.HandleFunc("/sales/{id:[0-9]+}/receipt", s.handleCustomerGetReceipt).Methods(GET)
+	customersSR.HandleFunc("/cart", s.handleCustomerGetCart).Methods(GET)
+	customersSR.HandleFunc("/cart", s.handleCustomerClearCart).Methods(DELETE)
+	customersSR.HandleFunc("/cart/items", s.handleCustomerAddCartItem).Methods(POST)
+	customersSR.HandleFunc("/cart/items/{id:[0-9]+}", s.handleCustomerSetCartItem).Methods(POST)
+	customersSR.HandleFunc("/cart/items/{id:[0-9]+}", s.handleCustomerRemoveCartItem).Methods(DELETE)
+	customersSR.HandleFunc("/cart/checkout", s.handleCustomerCheckout).Methods(POST)
+	customersSR.HandleFunc("/cart/promo", s.handleCustomerValidatePromo).Methods(POST)
+	customersSR.HandleFunc("/wallet", s.handleCustomerGetWallet).Methods(GET)
+	customersSR.HandleFunc("/loyalty", s.handleCustomerGetLoyalty).Methods(GET)
 
 	managersAuth := middleware.Authenticate(s.managersSvc.IDByToken)
 	managersSR := s.mux.PathPrefix("/api/managers").Subrouter()
 	managersSR.Use(managersAuth)
-	managersSR.HandleFunc("", s.handleManagerRegistration).Methods(POST)
+	// can wraps a handler with the permissions it requires.
+	can := func(handler http.HandlerFunc, permissions ...string) http.Handler {
+		return middleware.Require(s.managersSvc.Permissions, permissions...)(handler)
+	}
+	managersSR.Handle("", can(s.handleManagerRegistration, managers.PermissionManagersWrite)).Methods(POST)
 	managersSR.HandleFunc("/token", s.handleManagerGetToken).Methods(POST)
-	managersSR.HandleFunc("/sales", s.handleManagerMakeSale).Methods(POST)
-	managersSR.HandleFunc("/sales", s.handleManagerGetSales).Methods(GET)
-	managersSR.HandleFunc("/products", s.handleManagerChangeProduct).Methods(POST)
-	managersSR.HandleFunc("/products", s.handleManagerGetProducts).Methods(GET)
-	managersSR.HandleFunc("/products/{id}", s.handleManagerRemoveProductByID).Methods(DELETE)
-	managersSR.HandleFunc("/customers", s.handleManagerChangeCustomer).Methods(POST)
-	managersSR.HandleFunc("/customers", s.handleManagerGetCustomers).Methods(GET)
-	managersSR.HandleFunc("/customers/{id}", s.handleManagerRemoveCustomerByID).Methods(DELETE)
+	managersSR.Handle("/roles", can(s.handleManagerGetRoles, managers.PermissionManagersWrite)).Methods(GET)
+	managersSR.Handle("/{id:[0-9]+}/roles", can(s.handleManagerGetManagerRoles, managers.PermissionManagersWrite)).Methods(GET)
+	managersSR.Handle("/{id:[0-9]+}/roles", can(s.handleManagerSetManagerRoles, managers.PermissionManagersWrite)).Methods(POST)
+	managersSR.Handle("/audit", can(s.handleManagerGetAudit, managers.PermissionAuditRead)).Methods(GET)
+	managersSR.Handle("/{id:[0-9]+}/team", can(s.handleManagerSetTeam, managers.PermissionManagersWrite)).Methods(POST)
+	managersSR.Handle("/{id:[0-9]+}/plans", can(s.handleManagerSetPlan, managers.PermissionManagersWrite)).Methods(POST)
+	managersSR.Handle("/{id:[0-9]+}/plans", can(s.handleManagerGetPlanHistory, managers.PermissionReportsAll)).Methods(GET)
+	managersSR.Handle("/plan", can(s.handleManagerGetPlan)).Methods(GET)
+	managersSR.Handle("/plans", can(s.handleManagerGetPlans, managers.PermissionReportsAll)).Methods(GET)
+	managersSR.Handle("/{id:[0-9]+}/payroll", can(s.handleManagerGetStatement, managers.PermissionPayrollManage)).Methods(GET)
+	managersSR.Handle("/{id:[0-9]+}/payroll/finalize", can(s.handleManagerFinalizeStatement, managers.PermissionPayrollManage)).Methods(POST)
+	managersSR.Handle("/payroll", can(s.handleManagerGetPayroll, managers.PermissionPayrollManage)).Methods(GET)
+	managersSR.Handle("/commission/rules", can(s.handleManagerGetCommissionRules, managers.PermissionPayrollManage)).Methods(GET)
+	managersSR.Handle("/commission/rules", can(s.handleManagerCreateCommissionRule, managers.PermissionPayrollManage)).Methods(POST)
+	managersSR.Handle("/commission/rules/{id:[0-9]+}", can(s.handleManagerRemoveCommissionRule, managers.PermissionPayrollManage)).Methods(DELETE)
+	managersSR.Handle("/loyalty/rules", can(s.handleManagerGetLoyaltyRules, managers.PermissionLoyaltyManage)).Methods(GET)
+	managersSR.Handle("/loyalty/rules", can(s.handleManagerCreateLoyaltyRule, managers.PermissionLoyaltyManage)).Methods(POST)
+	managersSR.Handle("/loyalty/rules/{id:[0-9]+}", can(s.handleManagerRemoveLoyaltyRule, managers.PermissionLoyaltyManage)).Methods(DELETE)
+	managersSR.Handle("/promos", can(s.handleManagerGetPromos, managers.PermissionPromosManage)).Methods(GET)
+	managersSR.Handle("/promos", can(s.handleManagerCreatePromo, managers.PermissionPromosManage)).Methods(POST)
+	managersSR.Handle("/promos/validate", can(s.handleManagerValidatePromo, managers.PermissionSalesCreate)).Methods(POST)
+	managersSR.Handle("/promos/{id:[0-9]+}", can(s.handleManagerGetPromoUsage, managers.PermissionPromosManage)).Methods(GET)
+	managersSR.Handle("/promos/{id:[0-9]+}", can(s.handleManagerRemovePromoByID, managers.PermissionPromosManage)).Methods(DELETE)
+	managersSR.Handle("/promos/{id:[0-9]+}/restore", can(s.handleManagerRestorePromoByID, managers.PermissionPromosManage)).Methods(POST)
+	managersSR.Handle("/reports/team", can(s.handleManagerGetTeamReport, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/reports/sales", can(s.handleManagerGetSalesReport, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/reports/departments", can(s.handleManagerGetDepartmentsReport, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/sales", can(s.handleManagerMakeSale, managers.PermissionSalesCreate)).Methods(POST)
+	managersSR.Handle("/sales", can(s.handleManagerGetSales, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/sales/{id:[0-9]+}/receipt", can(s.handleManagerGetReceipt, managers.PermissionSalesCreate)).Methods(GET)
+	managersSR.Handle("/sales/{id:[0-9]+}/status", can(s.handleManagerSetSaleStatus, managers.PermissionSalesCreate)).Methods(POST)
+	managersSR.Handle("/sales/{id:[0-9]+}/status", can(s.handleManagerGetSaleStatus, managers.PermissionSalesCreate)).Methods(GET)
+	managersSR.Handle("/orders", can(s.handleManagerGetOrders, managers.PermissionSalesCreate)).Methods(GET)
+	managersSR.Handle("/sales/{id:[0-9]+}/payments", can(s.handleManagerPaySale, managers.PermissionSalesCreate)).Methods(POST)
+	managersSR.Handle("/sales/{id:[0-9]+}/payments", can(s.handleManagerGetPayments, managers.PermissionSalesCreate)).Methods(GET)
+	managersSR.Handle("/payments/{id:[0-9]+}/refund", can(s.handleManagerRefundPayment, managers.PermissionReturnsCreate)).Methods(POST)
+	managersSR.Handle("/payments/reconciliation", can(s.handleManagerGetReconciliation, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/returns", can(s.handleManagerMakeReturn, managers.PermissionReturnsCreate)).Methods(POST)
+	managersSR.Handle("/returns", can(s.handleManagerGetReturns, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/products", can(s.handleManagerChangeProduct, managers.PermissionProductsWrite)).Methods(POST)
+	managersSR.Handle("/products", can(s.handleManagerGetProducts, managers.PermissionProductsRead)).Methods(GET)
+	managersSR.Handle("/products/search", can(s.handleManagerSearchProducts, managers.PermissionProductsRead)).Methods(GET)
+	managersSR.Handle("/products/{id}", can(s.handleManagerRemoveProductByID, managers.PermissionProductsDelete)).Methods(DELETE)
+	managersSR.Handle("/products/{id}/restore", can(s.handleManagerRestoreProductByID, managers.PermissionProductsDelete)).Methods(POST)
+	managersSR.Handle("/products/{id}/categories", can(s.handleManagerSetProductCategories, managers.PermissionProductsWrite)).Methods(POST)
+	managersSR.Handle("/products/{id}/categories", can(s.handleManagerGetProductCategories, managers.PermissionProductsRead)).Methods(GET)
+	managersSR.Handle("/products/{id}/stock", can(s.handleManagerPostStockMovement, managers.PermissionStockWrite)).Methods(POST)
+	managersSR.Handle("/products/{id}/stock", can(s.handleManagerGetStockMovements, managers.PermissionProductsRead)).Methods(GET)
+	managersSR.Handle("/stock/discrepancies", can(s.handleManagerGetStockDiscrepancies, managers.PermissionReportsRead)).Methods(GET)
+	managersSR.Handle("/categories", can(s.handleManagerChangeCategory, managers.PermissionProductsWrite)).Methods(POST)
+	managersSR.Handle("/categories", can(s.handleManagerGetCategories, managers.PermissionProductsRead)).Methods(GET)
+	managersSR.Handle("/categories/{id}", can(s.handleManagerRemoveCategoryByID, managers.PermissionProductsWrite)).Methods(DELETE)
+	managersSR.Handle("/customers", can(s.handleManagerChangeCustomer, managers.PermissionCustomersWrite)).Methods(POST)
+	managersSR.Handle("/customers", can(s.handleManagerGetCustomers, managers.PermissionCustomersRead)).Methods(GET)
+	managersSR.Handle("/customers/{id}", can(s.handleManagerRemoveCustomerByID, managers.PermissionCustomersDelete)).Methods(DELETE)
+	managersSR.Handle("/customers/{id}/restore", can(s.handleManagerRestoreCustomerByID, managers.PermissionCustomersDelete)).Methods(POST)
+	managersSR.Handle("/customers/{id:[0-9]+}/wallet", can(s.handleManagerGetWallet, managers.PermissionCustomersRead)).Methods(GET)
+	managersSR.Handle("/customers/{id:[0-9]+}/wallet/top-up", can(s.handleManagerTopUpWallet, managers.PermissionCustomersWrite)).Methods(POST)
+	managersSR.Handle("/customers/{id:[0-9]+}/loyalty", can(s.handleManagerGetLoyalty, managers.PermissionCustomersRead)).Methods(GET)
+}
+
+// responseCSV sends header and rows as a CSV attachment named filename.
+func responseCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	err := out.Write(header)
+	if err == nil {
+		err = out.WriteAll(rows)
+	}
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 func responseJSON(w http.ResponseWriter, statusCode int, response interface{}) {
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/khiki1995/crud/cmd/app/middleware"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// TopUpRequest is the body of a wallet top-up.
+type TopUpRequest struct {
+	Amount  int    `json:"amount"`
+	Comment string `json:"comment"`
+}
+
+func (s *Server) handleCustomerGetWallet(writer http.ResponseWriter, request *http.Request) {
+	customerID, err := middleware.Authentication(request.Context())
+	if err != nil || customerID == 0 {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	wallet, err := s.managersSvc.GetWallet(request.Context(), customerID)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, wallet)
+}
+
+func (s *Server) handleManagerGetWallet(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := s.managersSvc.GetWallet(request.Context(), id)
+	switch err {
+	case nil:
+	case customers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, wallet)
+}
+
+func (s *Server) handleManagerTopUpWallet(writer http.ResponseWriter, request *http.Request) {
+	var body *TopUpRequest
+	managerID, err := middleware.Authentication(request.Context())
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(request)["id"], 10, 64)
+	if err != nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || body == nil {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := s.managersSvc.TopUpWallet(request.Context(), managerID, id, body.Amount, body.Comment)
+	switch err {
+	case nil:
+	case managers.ErrInvalidAmount:
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	case customers.ErrUserNotFound:
+		http.Error(writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case managers.ErrCustomerInactive:
+		responseJSON(writer, http.StatusConflict, map[string]interface{}{"status": "fail", "reason": err.Error()})
+		return
+	default:
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	responseJSON(writer, 200, wallet)
+}
@@ -1,9 +1,10 @@
 module github.com/khiki1995/crud
 
-go 1.15
+go 1.16
 
 require (
 	github.com/gorilla/mux v1.8.0
+	github.com/jackc/pgconn v1.8.0
 	github.com/jackc/pgx v3.6.2+incompatible
 	github.com/jackc/pgx/v4 v4.10.0
 	github.com/pkg/errors v0.9.1 // indirect
@@ -11,4 +12,5 @@ require (
 	golang.org/x/crypto v0.0.0-20201217014255-9d1352758620
 	golang.org/x/text v0.3.4 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix is prepended to the upper-cased flag name to get the
+// environment variable for an option, e.g. db-dsn is read from CRUD_DB_DSN.
+const envPrefix = "CRUD_"
+
+var ErrInvalid = errors.New("invalid config")
+
+type Config struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Storage  string   `yaml:"storage"`
+	Migrate  bool     `yaml:"migrate"`
+	DB       DB       `yaml:"db"`
+	Auth     Auth     `yaml:"auth"`
+	HTTP     HTTP     `yaml:"http"`
+	Receipt  Receipt  `yaml:"receipt"`
+	Payments Payments `yaml:"payments"`
+	Loyalty  Loyalty  `yaml:"loyalty"`
+	// PrintConfig is only settable by flag and is never printed itself.
+	PrintConfig bool `yaml:"-"`
+}
+
+type DB struct {
+	DSN      string `yaml:"dsn"`
+	MaxConns int32  `yaml:"max_conns"`
+	MinConns int32  `yaml:"min_conns"`
+}
+
+type Auth struct {
+	TokenTTL   time.Duration `yaml:"token_ttl"`
+	BcryptCost int           `yaml:"bcrypt_cost"`
+}
+
+type HTTP struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes"`
+}
+
+type Receipt struct {
+	Seller     string `yaml:"seller"`
+	TaxPercent int    `yaml:"tax_percent"`
+}
+
+type Payments struct {
+	Provider      string `yaml:"provider"`
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// Loyalty holds the redemption and expiry settings of the loyalty program;
+// earn rules and tiers are managed through the API.
+type Loyalty struct {
+	PointValue       int           `yaml:"point_value"`
+	Expiry           time.Duration `yaml:"expiry"`
+	MaxRedeemPercent int           `yaml:"max_redeem_percent"`
+}
+
+func Default() Config {
+	return Config{
+		Host:    "0.0.0.0",
+		Port:    9999,
+		Storage: "postgres",
+		Migrate: true,
+		DB: DB{
+			MaxConns: 10,
+			MinConns: 0,
+		},
+		Auth: Auth{
+			TokenTTL:   time.Hour,
+			BcryptCost: bcrypt.DefaultCost,
+		},
+		HTTP: HTTP{
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+		},
+		Payments: Payments{
+			Provider: "fake",
+		},
+		Loyalty: Loyalty{
+			PointValue:       1,
+			Expiry:           365 * 24 * time.Hour,
+			MaxRedeemPercent: 50,
+		},
+	}
+}
+
+// Load builds the config from defaults, then the YAML file given by -config
+// (or CRUD_CONFIG), then CRUD_* environment variables, then flags, each
+// overriding the previous one. It returns the arguments left after flags.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var file string
+	fs.StringVar(&file, "config", "", "path to YAML config file")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print effective config with secrets redacted and exit")
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "listen host")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "listen port")
+	fs.StringVar(&cfg.Storage, "storage", cfg.Storage, "storage backend: postgres or memory")
+	fs.BoolVar(&cfg.Migrate, "migrate", cfg.Migrate, "apply pending migrations on startup (postgres storage only)")
+	fs.StringVar(&cfg.DB.DSN, "db-dsn", cfg.DB.DSN, "postgres connection string")
+	fs.Var((*int32Value)(&cfg.DB.MaxConns), "db-max-conns", "maximum pool size")
+	fs.Var((*int32Value)(&cfg.DB.MinConns), "db-min-conns", "minimum pool size")
+	fs.DurationVar(&cfg.Auth.TokenTTL, "auth-token-ttl", cfg.Auth.TokenTTL, "lifetime of issued tokens")
+	fs.IntVar(&cfg.Auth.BcryptCost, "auth-bcrypt-cost", cfg.Auth.BcryptCost, "bcrypt cost for new passwords")
+	fs.DurationVar(&cfg.HTTP.ReadTimeout, "http-read-timeout", cfg.HTTP.ReadTimeout, "maximum duration for reading a request")
+	fs.DurationVar(&cfg.HTTP.ReadHeaderTimeout, "http-read-header-timeout", cfg.HTTP.ReadHeaderTimeout, "maximum duration for reading request headers")
+	fs.DurationVar(&cfg.HTTP.WriteTimeout, "http-write-timeout", cfg.HTTP.WriteTimeout, "maximum duration for writing a response")
+	fs.DurationVar(&cfg.HTTP.IdleTimeout, "http-idle-timeout", cfg.HTTP.IdleTimeout, "maximum keep-alive idle time")
+	fs.DurationVar(&cfg.HTTP.ShutdownTimeout, "http-shutdown-timeout", cfg.HTTP.ShutdownTimeout, "how long to wait for in-flight requests on shutdown")
+	fs.IntVar(&cfg.HTTP.MaxHeaderBytes, "http-max-header-bytes", cfg.HTTP.MaxHeaderBytes, "maximum size of request headers")
+	fs.Int64Var(&cfg.HTTP.MaxBodyBytes, "http-max-body-bytes", cfg.HTTP.MaxBodyBytes, "maximum size of request bodies")
+	fs.StringVar(&cfg.Receipt.Seller, "receipt-seller", cfg.Receipt.Seller, "shop name printed on receipts")
+	fs.IntVar(&cfg.Receipt.TaxPercent, "receipt-tax-percent", cfg.Receipt.TaxPercent, "VAT percent included in prices, shown on receipts")
+	fs.StringVar(&cfg.Payments.Provider, "payments-provider", cfg.Payments.Provider, "card payment gateway: fake")
+	fs.StringVar(&cfg.Payments.WebhookSecret, "payments-webhook-secret", cfg.Payments.WebhookSecret, "secret the gateway signs webhooks with; none are accepted without it")
+	fs.IntVar(&cfg.Loyalty.PointValue, "loyalty-point-value", cfg.Loyalty.PointValue, "currency units a redeemed loyalty point is worth")
+	fs.DurationVar(&cfg.Loyalty.Expiry, "loyalty-expiry", cfg.Loyalty.Expiry, "how long earned loyalty points last, 0 for ever")
+	fs.IntVar(&cfg.Loyalty.MaxRedeemPercent, "loyalty-max-redeem-percent", cfg.Loyalty.MaxRedeemPercent, "percent of a price that can be paid with loyalty points")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %[1]s [flags]\n  %[1]s [flags] migrate up|down [steps]|baseline version|status\n\n", name)
+		fmt.Fprintf(fs.Output(), "Every flag can also be set with the %sFLAG_NAME environment variable.\n\nFlags:\n", envPrefix)
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if _, ok := explicit["config"]; !ok {
+		file, _ = lookupEnv(envPrefix + "CONFIG")
+	}
+	cfg = Default()
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = yaml.UnmarshalStrict(data, &cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		value, ok := explicit[f.Name]
+		if !ok {
+			value, ok = lookupEnv(envName(f.Name))
+		}
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.Name, err))
+		}
+	})
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalid, strings.Join(errs, "; "))
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cfg, fs.Args(), nil
+}
+
+func (c *Config) Validate() error {
+	var errs []string
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, "port must be between 1 and 65535")
+	}
+	switch c.Storage {
+	case "postgres":
+		if c.DB.DSN == "" {
+			errs = append(errs, "db-dsn is required for postgres storage")
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Sprintf("unknown storage %q", c.Storage))
+	}
+	if c.DB.MaxConns < 1 {
+		errs = append(errs, "db-max-conns must be positive")
+	}
+	if c.DB.MinConns < 0 || c.DB.MinConns > c.DB.MaxConns {
+		errs = append(errs, "db-min-conns must be between 0 and db-max-conns")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		errs = append(errs, "auth-token-ttl must be positive")
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Sprintf("auth-bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if c.HTTP.ReadTimeout < 0 || c.HTTP.ReadHeaderTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.IdleTimeout < 0 {
+		errs = append(errs, "http timeouts must not be negative")
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		errs = append(errs, "http-shutdown-timeout must be positive")
+	}
+	if c.HTTP.MaxHeaderBytes <= 0 || c.HTTP.MaxBodyBytes <= 0 {
+		errs = append(errs, "http size limits must be positive")
+	}
+	if c.Receipt.TaxPercent < 0 || c.Receipt.TaxPercent > 100 {
+		errs = append(errs, "receipt-tax-percent must be between 0 and 100")
+	}
+	switch c.Payments.Provider {
+	case "fake":
+	default:
+		errs = append(errs, fmt.Sprintf("unknown payments provider %q", c.Payments.Provider))
+	}
+	if c.Loyalty.PointValue < 1 {
+		errs = append(errs, "loyalty-point-value must be positive")
+	}
+	if c.Loyalty.Expiry < 0 {
+		errs = append(errs, "loyalty-expiry must not be negative")
+	}
+	if c.Loyalty.MaxRedeemPercent < 0 || c.Loyalty.MaxRedeemPercent > 100 {
+		errs = append(errs, "loyalty-max-redeem-percent must be between 0 and 100")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Addr returns the host:port the server listens on.
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// Redacted returns the config as YAML with passwords and secrets masked.
+func (c *Config) Redacted() (string, error) {
+	redacted := *c
+	redacted.DB.DSN = redactDSN(c.DB.DSN)
+	if c.Payments.WebhookSecret != "" {
+		redacted.Payments.WebhookSecret = "xxxxx"
+	}
+	data, err := yaml.Marshal(redacted)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+var passwordParam = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		query := u.Query()
+		if query.Get("password") != "" {
+			query.Set("password", "xxxxx")
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+	return passwordParam.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+type int32Value int32
+
+func (v *int32Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return err
+	}
+	*v = int32Value(n)
+	return nil
+}
+
+func (v *int32Value) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
@@ -0,0 +1,67 @@
+package customers
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/khiki1995/crud/pkg/listing"
+)
+
+var ErrCategoryNotFound = errors.New("no such category")
+
+type Category struct {
+	ID       int64       `json:"id"`
+	Name     string      `json:"name"`
+	Parent   *int64      `json:"-"`
+	Children []*Category `json:"children"`
+}
+
+// Categories returns the category tree: root categories with their
+// subcategories nested, each level ordered by name.
+func (s *Service) Categories(ctx context.Context) ([]*Category, error) {
+	items, err := s.repo.Categories(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	byID := make(map[int64]*Category, len(items))
+	for _, item := range items {
+		item.Children = make([]*Category, 0)
+		byID[item.ID] = item
+	}
+	roots := make([]*Category, 0)
+	for _, item := range items {
+		if item.Parent == nil {
+			roots = append(roots, item)
+			continue
+		}
+		parent := byID[*item.Parent]
+		parent.Children = append(parent.Children, item)
+	}
+	return roots, nil
+}
+
+// CategoryProducts lists active products of the category and all of its
+// subcategories.
+func (s *Service) CategoryProducts(ctx context.Context, id int64, filter *listing.ProductFilter) (*ProductsPage, error) {
+	items, err := s.repo.Categories(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	found := false
+	for _, item := range items {
+		if item.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrCategoryNotFound
+	}
+
+	filter.CategoryID = &id
+	return s.Products(ctx, filter)
+}
@@ -0,0 +1,51 @@
+package customers
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/receipts"
+)
+
+// Customers stores customer accounts.
+type Customers interface {
+	CreateCustomer(ctx context.Context, reg *Registration) (*Customer, error)
+	CustomerPasswordByPhone(ctx context.Context, phone string) (id int64, hash string, err error)
+}
+
+// Tokens stores customer authentication tokens.
+type Tokens interface {
+	SaveCustomerToken(ctx context.Context, token string, customerID int64, expire time.Time) error
+	CustomerToken(ctx context.Context, token string) (customerID int64, expire time.Time, err error)
+}
+
+// Products gives customers read access to the catalog.
+type Products interface {
+	// Products returns at most filter.Limit+1 products matching filter in
+	// listing order, the extra one telling that there is a next page.
+	Products(ctx context.Context, filter *listing.ProductFilter) ([]*Product, error)
+	SearchProducts(ctx context.Context, search *listing.Search) ([]*ProductMatch, error)
+}
+
+// Categories gives customers read access to the category tree.
+type Categories interface {
+	// Categories returns every category ordered by name.
+	Categories(ctx context.Context) ([]*Category, error)
+}
+
+// Sales gives customers read access to their purchases.
+type Sales interface {
+	Purchases(ctx context.Context, customerID int64) ([]*Purchase, error)
+	// SaleReceipt returns the sale as printed, without totals.
+	SaleReceipt(ctx context.Context, id int64) (*receipts.Receipt, error)
+}
+
+// Repository is the storage customers.Service depends on.
+type Repository interface {
+	Customers
+	Tokens
+	Products
+	Categories
+	Sales
+}
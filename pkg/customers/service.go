@@ -8,8 +8,8 @@ import (
 	"log"
 	"time"
 
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/receipts"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,7 +21,14 @@ var ErrTokenNotFound = errors.New("expired")
 var ErrPasswordInvalid = errors.New("invalid password")
 
 type Service struct {
-	pool *pgxpool.Pool
+	repo Repository
+	opts Options
+}
+
+type Options struct {
+	TokenTTL   time.Duration
+	BcryptCost int
+	Receipt    receipts.Options
 }
 
 type Customer struct {
@@ -52,24 +59,39 @@ type Product struct {
 	Created time.Time `json:"created"`
 }
 
+type ProductsPage struct {
+	Items      []*Product `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ProductMatch is a product found by full-text search; Highlight is the
+// product name with matched words wrapped in <b></b>.
+type ProductMatch struct {
+	*Product
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// Purchase is a sale made to the customer; Status tells how far an order
+// placed at checkout has got.
 type Purchase struct {
+	Sale_id  int64      `json:"sale_id"`
+	Status   string     `json:"status"`
 	Date     time.Time  `json:"date"`
 	Products []*Product `json:"products"`
 }
 
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+func NewService(repo Repository, opts Options) *Service {
+	return &Service{repo: repo, opts: opts}
 }
 
 func (s *Service) GetToken(ctx context.Context, phone string, password string) (token string, err error) {
-	var hash string
-	var id int64
-	err = s.pool.QueryRow(ctx, `SELECT id, password FROM customers WHERE phone = $1`, phone).Scan(&id, &hash)
-
-	if err == pgx.ErrNoRows {
+	id, hash, err := s.repo.CustomerPasswordByPhone(ctx, phone)
+	if err == ErrUserNotFound {
 		return "", ErrUserNotFound
 	}
 	if err != nil {
+		log.Print(err)
 		return "", ErrInternal
 	}
 	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
@@ -84,24 +106,22 @@ func (s *Service) GetToken(ctx context.Context, phone string, password string) (
 	}
 
 	token = hex.EncodeToString(buffer)
-	_, err = s.pool.Exec(ctx, `INSERT INTO customers_tokens(token, customer_id) VALUES($1, $2)`, token, id)
+	err = s.repo.SaveCustomerToken(ctx, token, id, time.Now().Add(s.opts.TokenTTL))
 	if err != nil {
+		log.Print(err)
 		return "", ErrInternal
 	}
 	return token, nil
 }
 
 func (s *Service) IDByToken(ctx context.Context, token string) (id int64, err error) {
-	expireTime := time.Now()
-	err = s.pool.QueryRow(ctx, `
-		SELECT customer_id, expire  FROM customers_tokens WHERE token = $1
-	`, token).Scan(&id, &expireTime)
-
-	if err == pgx.ErrNoRows {
+	id, expireTime, err := s.repo.CustomerToken(ctx, token)
+	if err == ErrTokenNotFound {
 		return 0, nil
 	}
 
 	if err != nil {
+		log.Print(err)
 		return 0, ErrInternal
 	}
 	if time.Now().After(expireTime) {
@@ -111,13 +131,12 @@ func (s *Service) IDByToken(ctx context.Context, token string) (id int64, err er
 }
 
 func (s *Service) AuthentificateCustomer(ctx context.Context, token string) (id int64, err error) {
-	expireTime := time.Now()
-	err = s.pool.QueryRow(ctx, `SELECT customer_id, expire FROM customers_tokens WHERE token = $1`, token).Scan(&id, &expireTime)
-
-	if err == pgx.ErrNoRows {
+	id, expireTime, err := s.repo.CustomerToken(ctx, token)
+	if err == ErrTokenNotFound {
 		return 0, ErrUserNotFound
 	}
 	if err != nil {
+		log.Print(err)
 		return 0, ErrInternal
 	}
 	if time.Now().After(expireTime) {
@@ -127,13 +146,15 @@ func (s *Service) AuthentificateCustomer(ctx context.Context, token string) (id
 }
 
 func (s *Service) Register(ctx context.Context, reg *Registration) (*Customer, error) {
-	item := &Customer{}
-	err := s.pool.QueryRow(ctx, `
-		INSERT INTO customers (name, phone, password) 
-		VALUES ($1, $2, $3) 
-		ON CONFLICT (phone) DO NOTHING 
-		RETURNING id, name, phone, active, created
-	`, reg.Name, reg.Phone, reg.Password).Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
+	hash, err := bcrypt.GenerateFromPassword([]byte(reg.Password), s.opts.BcryptCost)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	item, err := s.repo.CreateCustomer(ctx, &Registration{Name: reg.Name, Phone: reg.Phone, Password: string(hash)})
+	if err == ErrPhoneUsed {
+		return nil, ErrPhoneUsed
+	}
 	if err != nil {
 		log.Print(err)
 		return nil, ErrInternal
@@ -141,79 +162,73 @@ func (s *Service) Register(ctx context.Context, reg *Registration) (*Customer, e
 	return item, nil
 }
 
-func (s *Service) Products(ctx context.Context) ([]*Product, error) {
-	items := make([]*Product, 0)
-	rows, err := s.pool.Query(ctx, `
-	SELECT id, name, price, qty FROM products WHERE active ORDER BY id LIMIT 500
-	`)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return items, nil
-	}
+// Products lists active products only, whatever filter.Active says.
+func (s *Service) Products(ctx context.Context, filter *listing.ProductFilter) (*ProductsPage, error) {
+	active := true
+	filter.Active = &active
+	items, err := s.repo.Products(ctx, filter)
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		item := &Product{}
-		err = rows.Scan(&item.ID, &item.Name, &item.Price, &item.Qty)
-		if err != nil {
-			log.Print(err)
-			return nil, err
-		}
-		items = append(items, item)
+	page := &ProductsPage{Items: items}
+	if len(items) > filter.Limit {
+		page.Items = items[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = (&listing.Cursor{Sort: filter.Sort, Key: productKey(last, filter.Sort), ID: last.ID}).Encode()
 	}
-	err = rows.Err()
+	return page, nil
+}
+
+// SearchProducts searches active products only.
+func (s *Service) SearchProducts(ctx context.Context, search *listing.Search) ([]*ProductMatch, error) {
+	active := true
+	search.Active = &active
+	items, err := s.repo.SearchProducts(ctx, search)
 	if err != nil {
 		log.Print(err)
-		return nil, err
+		return nil, ErrInternal
 	}
 	return items, nil
 }
 
 func (s *Service) Purchases(ctx context.Context, id int64) ([]*Purchase, error) {
-	items := make([]*Purchase, 0)
-	rows, err := s.pool.Query(ctx, `
-		SELECT s.created as Date, sp.product_id as ID, p.name as Name, sp.price as Price, sp.qty as Qty
-		FROM sales s
-		INNER JOIN sales_positions sp ON sp.sale_id = s.id and s.customer_id = $1
-		LEFT JOIN products p ON sp.product_id = p.id
-		GROUP BY s.id, sp.product_id, p.name, sp.price, sp.qty
-		ORDER BY s.created
-	`, id)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return items, nil
-	}
+	items, err := s.repo.Purchases(ctx, id)
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		purchase := &Purchase{}
-		product := &Product{}
-		err = rows.Scan(&purchase.Date, &product.ID, &product.Name, &product.Price, &product.Qty)
-		if err != nil {
-			log.Print(err)
-			return nil, err
-		}
-		found := false
-		for _, p := range items {
-			if p.Date == purchase.Date {
-				p.Products = append(p.Products, product)
-				found = true
-				break
-			}
-		}
-		if !found {
-			purchase.Products = append(purchase.Products, product)
-			items = append(items, purchase)
-		}
-	}
-	err = rows.Err()
+	return items, nil
+}
+
+// Receipt returns the receipt of a sale made to the customer; sales to
+// anyone else are reported as missing.
+func (s *Service) Receipt(ctx context.Context, customerID int64, saleID int64) (*receipts.Receipt, error) {
+	receipt, err := s.repo.SaleReceipt(ctx, saleID)
+	if err == receipts.ErrNotFound {
+		return nil, err
+	}
 	if err != nil {
 		log.Print(err)
-		return nil, err
+		return nil, ErrInternal
+	}
+	if receipt.Customer_id != customerID {
+		return nil, receipts.ErrNotFound
+	}
+	receipt.Complete(s.opts.Receipt)
+	return receipt, nil
+}
+
+// productKey returns the value of the listing sort field of product.
+func productKey(product *Product, sort string) interface{} {
+	switch sort {
+	case "name":
+		return product.Name
+	case "price":
+		return int64(product.Price)
+	case "created":
+		return product.Created
+	default:
+		return product.ID
 	}
-	return items, nil
 }
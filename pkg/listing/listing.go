@@ -0,0 +1,176 @@
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultLimit = 50
+const MaxLimit = 500
+
+var ErrInvalid = errors.New("invalid listing parameters")
+
+// Kind is the type of a sortable field, used to decode cursor keys.
+type Kind int
+
+const (
+	Int Kind = iota
+	String
+	Time
+)
+
+// ProductSorts and CustomerSorts whitelist the fields listings can be sorted by.
+var ProductSorts = map[string]Kind{"id": Int, "name": String, "price": Int, "created": Time}
+var CustomerSorts = map[string]Kind{"id": Int, "name": String, "phone": String, "created": Time}
+
+// Cursor points right after the last item of a page: Key is the value of the
+// sort field of that item (int64, string or time.Time) and ID breaks ties.
+type Cursor struct {
+	Sort string
+	Key  interface{}
+	ID   int64
+}
+
+type cursorJSON struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   int64  `json:"id"`
+}
+
+func (c *Cursor) Encode() string {
+	item := cursorJSON{Sort: c.Sort, ID: c.ID}
+	switch key := c.Key.(type) {
+	case int64:
+		item.Key = strconv.FormatInt(key, 10)
+	case string:
+		item.Key = key
+	case time.Time:
+		item.Key = key.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(item)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by Encode for a listing sorted by sort.
+func DecodeCursor(value string, sort string, kind Kind) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var item cursorJSON
+	err = json.Unmarshal(data, &item)
+	if err != nil || item.Sort != sort {
+		return nil, ErrInvalid
+	}
+
+	cursor := &Cursor{Sort: sort, ID: item.ID}
+	switch kind {
+	case Int:
+		cursor.Key, err = strconv.ParseInt(item.Key, 10, 64)
+	case String:
+		cursor.Key = item.Key
+	case Time:
+		cursor.Key, err = time.Parse(time.RFC3339Nano, item.Key)
+	}
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return cursor, nil
+}
+
+// Compare orders two keys of the same kind, returning -1, 0 or 1.
+func Compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		b := b.(time.Time)
+		if a.Before(b) {
+			return -1
+		}
+		if a.After(b) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Params are common to every listing.
+type Params struct {
+	Sort  string
+	Desc  bool
+	Limit int
+	After *Cursor
+}
+
+// IsAfter reports whether an item with given sort key and id comes after the
+// cursor in the listing order; it is always true without a cursor.
+func (p *Params) IsAfter(key interface{}, id int64) bool {
+	if p.After == nil {
+		return true
+	}
+	cmp := Compare(key, p.After.Key)
+	if cmp == 0 {
+		cmp = compareIDs(id, p.After.ID)
+	}
+	if p.Desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// Less orders two items by sort key then id in the listing direction.
+func (p *Params) Less(keyA interface{}, idA int64, keyB interface{}, idB int64) bool {
+	cmp := Compare(keyA, keyB)
+	if cmp == 0 {
+		cmp = compareIDs(idA, idB)
+	}
+	if p.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+type ProductFilter struct {
+	Params
+	Active      *bool
+	MinPrice    *int
+	MaxPrice    *int
+	Name        string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// CategoryID limits the listing to products of the category or any
+	// of its descendants.
+	CategoryID *int64
+}
+
+type CustomerFilter struct {
+	Params
+	Active      *bool
+	Name        string
+	PhonePrefix string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+func compareIDs(a, b int64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
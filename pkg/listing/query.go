@@ -0,0 +1,156 @@
+package listing
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseProductFilter reads a product listing from query parameters:
+// active, category, min_price, max_price, name, created_from, created_to, sort
+// (a whitelisted field, prefixed with - for descending order), limit and cursor.
+func ParseProductFilter(query url.Values) (*ProductFilter, error) {
+	filter := &ProductFilter{Name: query.Get("name")}
+	var err error
+	filter.Params, err = parseParams(query, ProductSorts)
+	if err != nil {
+		return nil, err
+	}
+	filter.Active, err = parseBool(query, "active")
+	if err != nil {
+		return nil, err
+	}
+	if category := query.Get("category"); category != "" {
+		id, err := strconv.ParseInt(category, 10, 64)
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		filter.CategoryID = &id
+	}
+	filter.MinPrice, err = parseInt(query, "min_price")
+	if err != nil {
+		return nil, err
+	}
+	filter.MaxPrice, err = parseInt(query, "max_price")
+	if err != nil {
+		return nil, err
+	}
+	filter.CreatedFrom, filter.CreatedTo, err = ParseRange(query, "created_from", "created_to")
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// ParseCustomerFilter reads a customer listing from query parameters:
+// active, name, phone (prefix), created_from, created_to, sort, limit and cursor.
+func ParseCustomerFilter(query url.Values) (*CustomerFilter, error) {
+	filter := &CustomerFilter{Name: query.Get("name"), PhonePrefix: query.Get("phone")}
+	var err error
+	filter.Params, err = parseParams(query, CustomerSorts)
+	if err != nil {
+		return nil, err
+	}
+	filter.Active, err = parseBool(query, "active")
+	if err != nil {
+		return nil, err
+	}
+	filter.CreatedFrom, filter.CreatedTo, err = ParseRange(query, "created_from", "created_to")
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func parseParams(query url.Values, sorts map[string]Kind) (Params, error) {
+	params := Params{Sort: "id", Limit: DefaultLimit}
+	if sort := query.Get("sort"); sort != "" {
+		params.Desc = strings.HasPrefix(sort, "-")
+		params.Sort = strings.TrimPrefix(sort, "-")
+	}
+	kind, ok := sorts[params.Sort]
+	if !ok {
+		return Params{}, ErrInvalid
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return Params{}, ErrInvalid
+		}
+		params.Limit = n
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := DecodeCursor(cursor, params.Sort, kind)
+		if err != nil {
+			return Params{}, err
+		}
+		params.After = after
+	}
+	return params, nil
+}
+
+func parseBool(query url.Values, name string) (*bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return &b, nil
+}
+
+func parseInt(query url.Values, name string) (*int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return &n, nil
+}
+
+// ParseRange reads a time range from the fromName and toName parameters,
+// accepting RFC 3339 times or plain dates; a plain to date includes that
+// whole day.
+func ParseRange(query url.Values, fromName string, toName string) (from *time.Time, to *time.Time, err error) {
+	return ParseRangeIn(query, fromName, toName, time.UTC)
+}
+
+// ParseRangeIn is ParseRange with plain dates taken in loc.
+func ParseRangeIn(query url.Values, fromName string, toName string, loc *time.Location) (from *time.Time, to *time.Time, err error) {
+	if value := query.Get(fromName); value != "" {
+		t, _, err := parseTime(value, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		from = &t
+	}
+	if value := query.Get(toName); value != "" {
+		t, date, err := parseTime(value, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if date {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		to = &t
+	}
+	return from, to, nil
+}
+
+func parseTime(value string, loc *time.Location) (t time.Time, date bool, err error) {
+	t, err = time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t.UTC(), false, nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", value, loc)
+	if err == nil {
+		return t.UTC(), true, nil
+	}
+	return time.Time{}, false, ErrInvalid
+}
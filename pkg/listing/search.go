@@ -0,0 +1,80 @@
+package listing
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxTerms bounds the number of words a search query is split into.
+const maxTerms = 10
+
+// Search is a full-text product search: every term must be a prefix of some
+// word of the product name. Results are ordered by rank, so paging is by
+// offset rather than by cursor.
+type Search struct {
+	Terms  []string
+	Active *bool
+	Limit  int
+	Offset int
+}
+
+// ParseSearch reads q, active, limit and offset query parameters.
+func ParseSearch(query url.Values) (*Search, error) {
+	search := &Search{Terms: Terms(query.Get("q")), Limit: DefaultLimit}
+	if len(search.Terms) == 0 {
+		return nil, ErrInvalid
+	}
+	var err error
+	search.Active, err = parseBool(query, "active")
+	if err != nil {
+		return nil, err
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return nil, ErrInvalid
+		}
+		search.Limit = n
+	}
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, ErrInvalid
+		}
+		search.Offset = n
+	}
+	return search, nil
+}
+
+// Terms splits text into lower-cased words made of letters and digits,
+// dropping duplicates; it is also how product names are tokenized.
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// TSQuery renders the terms as a prefix-matching to_tsquery expression.
+// Terms only hold letters and digits, so no further escaping is needed.
+func (s *Search) TSQuery() string {
+	parts := make([]string, len(s.Terms))
+	for i, term := range s.Terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
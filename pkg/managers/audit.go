@@ -0,0 +1,170 @@
+package managers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/requestid"
+)
+
+// Audited actions, named entity.verb.
+const (
+	ActionProductCreate      = "product.create"
+	ActionProductUpdate      = "product.update"
+	ActionProductArchive     = "product.archive"
+	ActionProductRestore     = "product.restore"
+	ActionProductCategories  = "product.categories"
+	ActionStockMovement      = "stock.movement"
+	ActionCategoryCreate     = "category.create"
+	ActionCategoryUpdate     = "category.update"
+	ActionCategoryDelete     = "category.delete"
+	ActionCustomerUpdate     = "customer.update"
+	ActionCustomerArchive    = "customer.archive"
+	ActionCustomerRestore    = "customer.restore"
+	ActionSaleCreate         = "sale.create"
+	ActionSaleStatus         = "sale.status"
+	ActionPaymentCreate      = "payment.create"
+	ActionPaymentCapture     = "payment.capture"
+	ActionPaymentRefund      = "payment.refund"
+	ActionReturnCreate       = "return.create"
+	ActionWalletTopUp        = "wallet.top_up"
+	ActionManagerRegister    = "manager.register"
+	ActionManagerRolesChange = "manager.roles"
+	ActionManagerTeamChange  = "manager.team"
+	ActionPlanSet            = "plan.set"
+	ActionCommissionCreate   = "commission.create"
+	ActionCommissionDelete   = "commission.delete"
+	ActionLoyaltyRuleCreate  = "loyalty_rule.create"
+	ActionLoyaltyRuleDelete  = "loyalty_rule.delete"
+	ActionPromoCreate        = "promo.create"
+	ActionPromoArchive       = "promo.archive"
+	ActionPromoRestore       = "promo.restore"
+	ActionPayrollFinalize    = "payroll.finalize"
+)
+
+// Audited entities.
+const (
+	EntityProduct        = "product"
+	EntityCategory       = "category"
+	EntityCustomer       = "customer"
+	EntitySale           = "sale"
+	EntityReturn         = "return"
+	EntityPayment        = "payment"
+	EntityWallet         = "wallet"
+	EntityManager        = "manager"
+	EntityPlan           = "plan"
+	EntityCommissionRule = "commission_rule"
+	EntityLoyaltyRule    = "loyalty_rule"
+	EntityPromo          = "promo"
+	EntityPayroll        = "payroll"
+)
+
+// AuditEntry records a change made by a manager. Before and After hold only
+// the fields that changed; one of them is null for creations and deletions.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Manager_id int64           `json:"manager_id"`
+	Action     string          `json:"action"`
+	Entity     string          `json:"entity"`
+	Entity_id  int64           `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	Request_id string          `json:"request_id"`
+	Created    time.Time       `json:"created"`
+}
+
+// AuditFilter selects audit entries; zero fields do not filter.
+type AuditFilter struct {
+	ManagerID int64
+	Entity    string
+	EntityID  int64
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+func (s *Service) GetAuditEntries(ctx context.Context, filter *AuditFilter) ([]*AuditEntry, error) {
+	items, err := s.repo.AuditEntries(ctx, filter)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// audit appends an entry for the change of an entity from before to after,
+// either of which may be nil; it must run in the transaction of the change.
+func audit(ctx context.Context, repo Repository, managerID int64, action string, entity string, entityID int64,
+	before interface{}, after interface{}) error {
+	entry := &AuditEntry{
+		Manager_id: managerID,
+		Action:     action,
+		Entity:     entity,
+		Entity_id:  entityID,
+		Request_id: requestid.FromContext(ctx),
+	}
+	var err error
+	entry.Before, entry.After, err = diff(before, after)
+	if err != nil {
+		return err
+	}
+	return repo.CreateAuditEntry(ctx, entry)
+}
+
+// diff marshals before and after to JSON objects and keeps only the fields
+// whose values differ.
+func diff(before interface{}, after interface{}) (json.RawMessage, json.RawMessage, error) {
+	a, err := toFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := toFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a != nil && b != nil {
+		for key, value := range a {
+			if other, ok := b[key]; ok && reflect.DeepEqual(value, other) {
+				delete(a, key)
+				delete(b, key)
+			}
+		}
+	}
+	beforeJSON, err := fromFields(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := fromFields(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func toFields(value interface{}) (map[string]interface{}, error) {
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "password")
+	return fields, nil
+}
+
+func fromFields(fields map[string]interface{}) (json.RawMessage, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	return json.Marshal(fields)
+}
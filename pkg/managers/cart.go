@@ -0,0 +1,172 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/khiki1995/crud/pkg/customers"
+)
+
+var ErrEmptyCart = errors.New("cart is empty")
+
+// CartItem is a product in a customer's cart shown with its current name,
+// price and stock; the price is only fixed at checkout.
+type CartItem struct {
+	Product_id int64  `json:"product_id"`
+	Name       string `json:"name"`
+	Price      int    `json:"price"`
+	Qty        int    `json:"qty"`
+	Amount     int    `json:"amount"`
+	Available  int    `json:"available"`
+	Active     bool   `json:"active"`
+}
+
+type Cart struct {
+	Customer_id int64       `json:"customer_id"`
+	Items       []*CartItem `json:"items"`
+	Total       int         `json:"total"`
+}
+
+func (s *Service) GetCart(ctx context.Context, customerID int64) (*Cart, error) {
+	cart, err := loadCart(ctx, s.repo, customerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return cart, nil
+}
+
+// AddToCart puts qty more of the product into the cart of the customer.
+func (s *Service) AddToCart(ctx context.Context, customerID int64, productID int64, qty int) (*Cart, error) {
+	if qty <= 0 {
+		return nil, ErrInvalidQty
+	}
+	return s.changeCart(ctx, customerID, productID, func(current int) int {
+		return current + qty
+	})
+}
+
+// SetCartQty sets the quantity of the product in the cart; 0 removes it.
+func (s *Service) SetCartQty(ctx context.Context, customerID int64, productID int64, qty int) (*Cart, error) {
+	if qty < 0 {
+		return nil, ErrInvalidQty
+	}
+	return s.changeCart(ctx, customerID, productID, func(int) int {
+		return qty
+	})
+}
+
+func (s *Service) RemoveFromCart(ctx context.Context, customerID int64, productID int64) (*Cart, error) {
+	return s.SetCartQty(ctx, customerID, productID, 0)
+}
+
+func (s *Service) ClearCart(ctx context.Context, customerID int64) (*Cart, error) {
+	err := s.repo.ClearCart(ctx, customerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return &Cart{Customer_id: customerID, Items: make([]*CartItem, 0)}, nil
+}
+
+// changeCart sets the quantity of the product in the cart to qty of the
+// current one. Only active products can be added, while any product can be
+// removed; stock is not checked until checkout.
+func (s *Service) changeCart(ctx context.Context, customerID int64, productID int64, qty func(current int) int) (*Cart, error) {
+	var cart *Cart
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		items, err := repo.CartItems(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		current := 0
+		for _, item := range items {
+			if item.Product_id == productID {
+				current = item.Qty
+			}
+		}
+
+		next := qty(current)
+		if next == 0 {
+			err = repo.DeleteCartItem(ctx, customerID, productID)
+		} else {
+			if next > current {
+				products, err := repo.LockProducts(ctx, []int64{productID})
+				if err != nil {
+					return err
+				}
+				if len(products) == 0 {
+					return ErrProductNotFound
+				}
+				if !products[0].Active {
+					return ErrProductInactive
+				}
+			}
+			err = repo.SetCartItem(ctx, customerID, productID, next)
+		}
+		if err != nil {
+			return err
+		}
+		cart, err = loadCart(ctx, repo, customerID)
+		return err
+	})
+	switch err {
+	case nil:
+		return cart, nil
+	case ErrProductNotFound, ErrProductInactive:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// Checkout turns the cart of the customer into a confirmed order at the
+// current prices less the promo code, if any, with the same checks as
+// MakeSale, reserving the stock, and empties the cart. The order has no
+// manager; its receipt number confirms it.
+func (s *Service) Checkout(ctx context.Context, customerID int64, promo string) (*Sale, error) {
+	sale := &Sale{Customer_id: customerID, Status: SaleConfirmed, Promo: promoCode(promo)}
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		items, err := repo.CartItems(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return ErrEmptyCart
+		}
+		for _, item := range items {
+			sale.Positions = append(sale.Positions, &SalePosition{Product_id: item.Product_id, Qty: item.Qty})
+		}
+		err = s.placeSale(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+		return repo.ClearCart(ctx, customerID)
+	})
+	switch err {
+	case nil:
+		return sale, nil
+	case ErrEmptyCart, ErrProductNotFound, ErrProductInactive, ErrInsufficientStock,
+		customers.ErrUserNotFound, ErrCustomerInactive, ErrPromoNotFound, ErrPromoNotActive, ErrPromoExhausted,
+		ErrPromoMinBasket, ErrPromoNotApplicable:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func loadCart(ctx context.Context, repo Repository, customerID int64) (*Cart, error) {
+	items, err := repo.CartItems(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	cart := &Cart{Customer_id: customerID, Items: items}
+	for _, item := range items {
+		item.Amount = item.Price * item.Qty
+		cart.Total += item.Amount
+	}
+	return cart, nil
+}
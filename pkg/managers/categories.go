@@ -0,0 +1,218 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var ErrCategoryNotFound = errors.New("no such category")
+var ErrInvalidCategory = errors.New("invalid category")
+var ErrCategoryCycle = errors.New("category cannot be its own ancestor")
+var ErrCategoryHasChildren = errors.New("category has subcategories")
+var ErrCategoryInUse = errors.New("category is used by promo codes or rules")
+
+type Category struct {
+	ID        int64     `json:"id"`
+	Parent_id *int64    `json:"parent_id"`
+	Name      string    `json:"name"`
+	Created   time.Time `json:"created"`
+}
+
+// SaveCategory creates a category when its id is 0 and updates it otherwise,
+// refusing to move a category under itself or one of its descendants.
+func (s *Service) SaveCategory(ctx context.Context, managerID int64, category *Category) (*Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return nil, ErrInvalidCategory
+	}
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		items, err := repo.Categories(ctx)
+		if err != nil {
+			return err
+		}
+		parents := make(map[int64]*int64)
+		var before *Category
+		for _, item := range items {
+			parents[item.ID] = item.Parent_id
+			if item.ID == category.ID {
+				before = item
+			}
+		}
+		if category.ID != 0 && before == nil {
+			return ErrCategoryNotFound
+		}
+		for parent := category.Parent_id; parent != nil; parent = parents[*parent] {
+			if _, ok := parents[*parent]; !ok {
+				return ErrCategoryNotFound
+			}
+			if *parent == category.ID {
+				return ErrCategoryCycle
+			}
+		}
+
+		if category.ID == 0 {
+			err = repo.CreateCategory(ctx, category)
+			if err != nil {
+				return err
+			}
+			return audit(ctx, repo, managerID, ActionCategoryCreate, EntityCategory, category.ID, nil, category)
+		}
+		err = repo.UpdateCategory(ctx, category)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, managerID, ActionCategoryUpdate, EntityCategory, category.ID, before, category)
+	})
+	switch err {
+	case nil:
+		return category, nil
+	case ErrCategoryNotFound, ErrCategoryCycle:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func (s *Service) GetCategories(ctx context.Context) ([]*Category, error) {
+	items, err := s.repo.Categories(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// RemoveCategoryByID deletes an empty-of-subcategories category no promo
+// code, commission rule or loyalty rule is scoped to; its products stay in
+// the catalog and just lose the assignment.
+func (s *Service) RemoveCategoryByID(ctx context.Context, managerID int64, id int64) (*Category, error) {
+	var category *Category
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		items, err := repo.Categories(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if item.Parent_id != nil && *item.Parent_id == id {
+				return ErrCategoryHasChildren
+			}
+		}
+		promos, err := repo.Promos(ctx)
+		if err != nil {
+			return err
+		}
+		for _, promo := range promos {
+			if promo.Category_id != nil && *promo.Category_id == id {
+				return ErrCategoryInUse
+			}
+		}
+		rules, err := repo.CommissionRules(ctx)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			if rule.Category_id != nil && *rule.Category_id == id {
+				return ErrCategoryInUse
+			}
+		}
+		loyaltyRules, err := repo.LoyaltyRules(ctx)
+		if err != nil {
+			return err
+		}
+		for _, rule := range loyaltyRules {
+			if rule.Category_id != nil && *rule.Category_id == id {
+				return ErrCategoryInUse
+			}
+		}
+		category, err = repo.DeleteCategory(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, managerID, ActionCategoryDelete, EntityCategory, id, category, nil)
+	})
+	switch err {
+	case nil:
+		return category, nil
+	case ErrCategoryNotFound, ErrCategoryHasChildren, ErrCategoryInUse:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// SetProductCategories replaces the categories the product is assigned to.
+func (s *Service) SetProductCategories(ctx context.Context, managerID int64, productID int64, categoryIDs []int64) ([]*Category, error) {
+	var assigned []*Category
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		items, err := repo.Categories(ctx)
+		if err != nil {
+			return err
+		}
+		known := make(map[int64]bool)
+		for _, item := range items {
+			known[item.ID] = true
+		}
+		ids := make([]int64, 0, len(categoryIDs))
+		seen := make(map[int64]bool)
+		for _, id := range categoryIDs {
+			if !known[id] {
+				return ErrCategoryNotFound
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		before, err := repo.ProductCategories(ctx, productID)
+		if err != nil {
+			return err
+		}
+		err = repo.SetProductCategories(ctx, productID, ids)
+		if err != nil {
+			return err
+		}
+		assigned, err = repo.ProductCategories(ctx, productID)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, managerID, ActionProductCategories, EntityProduct, productID,
+			map[string]interface{}{"category_ids": categoryIDsOf(before)},
+			map[string]interface{}{"category_ids": categoryIDsOf(assigned)})
+	})
+	switch err {
+	case nil:
+		return assigned, nil
+	case ErrCategoryNotFound, ErrProductNotFound:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func (s *Service) GetProductCategories(ctx context.Context, productID int64) ([]*Category, error) {
+	items, err := s.repo.ProductCategories(ctx, productID)
+	if err == ErrProductNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+func categoryIDsOf(items []*Category) []int64 {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
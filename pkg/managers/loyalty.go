@@ -0,0 +1,457 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+)
+
+var ErrInvalidLoyaltyRule = errors.New("invalid loyalty rule")
+var ErrLoyaltyRuleNotFound = errors.New("no such loyalty rule")
+var ErrInsufficientPoints = errors.New("insufficient loyalty points")
+
+// Kinds of loyalty rules. Customers earn the highest earn rate on what they
+// pay for a sale, multiplied by the highest category multiplier matching the
+// product or any ancestor category, and by the multiplier of the highest
+// tier their spend over the last year reaches.
+const (
+	LoyaltyEarn     = "earn"
+	LoyaltyCategory = "category"
+	LoyaltyTier     = "tier"
+)
+
+// Kinds of points transactions. Refunded points are the redeemed points
+// given back for returned items; they are spent and expire like earned ones.
+const (
+	PointsEarned   = "earn"
+	PointsRedeemed = "redeem"
+	PointsRevoked  = "revoke"
+	PointsRefunded = "refund"
+	PointsExpired  = "expire"
+)
+
+// tierYear is the period whose spend decides the tier of a customer.
+const tierYear = 365 * 24 * time.Hour
+
+// LoyaltyOptions are the redemption and expiry settings of the program.
+type LoyaltyOptions struct {
+	// PointValue is what a redeemed point takes off a price.
+	PointValue int
+	// Expiry is how long earned points last; 0 keeps them for ever.
+	Expiry time.Duration
+	// MaxRedeemPercent limits the part of a price paid with points.
+	MaxRedeemPercent int
+}
+
+// LoyaltyRule earns Rate_bp basis points of a point per currency unit paid
+// for earn rules. Category and tier rules multiply the points by Multiplier
+// percent: category rules for products in Category_id, tier rules for
+// customers who spent at least Min_spend over the last year.
+type LoyaltyRule struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	Rate_bp     int       `json:"rate_bp"`
+	Multiplier  int       `json:"multiplier"`
+	Category_id *int64    `json:"category_id"`
+	Tier        string    `json:"tier"`
+	Min_spend   int       `json:"min_spend"`
+	Created     time.Time `json:"created"`
+}
+
+// PointsTransaction changes the points balance of a customer by Points,
+// which is negative for redemption, revocation and expiry. Earned and
+// refunded points are spent oldest first: Remaining is what is left of them
+// and Expires when the rest is written off.
+type PointsTransaction struct {
+	ID          int64      `json:"id"`
+	Customer_id int64      `json:"customer_id"`
+	Kind        string     `json:"kind"`
+	Points      int        `json:"points"`
+	Balance     int        `json:"balance"`
+	Remaining   int        `json:"remaining"`
+	Sale_id     *int64     `json:"sale_id"`
+	Return_id   *int64     `json:"return_id"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	Created     time.Time  `json:"created"`
+}
+
+// LoyaltyAccount is the points account of a customer with their tier and
+// history, newest first.
+type LoyaltyAccount struct {
+	Customer_id  int64                `json:"customer_id"`
+	Balance      int                  `json:"balance"`
+	Tier         string               `json:"tier"`
+	Annual_spend int                  `json:"annual_spend"`
+	Point_value  int                  `json:"point_value"`
+	History      []*PointsTransaction `json:"history"`
+}
+
+func (s *Service) GetLoyaltyRules(ctx context.Context) ([]*LoyaltyRule, error) {
+	items, err := s.repo.LoyaltyRules(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+func (s *Service) CreateLoyaltyRule(ctx context.Context, adminID int64, rule *LoyaltyRule) (*LoyaltyRule, error) {
+	switch rule.Kind {
+	case LoyaltyEarn:
+		if rule.Rate_bp <= 0 {
+			return nil, ErrInvalidLoyaltyRule
+		}
+		rule.Multiplier, rule.Category_id, rule.Tier, rule.Min_spend = 0, nil, "", 0
+	case LoyaltyCategory:
+		if rule.Multiplier < 0 || rule.Category_id == nil {
+			return nil, ErrInvalidLoyaltyRule
+		}
+		rule.Rate_bp, rule.Tier, rule.Min_spend = 0, "", 0
+	case LoyaltyTier:
+		if rule.Multiplier < 0 || rule.Tier == "" || rule.Min_spend <= 0 {
+			return nil, ErrInvalidLoyaltyRule
+		}
+		rule.Rate_bp, rule.Category_id = 0, nil
+	default:
+		return nil, ErrInvalidLoyaltyRule
+	}
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		if rule.Category_id != nil {
+			categories, err := repo.Categories(ctx)
+			if err != nil {
+				return err
+			}
+			if _, ok := categoryParents(categories)[*rule.Category_id]; !ok {
+				return ErrCategoryNotFound
+			}
+		}
+		err := repo.CreateLoyaltyRule(ctx, rule)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionLoyaltyRuleCreate, EntityLoyaltyRule, rule.ID, nil, rule)
+	})
+	switch err {
+	case nil:
+		return rule, nil
+	case ErrCategoryNotFound:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// RemoveLoyaltyRule deletes the rule; points already earned are kept.
+func (s *Service) RemoveLoyaltyRule(ctx context.Context, adminID int64, id int64) (*LoyaltyRule, error) {
+	var rule *LoyaltyRule
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		var err error
+		rule, err = repo.DeleteLoyaltyRule(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionLoyaltyRuleDelete, EntityLoyaltyRule, id, rule, nil)
+	})
+	switch err {
+	case nil:
+		return rule, nil
+	case ErrLoyaltyRuleNotFound:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// GetLoyalty returns the points account of the customer after writing off
+// the points that have expired.
+func (s *Service) GetLoyalty(ctx context.Context, customerID int64) (*LoyaltyAccount, error) {
+	loyalty := &LoyaltyAccount{Customer_id: customerID, Point_value: s.opts.Loyalty.PointValue}
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		err := s.expirePoints(ctx, repo, customerID)
+		if err != nil {
+			return err
+		}
+		loyalty.History, err = repo.PointsTransactions(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		rules, err := repo.LoyaltyRules(ctx)
+		if err != nil {
+			return err
+		}
+		loyalty.Annual_spend, err = repo.CustomerSpend(ctx, customerID, time.Now().Add(-tierYear))
+		if err != nil {
+			return err
+		}
+		if tier := loyaltyTier(rules, loyalty.Annual_spend); tier != nil {
+			loyalty.Tier = tier.Tier
+		}
+		return nil
+	})
+	if err == customers.ErrUserNotFound {
+		return nil, customers.ErrUserNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	if len(loyalty.History) > 0 {
+		loyalty.Balance = loyalty.History[0].Balance
+	}
+	for _, item := range loyalty.History {
+		if (item.Kind == PointsEarned || item.Kind == PointsRefunded) && s.opts.Loyalty.Expiry > 0 {
+			expires := item.Created.Add(s.opts.Loyalty.Expiry)
+			item.Expires = &expires
+		}
+	}
+	return loyalty, nil
+}
+
+// priceWithPoints takes up to sale.Points points off the unit prices of the
+// priced positions, within MaxRedeemPercent of every price, and leaves in
+// sale.Points the points actually used.
+func (s *Service) priceWithPoints(ctx context.Context, repo Repository, sale *Sale) error {
+	err := s.expirePoints(ctx, repo, sale.Customer_id)
+	if err != nil {
+		return err
+	}
+	balance, err := repo.LockPoints(ctx, sale.Customer_id)
+	if err != nil {
+		return err
+	}
+	if balance < sale.Points {
+		return ErrInsufficientPoints
+	}
+
+	value := s.opts.Loyalty.PointValue
+	left := sale.Points
+	for _, v := range sale.Positions {
+		perUnit := left / v.Qty
+		if limit := v.Price * s.opts.Loyalty.MaxRedeemPercent / 100 / value; perUnit > limit {
+			perUnit = limit
+		}
+		v.Points_discount = perUnit * value
+		v.Price -= v.Points_discount
+		left -= perUnit * v.Qty
+	}
+	sale.Points -= left
+	return nil
+}
+
+// expirePoints writes off what is left of the points of the customer earned
+// longer than Expiry ago.
+func (s *Service) expirePoints(ctx context.Context, repo Repository, customerID int64) error {
+	if s.opts.Loyalty.Expiry == 0 {
+		return nil
+	}
+	balance, err := repo.LockPoints(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	lots, err := repo.PointLots(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(-s.opts.Loyalty.Expiry)
+	for _, lot := range lots {
+		if lot.Created.After(deadline) {
+			break
+		}
+		err = repo.SetLotRemaining(ctx, lot.ID, 0)
+		if err != nil {
+			return err
+		}
+		balance -= lot.Remaining
+		err = repo.CreatePointsTransaction(ctx, &PointsTransaction{
+			Customer_id: customerID,
+			Kind:        PointsExpired,
+			Points:      -lot.Remaining,
+			Balance:     balance,
+			Sale_id:     lot.Sale_id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// earnPoints credits the customer of a fulfilled sale with the points its
+// positions earn under the current rules.
+func earnPoints(ctx context.Context, repo Repository, sale *Sale) error {
+	rules, err := repo.LoyaltyRules(ctx)
+	if err != nil {
+		return err
+	}
+	rate := 0
+	for _, rule := range rules {
+		if rule.Kind == LoyaltyEarn && rule.Rate_bp > rate {
+			rate = rule.Rate_bp
+		}
+	}
+	if rate == 0 {
+		return nil
+	}
+	categories, err := repo.Categories(ctx)
+	if err != nil {
+		return err
+	}
+	parents := categoryParents(categories)
+
+	points := 0
+	for _, v := range sale.Positions {
+		ancestors, err := productAncestors(ctx, repo, parents, v.Product_id)
+		if err != nil {
+			return err
+		}
+		var category *LoyaltyRule
+		for _, rule := range rules {
+			if rule.Kind == LoyaltyCategory && containsID(ancestors, *rule.Category_id) &&
+				(category == nil || rule.Multiplier > category.Multiplier) {
+				category = rule
+			}
+		}
+		multiplier := 100
+		if category != nil {
+			multiplier = category.Multiplier
+		}
+		points += v.Price * v.Qty * rate * multiplier / 1000000
+	}
+	spend, err := repo.CustomerSpend(ctx, sale.Customer_id, time.Now().Add(-tierYear))
+	if err != nil {
+		return err
+	}
+	if tier := loyaltyTier(rules, spend); tier != nil {
+		points = points * tier.Multiplier / 100
+	}
+	if points == 0 {
+		return nil
+	}
+
+	balance, err := repo.LockPoints(ctx, sale.Customer_id)
+	if err != nil {
+		return err
+	}
+	return repo.CreatePointsTransaction(ctx, &PointsTransaction{
+		Customer_id: sale.Customer_id,
+		Kind:        PointsEarned,
+		Points:      points,
+		Balance:     balance + points,
+		Remaining:   points,
+		Sale_id:     &sale.ID,
+	})
+}
+
+// spendPoints takes the points of the transaction off the balance of the
+// customer, oldest earned points first.
+func spendPoints(ctx context.Context, repo Repository, transaction *PointsTransaction) error {
+	balance, err := repo.LockPoints(ctx, transaction.Customer_id)
+	if err != nil {
+		return err
+	}
+	if balance+transaction.Points < 0 {
+		return ErrInsufficientPoints
+	}
+	lots, err := repo.PointLots(ctx, transaction.Customer_id)
+	if err != nil {
+		return err
+	}
+	need := -transaction.Points
+	for _, lot := range lots {
+		if need == 0 {
+			break
+		}
+		used := lot.Remaining
+		if used > need {
+			used = need
+		}
+		err = repo.SetLotRemaining(ctx, lot.ID, lot.Remaining-used)
+		if err != nil {
+			return err
+		}
+		need -= used
+	}
+	transaction.Balance = balance + transaction.Points
+	return repo.CreatePointsTransaction(ctx, transaction)
+}
+
+// revokePoints takes back the points the sale earned in proportion to the
+// refund of the return, as far as the customer still has them.
+func revokePoints(ctx context.Context, repo Repository, sale *Sale, ret *Return) error {
+	earned, err := repo.SalePoints(ctx, sale.ID)
+	if err != nil || earned == 0 {
+		return err
+	}
+	total := 0
+	for _, v := range sale.Positions {
+		total += v.Price * v.Qty
+	}
+	if total == 0 {
+		return nil
+	}
+	points := earned * ret.Refund / total
+	balance, err := repo.LockPoints(ctx, sale.Customer_id)
+	if err != nil {
+		return err
+	}
+	if points > balance {
+		points = balance
+	}
+	if points == 0 {
+		return nil
+	}
+	return spendPoints(ctx, repo, &PointsTransaction{
+		Customer_id: sale.Customer_id,
+		Kind:        PointsRevoked,
+		Points:      -points,
+		Sale_id:     &sale.ID,
+		Return_id:   &ret.ID,
+	})
+}
+
+// refundPoints credits the customer back with the points they redeemed for
+// the items of the return.
+func (s *Service) refundPoints(ctx context.Context, repo Repository, sale *Sale, ret *Return) error {
+	sold := make(map[int64]*SalePosition)
+	for _, position := range sale.Positions {
+		sold[position.ID] = position
+	}
+	points := 0
+	for _, v := range ret.Positions {
+		points += sold[v.Sale_position_id].Points_discount * v.Qty / s.opts.Loyalty.PointValue
+	}
+	if points == 0 {
+		return nil
+	}
+	balance, err := repo.LockPoints(ctx, sale.Customer_id)
+	if err != nil {
+		return err
+	}
+	return repo.CreatePointsTransaction(ctx, &PointsTransaction{
+		Customer_id: sale.Customer_id,
+		Kind:        PointsRefunded,
+		Points:      points,
+		Balance:     balance + points,
+		Remaining:   points,
+		Sale_id:     &sale.ID,
+		Return_id:   &ret.ID,
+	})
+}
+
+// loyaltyTier returns the highest tier reached by spend, or nil.
+func loyaltyTier(rules []*LoyaltyRule, spend int) *LoyaltyRule {
+	var tier *LoyaltyRule
+	for _, rule := range rules {
+		if rule.Kind == LoyaltyTier && spend >= rule.Min_spend && (tier == nil || rule.Min_spend > tier.Min_spend) {
+			tier = rule
+		}
+	}
+	return tier
+}
@@ -0,0 +1,249 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrInvalidStatus = errors.New("invalid sale status")
+var ErrStatusTransition = errors.New("sale cannot move to this status")
+var ErrSaleNotFulfilled = errors.New("sale is not fulfilled")
+var ErrSaleHasPayments = errors.New("sale has payments that were not refunded")
+
+// Statuses of a sale. Sales made over the counter are fulfilled at once;
+// orders go from draft to fulfilled or get cancelled on the way. Stock is
+// reserved at confirmation and released on cancellation.
+const (
+	SaleDraft     = "draft"
+	SaleConfirmed = "confirmed"
+	SalePaid      = "paid"
+	SaleFulfilled = "fulfilled"
+	SaleCancelled = "cancelled"
+)
+
+// saleTransitions lists the statuses each status can move to; fulfilled and
+// cancelled sales are final.
+var saleTransitions = map[string][]string{
+	SaleDraft:     {SaleConfirmed, SaleCancelled},
+	SaleConfirmed: {SalePaid, SaleCancelled},
+	SalePaid:      {SaleFulfilled, SaleCancelled},
+}
+
+// StatusChange is an entry of the status history of a sale. Manager_id is 0
+// when the customer placed the order at checkout.
+type StatusChange struct {
+	ID         int64     `json:"id"`
+	Sale_id    int64     `json:"sale_id"`
+	Status     string    `json:"status"`
+	Manager_id int64     `json:"manager_id"`
+	Created    time.Time `json:"created"`
+}
+
+// SaleStatus is the current status of a sale with the history leading to it.
+type SaleStatus struct {
+	Sale_id int64           `json:"sale_id"`
+	Status  string          `json:"status"`
+	History []*StatusChange `json:"history"`
+}
+
+// SetSaleStatus moves the sale to status on behalf of managerID.
+func (s *Service) SetSaleStatus(ctx context.Context, managerID int64, id int64, status string) (*Sale, error) {
+	var sale *Sale
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		var err error
+		sale, err = repo.LockSale(ctx, id)
+		if err != nil {
+			return err
+		}
+		return moveSale(ctx, repo, managerID, sale, status)
+	})
+	switch err {
+	case nil:
+		return sale, nil
+	case ErrSaleNotFound, ErrStatusTransition, ErrProductNotFound, ErrProductInactive, ErrInsufficientStock,
+		ErrSaleHasPayments:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func (s *Service) GetSaleStatus(ctx context.Context, id int64) (*SaleStatus, error) {
+	history, err := s.repo.StatusHistory(ctx, id)
+	if err == ErrSaleNotFound {
+		return nil, ErrSaleNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	status := &SaleStatus{Sale_id: id, History: history}
+	if len(history) > 0 {
+		status.Status = history[len(history)-1].Status
+	}
+	return status, nil
+}
+
+// GetOrders lists the sales in the given status with their positions.
+func (s *Service) GetOrders(ctx context.Context, status string) ([]*Sale, error) {
+	switch status {
+	case SaleDraft, SaleConfirmed, SalePaid, SaleFulfilled, SaleCancelled:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	items, err := s.repo.SalesByStatus(ctx, status)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// moveSale moves the locked sale to status, reserving the stock when a
+// draft is confirmed and releasing it when an order holding stock is
+// cancelled, crediting the customer with loyalty points when it is
+// fulfilled, and records the change. Orders can only be cancelled once
+// what was paid for them has been refunded.
+func moveSale(ctx context.Context, repo Repository, managerID int64, sale *Sale, status string) error {
+	if !canMoveSale(sale.Status, status) {
+		return ErrStatusTransition
+	}
+	switch {
+	case status == SaleConfirmed:
+		products, err := lockSaleProducts(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+		err = takeStock(ctx, repo, sale, products, MovementReserve, managerID)
+		if err != nil {
+			return err
+		}
+	case status == SaleCancelled && sale.Status != SaleDraft:
+		paid, err := salePaid(ctx, repo, sale.ID)
+		if err != nil {
+			return err
+		}
+		if paid > 0 {
+			return ErrSaleHasPayments
+		}
+		err = releaseStock(ctx, repo, sale, managerID)
+		if err != nil {
+			return err
+		}
+	case status == SaleFulfilled:
+		err := earnPoints(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+	}
+
+	before := sale.Status
+	err := repo.SetSaleStatus(ctx, sale.ID, status)
+	if err != nil {
+		return err
+	}
+	sale.Status = status
+	err = repo.CreateStatusChange(ctx, &StatusChange{Sale_id: sale.ID, Status: status, Manager_id: managerID})
+	if err != nil {
+		return err
+	}
+	return audit(ctx, repo, managerID, ActionSaleStatus, EntitySale, sale.ID,
+		map[string]interface{}{"status": before}, map[string]interface{}{"status": status})
+}
+
+func canMoveSale(from string, to string) bool {
+	for _, status := range saleTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// lockSaleProducts locks the products of the sale ordered by id, making sure
+// that all of them exist and are active.
+func lockSaleProducts(ctx context.Context, repo Repository, sale *Sale) ([]*Product, error) {
+	ids := make([]int64, 0, len(sale.Positions))
+	seen := make(map[int64]bool)
+	for _, v := range sale.Positions {
+		if !seen[v.Product_id] {
+			seen[v.Product_id] = true
+			ids = append(ids, v.Product_id)
+		}
+	}
+	products, err := repo.LockProducts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) != len(ids) {
+		return nil, ErrProductNotFound
+	}
+	for _, product := range products {
+		if !product.Active {
+			return nil, ErrProductInactive
+		}
+	}
+	return products, nil
+}
+
+// takeStock takes the positions of the sale off the locked products with
+// movements of the given kind, failing if any of them is short.
+func takeStock(ctx context.Context, repo Repository, sale *Sale, products []*Product, kind string, managerID int64) error {
+	required := saleQty(sale)
+	for _, product := range products {
+		if product.Qty < required[product.ID] {
+			return ErrInsufficientStock
+		}
+	}
+	for _, product := range products {
+		err := moveStock(ctx, repo, &StockMovement{
+			Product_id: product.ID,
+			Manager_id: managerID,
+			Kind:       kind,
+			Qty:        -required[product.ID],
+			Sale_id:    &sale.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseStock puts the positions of a cancelled order back in stock.
+func releaseStock(ctx context.Context, repo Repository, sale *Sale, managerID int64) error {
+	required := saleQty(sale)
+	ids := make([]int64, 0, len(required))
+	for id := range required {
+		ids = append(ids, id)
+	}
+	products, err := repo.LockProducts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		err = moveStock(ctx, repo, &StockMovement{
+			Product_id: product.ID,
+			Manager_id: managerID,
+			Kind:       MovementRelease,
+			Qty:        required[product.ID],
+			Sale_id:    &sale.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saleQty sums the quantities of the sale by product id.
+func saleQty(sale *Sale) map[int64]int {
+	required := make(map[int64]int)
+	for _, v := range sale.Positions {
+		required[v.Product_id] += v.Qty
+	}
+	return required
+}
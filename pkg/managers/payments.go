@@ -0,0 +1,531 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/payments"
+)
+
+var ErrInvalidPayment = errors.New("invalid payment")
+var ErrSaleNotPayable = errors.New("sale cannot be paid in its status")
+var ErrPaymentExceedsDue = errors.New("payment exceeds amount due")
+var ErrPaymentDeclined = errors.New("payment declined")
+var ErrPaymentNotFound = errors.New("no such payment")
+var ErrRefundExceeded = errors.New("refund exceeds paid amount")
+var ErrRefundPending = errors.New("payment has a refund in progress")
+
+// Tender methods. Wallet tenders are taken from the stored value of the
+// customer of the sale.
+const (
+	TenderCash   = "cash"
+	TenderCard   = "card"
+	TenderWallet = "wallet"
+)
+
+// Statuses of a payment. A refunded payment has been given back in full;
+// a failed one was reversed by the card issuer after capture. A card
+// payment is pending while the gateway captures it, counting as paid so
+// that the sale is not paid twice meanwhile, and voided if the payment did
+// not go through. It is refund pending while the gateway is giving money
+// back; its Refunded already counts that money.
+const (
+	PaymentPending       = "pending"
+	PaymentCaptured      = "captured"
+	PaymentRefundPending = "refund_pending"
+	PaymentRefunded      = "refunded"
+	PaymentVoided        = "voided"
+	PaymentFailed        = "failed"
+)
+
+// Tender is one part of a split payment. Card tenders go through the
+// payment gateway with the card token it issued.
+type Tender struct {
+	Method     string `json:"method"`
+	Amount     int    `json:"amount"`
+	Card_token string `json:"card_token,omitempty"`
+}
+
+// Payment is money taken for a sale. Provider and Reference identify card
+// payments at the gateway and are empty for cash.
+type Payment struct {
+	ID         int64     `json:"id"`
+	Sale_id    int64     `json:"sale_id"`
+	Method     string    `json:"method"`
+	Provider   string    `json:"provider"`
+	Reference  string    `json:"reference"`
+	Amount     int       `json:"amount"`
+	Refunded   int       `json:"refunded"`
+	Status     string    `json:"status"`
+	Manager_id int64     `json:"manager_id"`
+	Created    time.Time `json:"created"`
+}
+
+// paid is what the payment finally brought in.
+func (p *Payment) paid() int {
+	if p.Status == PaymentVoided || p.Status == PaymentFailed {
+		return 0
+	}
+	return p.Amount - p.Refunded
+}
+
+// Reconciliation compares what a sale was worth after returns with what
+// was paid for it; Difference is positive for overpaid sales. Cancelled
+// sales are worth nothing and are listed when they had payments.
+type Reconciliation struct {
+	Sale_id    int64     `json:"sale_id"`
+	Receipt_no int64     `json:"receipt_no"`
+	Status     string    `json:"status"`
+	Created    time.Time `json:"created"`
+	Total      int       `json:"total"`
+	Returned   int       `json:"returned"`
+	Paid       int       `json:"paid"`
+	Difference int       `json:"difference"`
+}
+
+// PaySale takes the tenders for the sale on behalf of managerID. Together
+// they may not exceed the amount still due; a confirmed order paid in full
+// becomes paid. Card tenders are booked as pending and captured at the
+// gateway after commit, so that no sale or wallet stays locked while it
+// answers; the other tenders are taken once every card is captured. If any
+// tender fails, the cards are given back and their payments voided.
+func (s *Service) PaySale(ctx context.Context, managerID int64, saleID int64, tenders []*Tender) ([]*Payment, error) {
+	if len(tenders) == 0 {
+		return nil, ErrInvalidPayment
+	}
+	others := make([]*Tender, 0, len(tenders))
+	for _, tender := range tenders {
+		if tender.Amount <= 0 {
+			return nil, ErrInvalidPayment
+		}
+		switch tender.Method {
+		case TenderCash, TenderWallet:
+			others = append(others, tender)
+		case TenderCard:
+			if tender.Card_token == "" || s.opts.Payments == nil {
+				return nil, ErrInvalidPayment
+			}
+		default:
+			return nil, ErrInvalidPayment
+		}
+	}
+
+	var cards []*Payment
+	var tokens []string
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		cards, tokens = nil, nil
+		sale, due, err := lockPayable(ctx, repo, saleID, tenders)
+		if err != nil {
+			return err
+		}
+		for _, tender := range tenders {
+			if tender.Method != TenderCard {
+				continue
+			}
+			payment := &Payment{
+				Sale_id:    saleID,
+				Method:     TenderCard,
+				Amount:     tender.Amount,
+				Status:     PaymentPending,
+				Manager_id: managerID,
+			}
+			err = repo.CreatePayment(ctx, payment)
+			if err != nil {
+				return err
+			}
+			err = audit(ctx, repo, managerID, ActionPaymentCreate, EntityPayment, payment.ID, nil, payment)
+			if err != nil {
+				return err
+			}
+			cards = append(cards, payment)
+			tokens = append(tokens, tender.Card_token)
+		}
+		if len(cards) > 0 {
+			return nil
+		}
+		return takeTenders(ctx, repo, managerID, sale, due, others, nil)
+	})
+	if err == nil && len(cards) > 0 {
+		for i, payment := range cards {
+			err = s.capture(ctx, payment, tokens[i])
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = s.repo.WithTx(ctx, func(repo Repository) error {
+				sale, due, err := lockPayable(ctx, repo, saleID, others)
+				if err != nil {
+					return err
+				}
+				return takeTenders(ctx, repo, managerID, sale, due, others, cards)
+			})
+		}
+		if err != nil {
+			s.voidCards(ctx, managerID, saleID, cards)
+		}
+	}
+	switch err {
+	case nil:
+		return s.GetPayments(ctx, saleID)
+	case ErrSaleNotFound, ErrSaleNotPayable, ErrPaymentExceedsDue, ErrPaymentDeclined, ErrInsufficientFunds:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// lockPayable locks the sale and returns it with what would still be due
+// after the tenders, making sure that it can be paid and that the tenders
+// do not exceed what is due.
+func lockPayable(ctx context.Context, repo Repository, saleID int64, tenders []*Tender) (*Sale, int, error) {
+	sale, err := repo.LockSale(ctx, saleID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sale.Status == SaleDraft || sale.Status == SaleCancelled {
+		return nil, 0, ErrSaleNotPayable
+	}
+	due, err := amountDue(ctx, repo, sale)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, tender := range tenders {
+		due -= tender.Amount
+	}
+	if due < 0 {
+		return nil, 0, ErrPaymentExceedsDue
+	}
+	return sale, due, nil
+}
+
+// takeTenders settles the captured card payments of the locked sale and
+// takes its cash and wallet tenders, moving a confirmed order with nothing
+// left due to paid.
+func takeTenders(ctx context.Context, repo Repository, managerID int64, sale *Sale, due int, tenders []*Tender,
+	cards []*Payment) error {
+	for _, payment := range cards {
+		payment.Status = PaymentCaptured
+		err := settleCard(ctx, repo, managerID, payment)
+		if err != nil {
+			return err
+		}
+	}
+	for _, tender := range tenders {
+		var payment *Payment
+		var err error
+		if tender.Method == TenderWallet {
+			payment, err = payFromWallet(ctx, repo, managerID, sale, tender.Amount)
+			if err != nil {
+				return err
+			}
+		} else {
+			payment = &Payment{
+				Sale_id:    sale.ID,
+				Method:     tender.Method,
+				Amount:     tender.Amount,
+				Status:     PaymentCaptured,
+				Manager_id: managerID,
+			}
+			err = repo.CreatePayment(ctx, payment)
+			if err != nil {
+				return err
+			}
+		}
+		err = audit(ctx, repo, managerID, ActionPaymentCreate, EntityPayment, payment.ID, nil, payment)
+		if err != nil {
+			return err
+		}
+	}
+
+	if due == 0 && sale.Status == SaleConfirmed {
+		return moveSale(ctx, repo, managerID, sale, SalePaid)
+	}
+	return nil
+}
+
+// settleCard saves the gateway reference and final status of a pending card
+// payment.
+func settleCard(ctx context.Context, repo Repository, managerID int64, payment *Payment) error {
+	err := repo.UpdatePayment(ctx, payment)
+	if err != nil {
+		return err
+	}
+	return audit(ctx, repo, managerID, ActionPaymentCapture, EntityPayment, payment.ID,
+		map[string]interface{}{"status": PaymentPending},
+		map[string]interface{}{"status": payment.Status, "provider": payment.Provider, "reference": payment.Reference})
+}
+
+// voidCards gives back the cards of a payment that did not go through and
+// voids their pending payments.
+func (s *Service) voidCards(ctx context.Context, managerID int64, saleID int64, cards []*Payment) {
+	for _, payment := range cards {
+		if payment.Reference == "" {
+			continue
+		}
+		err := s.opts.Payments.Refund(ctx, payment.Reference, payment.Amount)
+		if err != nil {
+			log.Printf("payment %s of sale %d not given back: %v", payment.Reference, saleID, err)
+		}
+	}
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		for _, payment := range cards {
+			payment.Status = PaymentVoided
+			err := settleCard(ctx, repo, managerID, payment)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("payments of sale %d left pending: %v", saleID, err)
+	}
+}
+
+// capture authorizes and captures the payment on the card at the gateway,
+// setting its reference once the money is taken.
+func (s *Service) capture(ctx context.Context, payment *Payment, token string) error {
+	reference, err := s.opts.Payments.Authorize(ctx, payment.Amount, token)
+	if err == payments.ErrDeclined {
+		return ErrPaymentDeclined
+	}
+	if err != nil {
+		return err
+	}
+	err = s.opts.Payments.Capture(ctx, reference, payment.Amount)
+	if err != nil {
+		refundErr := s.opts.Payments.Refund(ctx, reference, payment.Amount)
+		if refundErr != nil {
+			log.Printf("authorization %s not released: %v", reference, refundErr)
+		}
+		return err
+	}
+	payment.Provider, payment.Reference = s.opts.Payments.Name(), reference
+	return nil
+}
+
+// RefundPayment gives amount of the payment back, through the gateway for
+// card payments and as wallet credit for wallet payments. Card refunds are
+// recorded as pending before the gateway is called after commit, then
+// settled, or undone if the gateway refuses, so that no money leaves at the
+// gateway without being booked.
+func (s *Service) RefundPayment(ctx context.Context, managerID int64, id int64, amount int) (*Payment, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidPayment
+	}
+
+	var payment *Payment
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		var err error
+		payment, err = repo.LockPayment(ctx, id)
+		if err != nil {
+			return err
+		}
+		if payment.Status == PaymentRefundPending {
+			return ErrRefundPending
+		}
+		if payment.Status == PaymentPending || payment.Status == PaymentVoided || payment.Status == PaymentFailed ||
+			payment.Refunded+amount > payment.Amount {
+			return ErrRefundExceeded
+		}
+		before := *payment
+		if payment.Method == TenderCard && (s.opts.Payments == nil || s.opts.Payments.Name() != payment.Provider) {
+			return ErrInvalidPayment
+		}
+		if payment.Method == TenderWallet {
+			sale, err := repo.LockSale(ctx, payment.Sale_id)
+			if err != nil {
+				return err
+			}
+			err = moveWallet(ctx, repo, &WalletTransaction{
+				Customer_id: sale.Customer_id,
+				Kind:        WalletRefund,
+				Amount:      amount,
+				Sale_id:     &sale.ID,
+				Payment_id:  &payment.ID,
+				Manager_id:  managerID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		payment.Refunded += amount
+		switch {
+		case payment.Method == TenderCard:
+			payment.Status = PaymentRefundPending
+		case payment.Refunded == payment.Amount:
+			payment.Status = PaymentRefunded
+		}
+		err = repo.UpdatePayment(ctx, payment)
+		if err != nil {
+			return err
+		}
+		return auditRefund(ctx, repo, managerID, &before, payment)
+	})
+	switch err {
+	case nil:
+	case ErrPaymentNotFound, ErrRefundExceeded, ErrRefundPending, ErrInvalidPayment:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	if payment.Method != TenderCard {
+		return payment, nil
+	}
+
+	refundErr := s.opts.Payments.Refund(ctx, payment.Reference, amount)
+	if refundErr != nil {
+		log.Printf("refund of payment %d at the gateway: %v", id, refundErr)
+	}
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		var err error
+		payment, err = repo.LockPayment(ctx, id)
+		if err != nil {
+			return err
+		}
+		// a gateway notification may have settled the payment meanwhile
+		if payment.Status != PaymentRefundPending {
+			return nil
+		}
+		before := *payment
+		if refundErr != nil {
+			payment.Refunded -= amount
+		}
+		payment.Status = PaymentCaptured
+		if payment.Refunded == payment.Amount {
+			payment.Status = PaymentRefunded
+		}
+		err = repo.UpdatePayment(ctx, payment)
+		if err != nil {
+			return err
+		}
+		return auditRefund(ctx, repo, managerID, &before, payment)
+	})
+	if err != nil {
+		log.Printf("refund of payment %d left pending: %v", id, err)
+	}
+	if refundErr != nil {
+		return nil, ErrInternal
+	}
+	return payment, nil
+}
+
+// auditRefund records the change of the refunded amount and status of the
+// payment.
+func auditRefund(ctx context.Context, repo Repository, managerID int64, before *Payment, after *Payment) error {
+	return audit(ctx, repo, managerID, ActionPaymentRefund, EntityPayment, after.ID,
+		map[string]interface{}{"refunded": before.Refunded, "status": before.Status},
+		map[string]interface{}{"refunded": after.Refunded, "status": after.Status})
+}
+
+// HandlePaymentWebhook applies a notification of the payment gateway to the
+// payment it is about. Notifications about unknown payments are rejected so
+// that the gateway retries them.
+func (s *Service) HandlePaymentWebhook(ctx context.Context, header http.Header, body []byte) error {
+	if s.opts.Payments == nil {
+		return ErrPaymentNotFound
+	}
+	event, err := s.opts.Payments.VerifyWebhook(header, body)
+	if err != nil {
+		return payments.ErrInvalidSignature
+	}
+
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		payment, err := repo.PaymentByReference(ctx, s.opts.Payments.Name(), event.Reference)
+		if err != nil {
+			return err
+		}
+		switch event.Type {
+		case payments.EventRefunded:
+			if event.Amount <= payment.Refunded || event.Amount > payment.Amount {
+				return nil
+			}
+			payment.Refunded = event.Amount
+			if payment.Refunded == payment.Amount {
+				payment.Status = PaymentRefunded
+			}
+		case payments.EventFailed:
+			payment.Status = PaymentFailed
+		default:
+			return nil
+		}
+		return repo.UpdatePayment(ctx, payment)
+	})
+	switch err {
+	case nil, ErrPaymentNotFound:
+		return err
+	default:
+		log.Print(err)
+		return ErrInternal
+	}
+}
+
+func (s *Service) GetPayments(ctx context.Context, saleID int64) ([]*Payment, error) {
+	items, err := s.repo.SalePayments(ctx, saleID)
+	if err == ErrSaleNotFound {
+		return nil, ErrSaleNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// Reconcile compares payments with the sales made over period; with
+// mismatchedOnly only sales paid more or less than they are worth are
+// listed.
+func (s *Service) Reconcile(ctx context.Context, period Period, mismatchedOnly bool) ([]*Reconciliation, error) {
+	items, err := s.repo.Reconciliation(ctx, period)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	result := make([]*Reconciliation, 0, len(items))
+	for _, item := range items {
+		item.Difference = item.Paid - (item.Total - item.Returned)
+		if !mismatchedOnly || item.Difference != 0 {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// amountDue is what is left to pay for the locked sale after its returns.
+func amountDue(ctx context.Context, repo Repository, sale *Sale) (int, error) {
+	due := 0
+	for _, v := range sale.Positions {
+		due += v.Price * v.Qty
+	}
+	returns, err := repo.Returns(ctx, sale.ID)
+	if err != nil {
+		return 0, err
+	}
+	for _, ret := range returns {
+		due -= ret.Refund
+	}
+	paid, err := salePaid(ctx, repo, sale.ID)
+	if err != nil {
+		return 0, err
+	}
+	return due - paid, nil
+}
+
+// salePaid is what the payments of the sale brought in after refunds.
+func salePaid(ctx context.Context, repo Repository, saleID int64) (int, error) {
+	items, err := repo.SalePayments(ctx, saleID)
+	if err != nil {
+		return 0, err
+	}
+	paid := 0
+	for _, payment := range items {
+		paid += payment.paid()
+	}
+	return paid, nil
+}
@@ -0,0 +1,449 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrInvalidCommissionRule = errors.New("invalid commission rule")
+var ErrCommissionRuleNotFound = errors.New("no such commission rule")
+var ErrStatementNotFound = errors.New("no such payroll statement")
+var ErrStatementFinalized = errors.New("payroll statement is already finalized")
+
+// Kinds of commission rules. A sale position earns the highest rate of the
+// category rules matching its product or any ancestor category; positions
+// without one earn the highest tiered rate reached by the manager's plan
+// attainment, and the highest flat rate otherwise.
+const (
+	CommissionFlat     = "flat"
+	CommissionTiered   = "tiered"
+	CommissionCategory = "category"
+)
+
+// Statement states.
+const (
+	StatementDraft = "draft"
+	StatementFinal = "final"
+)
+
+// CommissionRule pays Rate_bp basis points (1/100 of a percent) of the sold
+// amount. Min_attainment is used by tiered rules and Category_id by category
+// rules.
+type CommissionRule struct {
+	ID             int64     `json:"id"`
+	Kind           string    `json:"kind"`
+	Rate_bp        int       `json:"rate_bp"`
+	Min_attainment int       `json:"min_attainment"`
+	Category_id    *int64    `json:"category_id"`
+	Created        time.Time `json:"created"`
+}
+
+// CommissionLine is the commission earned on one sale position.
+type CommissionLine struct {
+	Sale_id     int64  `json:"sale_id"`
+	Position_id int64  `json:"position_id"`
+	Product_id  int64  `json:"product_id"`
+	Name        string `json:"name"`
+	Qty         int    `json:"qty"`
+	Amount      int    `json:"amount"`
+	Rule_id     *int64 `json:"rule_id"`
+	Rate_bp     int    `json:"rate_bp"`
+	Commission  int    `json:"commission"`
+}
+
+// Statement is the monthly payroll of a manager. Drafts are computed on
+// request from the current rules and sales net of the items returned so
+// far; finalized statements are stored and never change again.
+type Statement struct {
+	ID           int64             `json:"id"`
+	Manager_id   int64             `json:"manager_id"`
+	Name         string            `json:"name"`
+	Month        time.Time         `json:"month"`
+	Status       string            `json:"status"`
+	Salary       int               `json:"salary"`
+	Plan         int               `json:"plan"`
+	Actual       int               `json:"actual"`
+	Attainment   int               `json:"attainment"`
+	Commission   int               `json:"commission"`
+	Total        int               `json:"total"`
+	Lines        []*CommissionLine `json:"lines"`
+	Finalized_by int64             `json:"finalized_by"`
+	Finalized    *time.Time        `json:"finalized"`
+}
+
+func (s *Service) GetCommissionRules(ctx context.Context) ([]*CommissionRule, error) {
+	items, err := s.repo.CommissionRules(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+func (s *Service) CreateCommissionRule(ctx context.Context, adminID int64, rule *CommissionRule) (*CommissionRule, error) {
+	if rule.Rate_bp < 0 || rule.Rate_bp > 10000 || rule.Min_attainment < 0 {
+		return nil, ErrInvalidCommissionRule
+	}
+	switch rule.Kind {
+	case CommissionFlat:
+		rule.Min_attainment, rule.Category_id = 0, nil
+	case CommissionTiered:
+		rule.Category_id = nil
+	case CommissionCategory:
+		if rule.Category_id == nil {
+			return nil, ErrInvalidCommissionRule
+		}
+		rule.Min_attainment = 0
+	default:
+		return nil, ErrInvalidCommissionRule
+	}
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		if rule.Category_id != nil {
+			categories, err := repo.Categories(ctx)
+			if err != nil {
+				return err
+			}
+			if _, ok := categoryParents(categories)[*rule.Category_id]; !ok {
+				return ErrCategoryNotFound
+			}
+		}
+		err := repo.CreateCommissionRule(ctx, rule)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionCommissionCreate, EntityCommissionRule, rule.ID, nil, rule)
+	})
+	switch err {
+	case nil:
+		return rule, nil
+	case ErrCategoryNotFound:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// RemoveCommissionRule deletes the rule; finalized statements keep the
+// rates they were computed with.
+func (s *Service) RemoveCommissionRule(ctx context.Context, adminID int64, id int64) (*CommissionRule, error) {
+	var rule *CommissionRule
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		var err error
+		rule, err = repo.DeleteCommissionRule(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionCommissionDelete, EntityCommissionRule, id, rule, nil)
+	})
+	switch err {
+	case nil:
+		return rule, nil
+	case ErrCommissionRuleNotFound:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// GetStatement returns the finalized statement of the manager for the month
+// containing month, or a draft if it is not finalized yet.
+func (s *Service) GetStatement(ctx context.Context, managerID int64, month time.Time) (*Statement, error) {
+	month, _, _ = planPeriod(PlanMonth, month)
+	statement, err := s.repo.Statement(ctx, managerID, month)
+	if err == nil {
+		return statement, nil
+	}
+	if err != ErrStatementNotFound {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	manager, err := s.repo.Manager(ctx, managerID)
+	if err == ErrUserNotFound {
+		return nil, err
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	p, err := newPayroll(ctx, s.repo, month)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	statement, err = p.statement(ctx, manager)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return statement, nil
+}
+
+// GetStatements returns the statements of every manager for the month
+// containing month, finalized or draft.
+func (s *Service) GetStatements(ctx context.Context, month time.Time) ([]*Statement, error) {
+	month, _, _ = planPeriod(PlanMonth, month)
+	items, err := s.statements(ctx, month)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+func (s *Service) statements(ctx context.Context, month time.Time) ([]*Statement, error) {
+	finalized, err := s.repo.Statements(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	byManager := make(map[int64]*Statement)
+	for _, item := range finalized {
+		byManager[item.Manager_id] = item
+	}
+
+	staff, err := s.repo.AllManagers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPayroll(ctx, s.repo, month)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*Statement, 0, len(staff))
+	for _, manager := range staff {
+		statement, ok := byManager[manager.ID]
+		if !ok {
+			statement, err = p.statement(ctx, manager)
+			if err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, statement)
+	}
+	return items, nil
+}
+
+// FinalizeStatement computes the statement of the manager for the month
+// containing month and locks it on behalf of adminID.
+func (s *Service) FinalizeStatement(ctx context.Context, adminID int64, managerID int64, month time.Time) (*Statement, error) {
+	month, _, _ = planPeriod(PlanMonth, month)
+	var statement *Statement
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		_, err := repo.Statement(ctx, managerID, month)
+		if err == nil {
+			return ErrStatementFinalized
+		}
+		if err != ErrStatementNotFound {
+			return err
+		}
+		manager, err := repo.Manager(ctx, managerID)
+		if err != nil {
+			return err
+		}
+		p, err := newPayroll(ctx, repo, month)
+		if err != nil {
+			return err
+		}
+		statement, err = p.statement(ctx, manager)
+		if err != nil {
+			return err
+		}
+		statement.Status = StatementFinal
+		statement.Finalized_by = adminID
+		err = repo.CreateStatement(ctx, statement)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionPayrollFinalize, EntityPayroll, statement.ID, nil,
+			map[string]interface{}{"manager_id": managerID, "month": month, "total": statement.Total})
+	})
+	switch err {
+	case nil:
+		return statement, nil
+	case ErrUserNotFound, ErrStatementFinalized:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// payroll holds what is shared by the statements of one month.
+type payroll struct {
+	repo       Repository
+	start      time.Time
+	end        time.Time
+	rules      []*CommissionRule
+	targets    map[int64]int
+	parents    map[int64]*int64
+	categories map[int64][]int64
+}
+
+func newPayroll(ctx context.Context, repo Repository, month time.Time) (*payroll, error) {
+	start, end, err := planPeriod(PlanMonth, month)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := repo.CommissionRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := repo.PlanTargets(ctx, PlanMonth, start)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := repo.Categories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &payroll{
+		repo:       repo,
+		start:      start,
+		end:        end,
+		rules:      rules,
+		targets:    targets,
+		parents:    categoryParents(categories),
+		categories: make(map[int64][]int64),
+	}, nil
+}
+
+func (p *payroll) statement(ctx context.Context, manager *Manager) (*Statement, error) {
+	to := p.end.Add(-time.Nanosecond)
+	sales, err := p.repo.ManagerSales(ctx, manager.ID, Period{From: &p.start, To: &to})
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{
+		Manager_id: manager.ID,
+		Name:       manager.Name,
+		Month:      p.start,
+		Status:     StatementDraft,
+		Salary:     manager.Salary,
+		Plan:       p.targets[manager.ID],
+		Lines:      make([]*CommissionLine, 0),
+	}
+	// returned maps position ids to the quantity returned, which earns
+	// neither commission nor attainment
+	returned := make(map[int64]int)
+	for _, sale := range sales {
+		qty, err := p.repo.ReturnedQty(ctx, sale.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, position := range sale.Positions {
+			returned[position.ID] = qty[position.ID]
+			statement.Actual += position.Price * (position.Qty - qty[position.ID])
+		}
+	}
+	if statement.Plan > 0 {
+		statement.Attainment = statement.Actual * 100 / statement.Plan
+	}
+
+	for _, sale := range sales {
+		for _, position := range sale.Positions {
+			qty := position.Qty - returned[position.ID]
+			if qty <= 0 {
+				continue
+			}
+			rule, err := p.rule(ctx, position.Product_id, statement.Attainment)
+			if err != nil {
+				return nil, err
+			}
+			line := &CommissionLine{
+				Sale_id:     sale.ID,
+				Position_id: position.ID,
+				Product_id:  position.Product_id,
+				Name:        position.Name,
+				Qty:         qty,
+				Amount:      position.Price * qty,
+			}
+			if rule != nil {
+				ruleID := rule.ID
+				line.Rule_id, line.Rate_bp = &ruleID, rule.Rate_bp
+				line.Commission = line.Amount * rule.Rate_bp / 10000
+			}
+			statement.Commission += line.Commission
+			statement.Lines = append(statement.Lines, line)
+		}
+	}
+	statement.Total = statement.Salary + statement.Commission
+	return statement, nil
+}
+
+// rule picks the rule paying the position, or nil if no rule applies.
+func (p *payroll) rule(ctx context.Context, productID int64, attainment int) (*CommissionRule, error) {
+	ancestors, err := p.productCategories(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var category, tiered, flat *CommissionRule
+	for _, rule := range p.rules {
+		switch rule.Kind {
+		case CommissionCategory:
+			if containsID(ancestors, *rule.Category_id) && (category == nil || rule.Rate_bp > category.Rate_bp) {
+				category = rule
+			}
+		case CommissionTiered:
+			if attainment >= rule.Min_attainment && (tiered == nil || rule.Rate_bp > tiered.Rate_bp) {
+				tiered = rule
+			}
+		case CommissionFlat:
+			if flat == nil || rule.Rate_bp > flat.Rate_bp {
+				flat = rule
+			}
+		}
+	}
+	switch {
+	case category != nil:
+		return category, nil
+	case tiered != nil:
+		return tiered, nil
+	default:
+		return flat, nil
+	}
+}
+
+// productCategories returns the categories of the product together with
+// all their ancestors.
+func (p *payroll) productCategories(ctx context.Context, productID int64) ([]int64, error) {
+	if ids, ok := p.categories[productID]; ok {
+		return ids, nil
+	}
+	ids, err := productAncestors(ctx, p.repo, p.parents, productID)
+	if err != nil {
+		return nil, err
+	}
+	p.categories[productID] = ids
+	return ids, nil
+}
+
+// productAncestors returns the categories of the product together with all
+// their ancestors, given the parents of every category.
+func productAncestors(ctx context.Context, repo Repository, parents map[int64]*int64, productID int64) ([]int64, error) {
+	assigned, err := repo.ProductCategories(ctx, productID)
+	if err != nil && err != ErrProductNotFound {
+		return nil, err
+	}
+	ids := make([]int64, 0)
+	for _, category := range assigned {
+		for id := &category.ID; id != nil && !containsID(ids, *id); id = parents[*id] {
+			ids = append(ids, *id)
+		}
+	}
+	return ids, nil
+}
+
+func categoryParents(items []*Category) map[int64]*int64 {
+	parents := make(map[int64]*int64)
+	for _, item := range items {
+		parents[item.ID] = item.Parent_id
+	}
+	return parents
+}
@@ -0,0 +1,163 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrInvalidPlan = errors.New("invalid plan")
+
+const (
+	PlanMonth   = "month"
+	PlanQuarter = "quarter"
+)
+
+// Plan is a sales target for one month or quarter. Plans are never updated:
+// setting a new target appends a row and the latest one for the period wins,
+// so the history of every change is kept.
+type Plan struct {
+	ID         int64     `json:"id"`
+	Manager_id int64     `json:"manager_id"`
+	Period     string    `json:"period"`
+	Start      time.Time `json:"start"`
+	Amount     int       `json:"amount"`
+	Set_by     int64     `json:"set_by"`
+	Created    time.Time `json:"created"`
+}
+
+// PlanProgress compares the target with the total of the manager's
+// sales_positions over [Start, End) less the refunds made over it.
+type PlanProgress struct {
+	Manager_id int64     `json:"manager_id"`
+	Name       string    `json:"name"`
+	Period     string    `json:"period"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Plan       int       `json:"plan"`
+	Actual     int       `json:"actual"`
+	Remaining  int       `json:"remaining"`
+	Percent    int       `json:"percent"`
+}
+
+// planPeriod returns the bounds of the month or quarter containing date.
+func planPeriod(period string, date time.Time) (start time.Time, end time.Time, err error) {
+	date = date.UTC()
+	switch period {
+	case PlanMonth:
+		start = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	case PlanQuarter:
+		start = time.Date(date.Year(), (date.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, 0), nil
+	default:
+		return time.Time{}, time.Time{}, ErrInvalidPlan
+	}
+}
+
+// SetPlan records a new target for the period containing plan.Start on
+// behalf of adminID.
+func (s *Service) SetPlan(ctx context.Context, adminID int64, plan *Plan) (*Plan, error) {
+	start, _, err := planPeriod(plan.Period, plan.Start)
+	if err != nil || plan.Amount < 0 {
+		return nil, ErrInvalidPlan
+	}
+	plan.Start = start
+	plan.Set_by = adminID
+
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		_, err := repo.ManagerRoles(ctx, plan.Manager_id)
+		if err != nil {
+			return err
+		}
+		err = repo.CreatePlan(ctx, plan)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionPlanSet, EntityPlan, plan.ID, nil, plan)
+	})
+	switch err {
+	case nil:
+		return plan, nil
+	case ErrUserNotFound:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func (s *Service) GetPlanHistory(ctx context.Context, managerID int64) ([]*Plan, error) {
+	_, err := s.repo.ManagerRoles(ctx, managerID)
+	if err == ErrUserNotFound {
+		return nil, err
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	items, err := s.repo.Plans(ctx, managerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// GetPlanProgress reports on the manager's plan for the period containing date.
+func (s *Service) GetPlanProgress(ctx context.Context, managerID int64, period string, date time.Time) (*PlanProgress, error) {
+	items, err := s.GetPlansProgress(ctx, period, date)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Manager_id == managerID {
+			return item, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// GetPlansProgress reports on the plans of every manager for the period
+// containing date. Managers without a plan for the period are measured
+// against the default monthly target stored in managers.plan.
+func (s *Service) GetPlansProgress(ctx context.Context, period string, date time.Time) ([]*PlanProgress, error) {
+	start, end, err := planPeriod(period, date)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := s.repo.PlanTargets(ctx, period, start)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	to := end.Add(-time.Nanosecond)
+	summaries, err := s.repo.SalesByManager(ctx, Period{From: &start, To: &to})
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	items := make([]*PlanProgress, 0, len(summaries))
+	for _, summary := range summaries {
+		item := &PlanProgress{
+			Manager_id: summary.Manager_id,
+			Name:       summary.Name,
+			Period:     period,
+			Start:      start,
+			End:        end,
+			Plan:       targets[summary.Manager_id],
+			Actual:     summary.Net_total,
+		}
+		if item.Actual < item.Plan {
+			item.Remaining = item.Plan - item.Actual
+		}
+		if item.Plan > 0 {
+			item.Percent = item.Actual * 100 / item.Plan
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
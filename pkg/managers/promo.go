@@ -0,0 +1,428 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+)
+
+var ErrInvalidPromo = errors.New("invalid promo code")
+var ErrPromoNotFound = errors.New("no such promo code")
+var ErrPromoCodeUsed = errors.New("promo code already exists")
+var ErrPromoNotActive = errors.New("promo code is not active")
+var ErrPromoExhausted = errors.New("promo code usage limit reached")
+var ErrPromoMinBasket = errors.New("basket is below the promo code minimum")
+var ErrPromoNotApplicable = errors.New("promo code does not apply to the basket")
+
+// Kinds of promo codes: percent codes take Value percent off the unit
+// prices, fixed codes take Value off the basket, shared by the positions in
+// proportion to their amounts. To give the exact amount a position may be
+// split in two at unit prices one apart.
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+// Promo is a promo code. It applies to the positions of Product_id or of
+// products in Category_id or its subcategories, or to every position when
+// neither is set, of baskets worth at least Min_basket between Starts and
+// Ends. Max_uses and Max_uses_per_customer limit its uses, 0 meaning no
+// limit. Uses and Discount count the sales it was used for that were not
+// cancelled and what it took off them.
+type Promo struct {
+	ID                    int64      `json:"id"`
+	Code                  string     `json:"code"`
+	Kind                  string     `json:"kind"`
+	Value                 int        `json:"value"`
+	Min_basket            int        `json:"min_basket"`
+	Product_id            *int64     `json:"product_id"`
+	Category_id           *int64     `json:"category_id"`
+	Starts                *time.Time `json:"starts"`
+	Ends                  *time.Time `json:"ends"`
+	Max_uses              int        `json:"max_uses"`
+	Max_uses_per_customer int        `json:"max_uses_per_customer"`
+	Active                bool       `json:"active"`
+	Uses                  int        `json:"uses"`
+	Discount              int        `json:"discount"`
+	Created               time.Time  `json:"created"`
+}
+
+// PromoRedemption records a use of a promo code for a sale. Status is the
+// current status of the sale; cancelled sales free their use.
+type PromoRedemption struct {
+	ID          int64     `json:"id"`
+	Promo_id    int64     `json:"promo_id"`
+	Customer_id int64     `json:"customer_id"`
+	Sale_id     int64     `json:"sale_id"`
+	Status      string    `json:"status"`
+	Discount    int       `json:"discount"`
+	Created     time.Time `json:"created"`
+}
+
+// PromoUsage is the usage report of a promo code, newest uses first.
+type PromoUsage struct {
+	Promo       *Promo             `json:"promo"`
+	Customers   int                `json:"customers"`
+	Redemptions []*PromoRedemption `json:"redemptions"`
+}
+
+// PromoQuote is what a promo code would take off a basket sold now.
+type PromoQuote struct {
+	Promo     *Promo          `json:"promo"`
+	Subtotal  int             `json:"subtotal"`
+	Discount  int             `json:"discount"`
+	Total     int             `json:"total"`
+	Positions []*SalePosition `json:"positions"`
+}
+
+func (s *Service) GetPromos(ctx context.Context) ([]*Promo, error) {
+	items, err := s.repo.Promos(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// CreatePromo stores a new active promo code; codes are case insensitive
+// and kept upper case.
+func (s *Service) CreatePromo(ctx context.Context, adminID int64, promo *Promo) (*Promo, error) {
+	promo.Code = promoCode(promo.Code)
+	if promo.Code == "" || promo.Min_basket < 0 || promo.Max_uses < 0 || promo.Max_uses_per_customer < 0 ||
+		(promo.Product_id != nil && promo.Category_id != nil) ||
+		(promo.Starts != nil && promo.Ends != nil && !promo.Ends.After(*promo.Starts)) {
+		return nil, ErrInvalidPromo
+	}
+	switch promo.Kind {
+	case PromoPercent:
+		if promo.Value <= 0 || promo.Value > 100 {
+			return nil, ErrInvalidPromo
+		}
+	case PromoFixed:
+		if promo.Value <= 0 {
+			return nil, ErrInvalidPromo
+		}
+	default:
+		return nil, ErrInvalidPromo
+	}
+	promo.Active, promo.Uses, promo.Discount = true, 0, 0
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		if promo.Product_id != nil {
+			products, err := repo.LockProducts(ctx, []int64{*promo.Product_id})
+			if err != nil {
+				return err
+			}
+			if len(products) == 0 {
+				return ErrProductNotFound
+			}
+		}
+		if promo.Category_id != nil {
+			categories, err := repo.Categories(ctx)
+			if err != nil {
+				return err
+			}
+			if _, ok := categoryParents(categories)[*promo.Category_id]; !ok {
+				return ErrCategoryNotFound
+			}
+		}
+		err := repo.CreatePromo(ctx, promo)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionPromoCreate, EntityPromo, promo.ID, nil, promo)
+	})
+	switch err {
+	case nil:
+		return promo, nil
+	case ErrProductNotFound, ErrCategoryNotFound, ErrPromoCodeUsed:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// GetPromoUsage returns the promo code with every sale it was used for.
+func (s *Service) GetPromoUsage(ctx context.Context, id int64) (*PromoUsage, error) {
+	promo, err := s.repo.Promo(ctx, id)
+	if err == ErrPromoNotFound {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	redemptions, err := s.repo.PromoRedemptions(ctx, id)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	usage := &PromoUsage{Promo: promo, Redemptions: redemptions}
+	seen := make(map[int64]bool)
+	for _, item := range redemptions {
+		if item.Status != SaleCancelled && !seen[item.Customer_id] {
+			seen[item.Customer_id] = true
+			usage.Customers++
+		}
+	}
+	return usage, nil
+}
+
+// RemovePromoByID archives the promo code so that it can no longer be
+// used; its usage stays in the reports.
+func (s *Service) RemovePromoByID(ctx context.Context, adminID int64, id int64) (*Promo, error) {
+	return s.setPromoActive(ctx, adminID, id, false)
+}
+
+func (s *Service) RestorePromoByID(ctx context.Context, adminID int64, id int64) (*Promo, error) {
+	return s.setPromoActive(ctx, adminID, id, true)
+}
+
+func (s *Service) setPromoActive(ctx context.Context, adminID int64, id int64, active bool) (*Promo, error) {
+	var promo *Promo
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		before, err := repo.Promo(ctx, id)
+		if err != nil {
+			return err
+		}
+		err = repo.SetPromoActive(ctx, id, active)
+		if err != nil {
+			return err
+		}
+		promo, err = repo.Promo(ctx, id)
+		if err != nil {
+			return err
+		}
+		action := ActionPromoArchive
+		if active {
+			action = ActionPromoRestore
+		}
+		return audit(ctx, repo, adminID, action, EntityPromo, id, before, promo)
+	})
+	if err == ErrPromoNotFound {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return promo, nil
+}
+
+// ValidatePromo prices the positions of the sale for its customer as
+// MakeSale would and tells what sale.Promo takes off them, storing nothing.
+func (s *Service) ValidatePromo(ctx context.Context, sale *Sale) (*PromoQuote, error) {
+	sale.Promo = promoCode(sale.Promo)
+	if sale.Promo == "" {
+		return nil, ErrPromoNotFound
+	}
+	err := s.checkPositions(ctx, sale)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &PromoQuote{}
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		_, err := priceSale(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+		for _, v := range sale.Positions {
+			quote.Subtotal += v.Price * v.Qty
+		}
+		quote.Promo, err = applyPromo(ctx, repo, sale)
+		return err
+	})
+	switch err {
+	case nil:
+	case ErrProductNotFound, ErrProductInactive, ErrInvalidDiscount, customers.ErrUserNotFound,
+		ErrCustomerInactive, ErrPromoNotFound, ErrPromoNotActive, ErrPromoExhausted, ErrPromoMinBasket,
+		ErrPromoNotApplicable:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	quote.Positions = sale.Positions
+	quote.Discount = promoDiscount(sale)
+	quote.Total = quote.Subtotal - quote.Discount
+	return quote, nil
+}
+
+// ValidateCartPromo tells what the promo code takes off the cart of the
+// customer at checkout.
+func (s *Service) ValidateCartPromo(ctx context.Context, customerID int64, code string) (*PromoQuote, error) {
+	items, err := s.repo.CartItems(ctx, customerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	if len(items) == 0 {
+		return nil, ErrEmptyCart
+	}
+	sale := &Sale{Customer_id: customerID, Promo: code}
+	for _, item := range items {
+		sale.Positions = append(sale.Positions, &SalePosition{Product_id: item.Product_id, Qty: item.Qty})
+	}
+	return s.ValidatePromo(ctx, sale)
+}
+
+// applyPromo takes the promo code of the priced sale off the unit prices of
+// the positions it applies to, after checking its validity window and
+// usage limits, and returns the locked promo code. A code that would take
+// nothing off does not apply.
+func applyPromo(ctx context.Context, repo Repository, sale *Sale) (*Promo, error) {
+	promo, err := repo.LockPromo(ctx, sale.Promo)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !promo.Active || (promo.Starts != nil && now.Before(*promo.Starts)) ||
+		(promo.Ends != nil && !now.Before(*promo.Ends)) {
+		return nil, ErrPromoNotActive
+	}
+	if promo.Max_uses > 0 && promo.Uses >= promo.Max_uses {
+		return nil, ErrPromoExhausted
+	}
+	if promo.Max_uses_per_customer > 0 {
+		uses, err := repo.CustomerPromoUses(ctx, promo.ID, sale.Customer_id)
+		if err != nil {
+			return nil, err
+		}
+		if uses >= promo.Max_uses_per_customer {
+			return nil, ErrPromoExhausted
+		}
+	}
+
+	basket := 0
+	for _, v := range sale.Positions {
+		basket += v.Price * v.Qty
+	}
+	if basket < promo.Min_basket {
+		return nil, ErrPromoMinBasket
+	}
+
+	eligible := make([]*SalePosition, 0)
+	var parents map[int64]*int64
+	if promo.Category_id != nil {
+		categories, err := repo.Categories(ctx)
+		if err != nil {
+			return nil, err
+		}
+		parents = categoryParents(categories)
+	}
+	for _, v := range sale.Positions {
+		switch {
+		case promo.Product_id != nil:
+			if v.Product_id != *promo.Product_id {
+				continue
+			}
+		case promo.Category_id != nil:
+			ancestors, err := productAncestors(ctx, repo, parents, v.Product_id)
+			if err != nil {
+				return nil, err
+			}
+			if !containsID(ancestors, *promo.Category_id) {
+				continue
+			}
+		}
+		if v.Price > 0 {
+			eligible = append(eligible, v)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil, ErrPromoNotApplicable
+	}
+
+	if promo.Kind == PromoFixed {
+		eligible = shareFixed(sale, eligible, promo.Value)
+	} else {
+		for _, v := range eligible {
+			v.Promo_discount = v.Price * promo.Value / 100
+		}
+	}
+	for _, v := range eligible {
+		v.Price -= v.Promo_discount
+	}
+	if promoDiscount(sale) == 0 {
+		return nil, ErrPromoNotApplicable
+	}
+	return promo, nil
+}
+
+// shareFixed sets the unit discounts of the eligible positions of the sale
+// so that they add up to amount, or to all the positions are worth if that
+// is less. Each position gets its share in proportion to its amount and the
+// remainder goes a unit at a time to whole positions; what is still left is
+// less than the quantity of any position that can take more, so one of them
+// is split, the new position taking the last units one more off. It returns
+// the eligible positions with the one split off.
+func shareFixed(sale *Sale, eligible []*SalePosition, amount int) []*SalePosition {
+	total := 0
+	for _, v := range eligible {
+		total += v.Price * v.Qty
+	}
+	if amount > total {
+		amount = total
+	}
+
+	left := amount
+	for _, v := range eligible {
+		v.Promo_discount = amount * v.Price / total
+		left -= v.Promo_discount * v.Qty
+	}
+	for shared := true; left > 0 && shared; {
+		shared = false
+		for _, v := range eligible {
+			if v.Promo_discount < v.Price && v.Qty <= left {
+				v.Promo_discount++
+				left -= v.Qty
+				shared = true
+			}
+		}
+	}
+	if left == 0 {
+		return eligible
+	}
+
+	for i, v := range sale.Positions {
+		if !containsPosition(eligible, v) || v.Promo_discount == v.Price {
+			continue
+		}
+		split := *v
+		split.Qty, split.Promo_discount = left, v.Promo_discount+1
+		v.Qty -= left
+		sale.Positions = append(sale.Positions[:i+1], append([]*SalePosition{&split}, sale.Positions[i+1:]...)...)
+		return append(eligible, &split)
+	}
+	return eligible
+}
+
+func containsPosition(items []*SalePosition, item *SalePosition) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// promoDiscount is what the promo code took off the sale.
+func promoDiscount(sale *Sale) int {
+	discount := 0
+	for _, v := range sale.Positions {
+		discount += v.Promo_discount * v.Qty
+	}
+	return discount
+}
+
+func promoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,21 @@
+package managers
+
+import (
+	"context"
+	"log"
+
+	"github.com/khiki1995/crud/pkg/receipts"
+)
+
+func (s *Service) GetReceipt(ctx context.Context, saleID int64) (*receipts.Receipt, error) {
+	receipt, err := s.repo.SaleReceipt(ctx, saleID)
+	if err == receipts.ErrNotFound {
+		return nil, err
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	receipt.Complete(s.opts.Receipt)
+	return receipt, nil
+}
@@ -0,0 +1,279 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrBossNotFound = errors.New("no such boss")
+var ErrHierarchyCycle = errors.New("manager cannot report to own subordinate")
+var ErrReportForbidden = errors.New("manager is outside of your reporting tree")
+var ErrInvalidReport = errors.New("invalid report")
+
+// Groupings of the sales report.
+const (
+	ReportByDay      = "day"
+	ReportByWeek     = "week"
+	ReportByMonth    = "month"
+	ReportByProduct  = "product"
+	ReportByCustomer = "customer"
+	ReportByManager  = "manager"
+)
+
+// Period limits a report to sales and returns made within [From, To]; nil
+// bounds are open.
+type Period struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+// SalesTotals are sales and returns summed over a period; Net_total is the
+// sales total minus refunds.
+type SalesTotals struct {
+	Sales_count   int `json:"sales_count"`
+	Sales_total   int `json:"sales_total"`
+	Returns_total int `json:"returns_total"`
+	Net_total     int `json:"net_total"`
+}
+
+func (t *SalesTotals) add(other SalesTotals) {
+	t.Sales_count += other.Sales_count
+	t.Sales_total += other.Sales_total
+	t.Returns_total += other.Returns_total
+	t.Net_total += other.Net_total
+}
+
+// ManagerSales are the totals of a single manager.
+type ManagerSales struct {
+	Manager_id int64  `json:"manager_id"`
+	Name       string `json:"name"`
+	Boss_id    *int64 `json:"boss_id"`
+	Department string `json:"department"`
+	SalesTotals
+}
+
+// TeamReport covers a boss and everyone in their reporting tree; Total
+// includes the boss's own sales.
+type TeamReport struct {
+	Boss_id int64           `json:"boss_id"`
+	Period  Period          `json:"period"`
+	Total   SalesTotals     `json:"total"`
+	Members []*ManagerSales `json:"members"`
+}
+
+type DepartmentSales struct {
+	Department string `json:"department"`
+	Managers   int    `json:"managers"`
+	SalesTotals
+}
+
+type DepartmentsReport struct {
+	Period      Period             `json:"period"`
+	Total       SalesTotals        `json:"total"`
+	Departments []*DepartmentSales `json:"departments"`
+}
+
+// SalesReportQuery selects the sales report. Days, weeks (starting on
+// Monday) and months are calendar ones in Location.
+type SalesReportQuery struct {
+	Group_by string
+	Period   Period
+	Location *time.Location
+}
+
+// SalesReportRow is one group of the sales report. Key is the first day of
+// the day, week or month as YYYY-MM-DD or the id of the product, customer or
+// manager; Label is the date again or their name.
+type SalesReportRow struct {
+	Key         string `json:"key"`
+	Label       string `json:"label"`
+	Sales_count int    `json:"sales_count"`
+	Units       int    `json:"units"`
+	Revenue     int    `json:"revenue"`
+}
+
+// Team places a manager in the hierarchy.
+type Team struct {
+	Boss_id    *int64 `json:"boss_id"`
+	Department string `json:"department"`
+}
+
+// TeamReport reports on the tree of bossID on behalf of managerID, who must
+// be bossID or one of their bosses unless granted reports:all.
+func (s *Service) TeamReport(ctx context.Context, managerID int64, bossID int64, period Period) (*TeamReport, error) {
+	if bossID != managerID && !s.HasPermission(ctx, managerID, PermissionReportsAll) {
+		tree, err := s.repo.Subordinates(ctx, managerID)
+		if err != nil {
+			log.Print(err)
+			return nil, ErrInternal
+		}
+		if !containsID(tree, bossID) {
+			return nil, ErrReportForbidden
+		}
+	}
+
+	tree, err := s.repo.Subordinates(ctx, bossID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	if len(tree) == 0 {
+		return nil, ErrUserNotFound
+	}
+	summaries, err := s.repo.SalesByManager(ctx, period)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	report := &TeamReport{Boss_id: bossID, Period: period, Members: make([]*ManagerSales, 0, len(tree))}
+	for _, item := range summaries {
+		if containsID(tree, item.Manager_id) {
+			report.Members = append(report.Members, item)
+			report.Total.add(item.SalesTotals)
+		}
+	}
+	return report, nil
+}
+
+// DepartmentsReport rolls sales up by department on behalf of managerID;
+// managers without one are reported under an empty department name. Without
+// reports:all only the sales of the manager's own tree are counted.
+func (s *Service) DepartmentsReport(ctx context.Context, managerID int64, period Period) (*DepartmentsReport, error) {
+	tree, err := s.reportTree(ctx, managerID)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.repo.SalesByManager(ctx, period)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	report := &DepartmentsReport{Period: period, Departments: make([]*DepartmentSales, 0)}
+	byName := make(map[string]*DepartmentSales)
+	for _, item := range summaries {
+		if tree != nil && !containsID(tree, item.Manager_id) {
+			continue
+		}
+		department, ok := byName[item.Department]
+		if !ok {
+			department = &DepartmentSales{Department: item.Department}
+			byName[item.Department] = department
+			report.Departments = append(report.Departments, department)
+		}
+		department.Managers++
+		department.add(item.SalesTotals)
+		report.Total.add(item.SalesTotals)
+	}
+	sort.Slice(report.Departments, func(i, j int) bool {
+		return report.Departments[i].Department < report.Departments[j].Department
+	})
+	return report, nil
+}
+
+// SalesReport passes the rows of the report to fn in key order as the
+// storage produces them, so that large ranges need not fit in memory.
+// Grouped by manager on behalf of managerID without reports:all, it only
+// has the rows of the manager's own tree.
+func (s *Service) SalesReport(ctx context.Context, managerID int64, query *SalesReportQuery, fn func(row *SalesReportRow) error) error {
+	switch query.Group_by {
+	case ReportByDay, ReportByWeek, ReportByMonth, ReportByProduct, ReportByCustomer, ReportByManager:
+	default:
+		return ErrInvalidReport
+	}
+	if query.Location == nil {
+		query.Location = time.UTC
+	}
+	if query.Group_by == ReportByManager {
+		tree, err := s.reportTree(ctx, managerID)
+		if err != nil {
+			return err
+		}
+		if tree != nil {
+			send := fn
+			fn = func(row *SalesReportRow) error {
+				id, err := strconv.ParseInt(row.Key, 10, 64)
+				if err != nil || !containsID(tree, id) {
+					return err
+				}
+				return send(row)
+			}
+		}
+	}
+
+	err := s.repo.SalesReport(ctx, query, fn)
+	if err != nil {
+		log.Print(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// reportTree returns the managers whose sales managerID may see in reports,
+// or nil when they may see everyone's.
+func (s *Service) reportTree(ctx context.Context, managerID int64) ([]int64, error) {
+	if s.HasPermission(ctx, managerID, PermissionReportsAll) {
+		return nil, nil
+	}
+	tree, err := s.repo.Subordinates(ctx, managerID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return tree, nil
+}
+
+// SetTeam moves the manager under another boss and/or department on behalf
+// of adminID, refusing to make the hierarchy cyclic.
+func (s *Service) SetTeam(ctx context.Context, adminID int64, id int64, team *Team) (*Team, error) {
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		before, err := repo.ManagerTeam(ctx, id)
+		if err != nil {
+			return err
+		}
+		if team.Boss_id != nil {
+			tree, err := repo.Subordinates(ctx, id)
+			if err != nil {
+				return err
+			}
+			if containsID(tree, *team.Boss_id) {
+				return ErrHierarchyCycle
+			}
+			boss, err := repo.Subordinates(ctx, *team.Boss_id)
+			if err != nil {
+				return err
+			}
+			if len(boss) == 0 {
+				return ErrBossNotFound
+			}
+		}
+		err = repo.SetManagerTeam(ctx, id, team)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionManagerTeamChange, EntityManager, id, before, team)
+	})
+	switch err {
+	case nil:
+		return team, nil
+	case ErrUserNotFound, ErrBossNotFound, ErrHierarchyCycle:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
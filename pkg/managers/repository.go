@@ -0,0 +1,263 @@
+package managers
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/receipts"
+)
+
+// Managers stores manager accounts.
+type Managers interface {
+	CreateManager(ctx context.Context, reg *Registration) (*Manager, error)
+	ManagerPasswordByPhone(ctx context.Context, phone string) (id int64, hash string, err error)
+	Manager(ctx context.Context, id int64) (*Manager, error)
+	// AllManagers returns every manager ordered by id.
+	AllManagers(ctx context.Context) ([]*Manager, error)
+	ManagerRoles(ctx context.Context, id int64) ([]string, error)
+	SetManagerRoles(ctx context.Context, id int64, roles []string) error
+	ManagerTeam(ctx context.Context, id int64) (*Team, error)
+	SetManagerTeam(ctx context.Context, id int64, team *Team) error
+	// Subordinates returns the ids of the manager and everyone reporting to
+	// them directly or indirectly, or nothing if there is no such manager.
+	Subordinates(ctx context.Context, id int64) ([]int64, error)
+}
+
+// Tokens stores manager authentication tokens.
+type Tokens interface {
+	SaveManagerToken(ctx context.Context, token string, managerID int64, expire time.Time) error
+	ManagerToken(ctx context.Context, token string) (managerID int64, expire time.Time, err error)
+}
+
+// Products stores the product catalog and its stock.
+type Products interface {
+	CreateProduct(ctx context.Context, product *Product) error
+	UpdateProduct(ctx context.Context, product *Product) error
+	// Products returns at most filter.Limit+1 products matching filter in
+	// listing order, the extra one telling that there is a next page.
+	Products(ctx context.Context, filter *listing.ProductFilter) ([]*Product, error)
+	SearchProducts(ctx context.Context, search *listing.Search) ([]*ProductMatch, error)
+	// LockProducts returns the products with given ids ordered by id and
+	// keeps them locked until the surrounding transaction ends.
+	LockProducts(ctx context.Context, ids []int64) ([]*Product, error)
+	// AddProductQty changes the stock of the product by delta and returns
+	// the new stock; callers record the change with CreateStockMovement.
+	AddProductQty(ctx context.Context, id int64, delta int) (int, error)
+	// SetProductActive archives or restores the product; products are never
+	// deleted so that sales keep referring to them.
+	SetProductActive(ctx context.Context, id int64, active bool) (*Product, error)
+}
+
+// Stock stores the ledger of stock movements.
+type Stock interface {
+	CreateStockMovement(ctx context.Context, movement *StockMovement) error
+	StockMovements(ctx context.Context, productID int64) ([]*StockMovement, error)
+	StockDiscrepancies(ctx context.Context) ([]*StockDiscrepancy, error)
+}
+
+// Categories stores the category tree and product assignments.
+type Categories interface {
+	CreateCategory(ctx context.Context, category *Category) error
+	UpdateCategory(ctx context.Context, category *Category) error
+	// Categories returns every category ordered by name.
+	Categories(ctx context.Context) ([]*Category, error)
+	DeleteCategory(ctx context.Context, id int64) (*Category, error)
+	// SetProductCategories replaces the categories of the product.
+	SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error
+	ProductCategories(ctx context.Context, productID int64) ([]*Category, error)
+}
+
+// Customers gives managers access to customer accounts.
+type Customers interface {
+	UpdateCustomer(ctx context.Context, customer *customers.Customer) (*customers.Customer, error)
+	// Customers returns at most filter.Limit+1 customers matching filter.
+	Customers(ctx context.Context, filter *listing.CustomerFilter) ([]*customers.Customer, error)
+	Customer(ctx context.Context, id int64) (*customers.Customer, error)
+	// SetCustomerActive archives or restores the customer.
+	SetCustomerActive(ctx context.Context, id int64, active bool) (*customers.Customer, error)
+}
+
+// Sales stores sales together with their positions and status history.
+// Totals and reports count only sales holding stock, leaving out drafts
+// and cancelled orders.
+type Sales interface {
+	CreateSale(ctx context.Context, sale *Sale) error
+	SalesTotal(ctx context.Context, managerID int64) (int, error)
+	// SalesByManager returns the totals of every manager over period,
+	// ordered by manager id.
+	SalesByManager(ctx context.Context, period Period) ([]*ManagerSales, error)
+	// ManagerSales returns the sales of the manager over period with their
+	// positions, ordered by id.
+	ManagerSales(ctx context.Context, managerID int64, period Period) ([]*Sale, error)
+	// SaleReceipt returns the sale as printed, without totals.
+	SaleReceipt(ctx context.Context, id int64) (*receipts.Receipt, error)
+	// SalesReport calls fn for every row of the report in key order,
+	// stopping at the first error.
+	SalesReport(ctx context.Context, query *SalesReportQuery, fn func(row *SalesReportRow) error) error
+	// SalesByStatus returns the sales in the status with their positions,
+	// ordered by id.
+	SalesByStatus(ctx context.Context, status string) ([]*Sale, error)
+	// SetSaleStatus changes the status of a sale locked with LockSale.
+	SetSaleStatus(ctx context.Context, id int64, status string) error
+	CreateStatusChange(ctx context.Context, change *StatusChange) error
+	// StatusHistory returns the status changes of the sale, oldest first.
+	StatusHistory(ctx context.Context, saleID int64) ([]*StatusChange, error)
+}
+
+// Carts stores the carts of customers.
+type Carts interface {
+	// CartItems returns the cart of the customer with the current name,
+	// price, stock and state of every product, ordered by product id.
+	CartItems(ctx context.Context, customerID int64) ([]*CartItem, error)
+	// SetCartItem puts the product into the cart or changes its quantity.
+	SetCartItem(ctx context.Context, customerID int64, productID int64, qty int) error
+	DeleteCartItem(ctx context.Context, customerID int64, productID int64) error
+	ClearCart(ctx context.Context, customerID int64) error
+}
+
+// Returns stores returns of previously sold positions.
+type Returns interface {
+	// LockSale returns the sale with its positions and keeps it locked
+	// until the surrounding transaction ends.
+	LockSale(ctx context.Context, id int64) (*Sale, error)
+	// ReturnedQty maps sale position ids to the quantity already returned.
+	ReturnedQty(ctx context.Context, saleID int64) (map[int64]int, error)
+	CreateReturn(ctx context.Context, ret *Return) error
+	Returns(ctx context.Context, saleID int64) ([]*Return, error)
+}
+
+// Payments stores the payments taken for sales.
+type Payments interface {
+	CreatePayment(ctx context.Context, payment *Payment) error
+	// UpdatePayment saves the gateway reference, refunded amount and status
+	// of the payment.
+	UpdatePayment(ctx context.Context, payment *Payment) error
+	// LockPayment returns the payment and keeps it locked until the
+	// surrounding transaction ends.
+	LockPayment(ctx context.Context, id int64) (*Payment, error)
+	// PaymentByReference finds and locks a card payment by its gateway
+	// reference.
+	PaymentByReference(ctx context.Context, provider string, reference string) (*Payment, error)
+	// SalePayments returns the payments of the sale ordered by id.
+	SalePayments(ctx context.Context, saleID int64) ([]*Payment, error)
+	// Reconciliation returns the totals, returns and payments of the sales
+	// made over period, ordered by sale id, without differences.
+	Reconciliation(ctx context.Context, period Period) ([]*Reconciliation, error)
+}
+
+// Wallets stores the stored value of customers as a ledger.
+type Wallets interface {
+	// LockWallet returns the balance of the customer wallet, opening an
+	// empty one if needed, and keeps it locked until the surrounding
+	// transaction ends.
+	LockWallet(ctx context.Context, customerID int64) (int, error)
+	// CreateWalletTransaction stores the transaction with its entries and
+	// sets the balance of the locked wallet to transaction.Balance.
+	CreateWalletTransaction(ctx context.Context, transaction *WalletTransaction) error
+	// WalletTransactions returns the transactions of the customer with their
+	// entries, newest first.
+	WalletTransactions(ctx context.Context, customerID int64) ([]*WalletTransaction, error)
+}
+
+// Loyalty stores the loyalty rules and the points accounts of customers.
+type Loyalty interface {
+	CreateLoyaltyRule(ctx context.Context, rule *LoyaltyRule) error
+	// LoyaltyRules returns every rule ordered by id.
+	LoyaltyRules(ctx context.Context) ([]*LoyaltyRule, error)
+	DeleteLoyaltyRule(ctx context.Context, id int64) (*LoyaltyRule, error)
+	// LockPoints returns the points balance of the customer, opening an
+	// empty account if needed, and keeps it locked until the surrounding
+	// transaction ends.
+	LockPoints(ctx context.Context, customerID int64) (int, error)
+	// CreatePointsTransaction stores the transaction and sets the balance of
+	// the locked account to transaction.Balance.
+	CreatePointsTransaction(ctx context.Context, transaction *PointsTransaction) error
+	// PointLots returns the earn and refund transactions of the customer
+	// with points remaining, oldest first.
+	PointLots(ctx context.Context, customerID int64) ([]*PointsTransaction, error)
+	SetLotRemaining(ctx context.Context, id int64, remaining int) error
+	// PointsTransactions returns the transactions of the customer, newest
+	// first.
+	PointsTransactions(ctx context.Context, customerID int64) ([]*PointsTransaction, error)
+	// SalePoints returns the points earned by the sale.
+	SalePoints(ctx context.Context, saleID int64) (int, error)
+	// CustomerSpend returns what the customer paid for the sales counted in
+	// totals made since since, less returns.
+	CustomerSpend(ctx context.Context, customerID int64, since time.Time) (int, error)
+}
+
+// Promos stores promo codes and their uses. Uses and Discount of the promo
+// codes returned count the uses for sales that were not cancelled.
+type Promos interface {
+	// CreatePromo fails with ErrPromoCodeUsed if the code exists.
+	CreatePromo(ctx context.Context, promo *Promo) error
+	// Promos returns every promo code ordered by id.
+	Promos(ctx context.Context) ([]*Promo, error)
+	Promo(ctx context.Context, id int64) (*Promo, error)
+	// LockPromo returns the promo code with the given code and keeps it
+	// locked until the surrounding transaction ends.
+	LockPromo(ctx context.Context, code string) (*Promo, error)
+	SetPromoActive(ctx context.Context, id int64, active bool) error
+	// CustomerPromoUses counts the uses of the promo code by the customer
+	// for sales that were not cancelled.
+	CustomerPromoUses(ctx context.Context, promoID int64, customerID int64) (int, error)
+	CreatePromoRedemption(ctx context.Context, redemption *PromoRedemption) error
+	// PromoRedemptions returns the uses of the promo code, newest first.
+	PromoRedemptions(ctx context.Context, promoID int64) ([]*PromoRedemption, error)
+}
+
+// Audit stores the audit log of manager actions.
+type Audit interface {
+	CreateAuditEntry(ctx context.Context, entry *AuditEntry) error
+	// AuditEntries returns entries matching filter, newest first.
+	AuditEntries(ctx context.Context, filter *AuditFilter) ([]*AuditEntry, error)
+}
+
+// Plans stores the history of sales targets.
+type Plans interface {
+	CreatePlan(ctx context.Context, plan *Plan) error
+	// Plans returns every target ever set for the manager, newest first.
+	Plans(ctx context.Context, managerID int64) ([]*Plan, error)
+	// PlanTargets maps every manager id to the latest target for the period
+	// starting at start, falling back to managers.plan per month.
+	PlanTargets(ctx context.Context, period string, start time.Time) (map[int64]int, error)
+}
+
+// Payroll stores commission rules and finalized payroll statements.
+type Payroll interface {
+	CreateCommissionRule(ctx context.Context, rule *CommissionRule) error
+	// CommissionRules returns every rule ordered by id.
+	CommissionRules(ctx context.Context) ([]*CommissionRule, error)
+	DeleteCommissionRule(ctx context.Context, id int64) (*CommissionRule, error)
+	CreateStatement(ctx context.Context, statement *Statement) error
+	// Statement returns the finalized statement with its lines.
+	Statement(ctx context.Context, managerID int64, month time.Time) (*Statement, error)
+	// Statements returns the finalized statements of the month with their
+	// lines, ordered by manager id.
+	Statements(ctx context.Context, month time.Time) ([]*Statement, error)
+}
+
+// Repository is the storage managers.Service depends on.
+type Repository interface {
+	Managers
+	Tokens
+	Products
+	Stock
+	Categories
+	Customers
+	Sales
+	Carts
+	Returns
+	Payments
+	Wallets
+	Loyalty
+	Promos
+	Audit
+	Plans
+	Payroll
+	// WithTx runs fn against a repository bound to a single transaction,
+	// committing if fn returns nil and rolling back otherwise.
+	WithTx(ctx context.Context, fn func(repo Repository) error) error
+}
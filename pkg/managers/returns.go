@@ -0,0 +1,126 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrSaleNotFound = errors.New("no such sale")
+var ErrEmptyReturn = errors.New("return has no positions")
+var ErrPositionNotFound = errors.New("no such position in sale")
+var ErrReturnQtyExceeded = errors.New("return quantity exceeds sold quantity")
+
+type ReturnPosition struct {
+	ID               int64 `json:"id"`
+	Sale_position_id int64 `json:"sale_position_id"`
+	Product_id       int64 `json:"product_id"`
+	Qty              int   `json:"qty"`
+	Price            int   `json:"price"`
+}
+
+type Return struct {
+	ID         int64             `json:"id"`
+	Sale_id    int64             `json:"sale_id"`
+	Manager_id int64             `json:"manager_id"`
+	Reason     string            `json:"reason"`
+	Refund     int               `json:"refund"`
+	Created    time.Time         `json:"created"`
+	Positions  []*ReturnPosition `json:"positions"`
+}
+
+// MakeReturn records the return of some of the sold positions of a sale,
+// puts the returned items back in stock and computes the refund from the
+// prices the items were sold at. The loyalty points redeemed for the items
+// are given back and those the sale earned taken back in proportion.
+func (s *Service) MakeReturn(ctx context.Context, ret *Return) (*Return, error) {
+	if len(ret.Positions) == 0 {
+		return nil, ErrEmptyReturn
+	}
+	for _, v := range ret.Positions {
+		if v.Qty <= 0 {
+			return nil, ErrInvalidQty
+		}
+	}
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		sale, err := repo.LockSale(ctx, ret.Sale_id)
+		if err != nil {
+			return err
+		}
+		if sale.Status != SaleFulfilled {
+			return ErrSaleNotFulfilled
+		}
+		returned, err := repo.ReturnedQty(ctx, sale.ID)
+		if err != nil {
+			return err
+		}
+
+		sold := make(map[int64]*SalePosition)
+		for _, position := range sale.Positions {
+			sold[position.ID] = position
+		}
+		ret.Refund = 0
+		for _, v := range ret.Positions {
+			position, ok := sold[v.Sale_position_id]
+			if !ok {
+				return ErrPositionNotFound
+			}
+			returned[position.ID] += v.Qty
+			if returned[position.ID] > position.Qty {
+				return ErrReturnQtyExceeded
+			}
+			v.Product_id = position.Product_id
+			v.Price = position.Price
+			ret.Refund += v.Qty * v.Price
+		}
+
+		err = repo.CreateReturn(ctx, ret)
+		if err != nil {
+			return err
+		}
+		for _, v := range ret.Positions {
+			err = moveStock(ctx, repo, &StockMovement{
+				Product_id: v.Product_id,
+				Manager_id: ret.Manager_id,
+				Kind:       MovementReturn,
+				Qty:        v.Qty,
+				Reason:     ret.Reason,
+				Sale_id:    &ret.Sale_id,
+				Return_id:  &ret.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = s.refundPoints(ctx, repo, sale, ret)
+		if err != nil {
+			return err
+		}
+		err = revokePoints(ctx, repo, sale, ret)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, ret.Manager_id, ActionReturnCreate, EntityReturn, ret.ID, nil, ret)
+	})
+	switch err {
+	case nil:
+		return ret, nil
+	case ErrSaleNotFound, ErrSaleNotFulfilled, ErrPositionNotFound, ErrReturnQtyExceeded:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// GetReturns lists returns of the given sale, or all returns if saleID is 0.
+func (s *Service) GetReturns(ctx context.Context, saleID int64) ([]*Return, error) {
+	items, err := s.repo.Returns(ctx, saleID)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
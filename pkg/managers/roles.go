@@ -0,0 +1,187 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+)
+
+var ErrInvalidRole = errors.New("unknown role")
+var ErrSelfDemotion = errors.New("admin cannot revoke own admin role")
+
+// Permissions checked by the HTTP layer before a handler runs, and by the
+// service for actions that depend on the request body.
+const (
+	PermissionProductsRead    = "products:read"
+	PermissionProductsWrite   = "products:write"
+	PermissionProductsDelete  = "products:delete"
+	PermissionStockWrite      = "stock:write"
+	PermissionStockAdjust     = "stock:adjust"
+	PermissionCustomersRead   = "customers:read"
+	PermissionCustomersWrite  = "customers:write"
+	PermissionCustomersDelete = "customers:delete"
+	PermissionSalesCreate     = "sales:create"
+	PermissionSalesDiscount   = "sales:discount"
+	PermissionReturnsCreate   = "returns:create"
+	PermissionReportsRead     = "reports:read"
+	PermissionReportsAll      = "reports:all"
+	PermissionManagersWrite   = "managers:write"
+	PermissionAuditRead       = "audit:read"
+	PermissionPayrollManage   = "payroll:manage"
+	PermissionLoyaltyManage   = "loyalty:manage"
+	PermissionPromosManage    = "promos:manage"
+)
+
+const (
+	RoleAdmin   = "ADMIN"
+	RoleManager = "MANAGER"
+	RoleCashier = "CASHIER"
+)
+
+// RolePermissions maps every known role to the permissions it grants; a
+// manager has the union of the permissions of their roles.
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionProductsRead, PermissionProductsWrite, PermissionProductsDelete,
+		PermissionStockWrite, PermissionStockAdjust,
+		PermissionCustomersRead, PermissionCustomersWrite, PermissionCustomersDelete,
+		PermissionSalesCreate, PermissionSalesDiscount, PermissionReturnsCreate,
+		PermissionReportsRead, PermissionReportsAll, PermissionManagersWrite, PermissionAuditRead,
+		PermissionPayrollManage, PermissionLoyaltyManage, PermissionPromosManage,
+	},
+	RoleManager: {
+		PermissionProductsRead, PermissionProductsWrite, PermissionProductsDelete,
+		PermissionStockWrite,
+		PermissionCustomersRead, PermissionCustomersWrite,
+		PermissionSalesCreate, PermissionReturnsCreate,
+		PermissionReportsRead,
+	},
+	RoleCashier: {
+		PermissionProductsRead,
+		PermissionCustomersRead, PermissionCustomersWrite,
+		PermissionSalesCreate, PermissionReturnsCreate,
+	},
+}
+
+// Role describes a role for the admin endpoints.
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// Permissions returns the permissions granted to the manager by their roles.
+func (s *Service) Permissions(ctx context.Context, id int64) ([]string, error) {
+	roles, err := s.repo.ManagerRoles(ctx, id)
+	if err == ErrUserNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+
+	granted := make(map[string]bool)
+	for _, role := range roles {
+		for _, permission := range RolePermissions[role] {
+			granted[permission] = true
+		}
+	}
+	permissions := make([]string, 0, len(granted))
+	for permission := range granted {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// HasPermission reports whether the manager is granted permission.
+func (s *Service) HasPermission(ctx context.Context, id int64, permission string) bool {
+	permissions, err := s.Permissions(ctx, id)
+	if err != nil {
+		return false
+	}
+	for _, v := range permissions {
+		if v == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) GetRoles(ctx context.Context) []*Role {
+	items := make([]*Role, 0, len(RolePermissions))
+	for name, permissions := range RolePermissions {
+		items = append(items, &Role{Name: name, Permissions: permissions})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items
+}
+
+func (s *Service) GetManagerRoles(ctx context.Context, id int64) ([]string, error) {
+	roles, err := s.repo.ManagerRoles(ctx, id)
+	if err == ErrUserNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return roles, nil
+}
+
+// SetManagerRoles replaces the roles of the manager id on behalf of adminID;
+// admins cannot drop their own admin role so that one always remains.
+func (s *Service) SetManagerRoles(ctx context.Context, adminID int64, id int64, roles []string) ([]string, error) {
+	roles, err := normalizeRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+	if adminID == id && !containsString(roles, RoleAdmin) {
+		return nil, ErrSelfDemotion
+	}
+
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		before, err := repo.ManagerRoles(ctx, id)
+		if err != nil {
+			return err
+		}
+		err = repo.SetManagerRoles(ctx, id, roles)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, adminID, ActionManagerRolesChange, EntityManager, id,
+			map[string]interface{}{"roles": before}, map[string]interface{}{"roles": roles})
+	})
+	if err == ErrUserNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return roles, nil
+}
+
+// normalizeRoles checks that every role is known and removes duplicates.
+func normalizeRoles(roles []string) ([]string, error) {
+	items := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if _, ok := RolePermissions[role]; !ok {
+			return nil, ErrInvalidRole
+		}
+		if !containsString(items, role) {
+			items = append(items, role)
+		}
+	}
+	return items, nil
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -6,13 +6,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"log"
-	"strconv"
 	"time"
 
 	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/payments"
+	"github.com/khiki1995/crud/pkg/receipts"
 
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,6 +22,16 @@ var ErrPhoneUsed = errors.New("phone already registered")
 var ErrTokenExpired = errors.New("expired")
 var ErrTokenNotFound = errors.New("expired")
 var ErrPasswordInvalid = errors.New("invalid password")
+var ErrEmptySale = errors.New("sale has no positions")
+var ErrInvalidQty = errors.New("invalid quantity")
+var ErrProductNotFound = errors.New("no such product")
+var ErrInvalidProduct = errors.New("invalid product")
+var ErrProductInactive = errors.New("product is not active")
+var ErrInsufficientStock = errors.New("insufficient stock")
+var ErrInvalidDiscount = errors.New("invalid discount")
+var ErrDiscountForbidden = errors.New("discount not permitted")
+var ErrCustomerInactive = errors.New("customer is archived")
+var ErrInvalidCustomer = errors.New("invalid customer")
 
 type Auth struct {
 	Login    string `json:"login"`
@@ -31,8 +41,8 @@ type Auth struct {
 type Manager struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
-	Salary      string    `json:"salary"`
-	Plan        string    `json:"plan"`
+	Salary      int       `json:"salary"`
+	Plan        int       `json:"plan"`
 	Boss_id     int64     `json:"boss_id"`
 	Departament string    `json:"departament"`
 	Phone       string    `json:"phone"`
@@ -43,10 +53,14 @@ type Manager struct {
 }
 
 type Registration struct {
-	ID    int64    `json:"id"`
-	Name  string   `json:"name"`
-	Phone string   `json:"phone"`
-	Roles []string `json:"roles"`
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Phone      string   `json:"phone"`
+	Roles      []string `json:"roles"`
+	Boss_id    *int64   `json:"boss_id"`
+	Department string   `json:"department"`
+	Salary     int      `json:"salary"`
+	Plan       int      `json:"plan"`
 }
 
 type Product struct {
@@ -57,37 +71,87 @@ type Product struct {
 	Active  bool      `json:"active"`
 	Created time.Time `json:"created"`
 }
+
+type ProductsPage struct {
+	Items      []*Product `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ProductMatch is a product found by full-text search; Highlight is the
+// product name with matched words wrapped in <b></b>.
+type ProductMatch struct {
+	*Product
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+type CustomersPage struct {
+	Items      []*customers.Customer `json:"items"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// SalePosition is priced by MakeSale: Base_price is the catalog price at the
+// moment of sale and Price the unit price after the manual discount, if any,
+// the promo code discount, Promo_discount, and the part of it paid with
+// loyalty points, Points_discount. Name is the product name at the moment of
+// sale.
 type SalePosition struct {
-	ID         int64 `json:"id"`
-	Product_id int64 `json:"product_id"`
-	Qty        int   `json:"qty"`
-	Price      int   `json:"price"`
+	ID               int64  `json:"id"`
+	Product_id       int64  `json:"product_id"`
+	Name             string `json:"name"`
+	Qty              int    `json:"qty"`
+	Base_price       int    `json:"base_price"`
+	Discount_percent int    `json:"discount_percent"`
+	Discount_amount  int    `json:"discount_amount"`
+	Promo_discount   int    `json:"promo_discount"`
+	Points_discount  int    `json:"points_discount"`
+	Price            int    `json:"price"`
 }
+
+// Sale is a sale or an order. Wallet is the part of it MakeSale takes from
+// the wallet of the customer; it becomes a wallet payment and is not kept
+// with the sale. Points are the loyalty points redeemed for it and Promo the
+// promo code applied to it.
 type Sale struct {
 	ID          int64           `json:"id"`
+	Receipt_no  int64           `json:"receipt_no"`
 	Manager_id  int64           `json:"manager_id"`
 	Customer_id int64           `json:"customer_id"`
+	Status      string          `json:"status"`
+	Wallet      int             `json:"wallet,omitempty"`
+	Points      int             `json:"points,omitempty"`
+	Promo       string          `json:"promo,omitempty"`
 	Created     time.Time       `json:"created"`
 	Positions   []*SalePosition `json:"positions"`
 }
 
 type Service struct {
-	pool *pgxpool.Pool
+	repo Repository
+	opts Options
 }
 
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+type Options struct {
+	TokenTTL time.Duration
+	Receipt  receipts.Options
+	// Payments is the gateway card tenders go through.
+	Payments payments.Provider
+	Loyalty  LoyaltyOptions
 }
 
-func (s *Service) GetToken(ctx context.Context, phone string, password string) (token string, err error) {
-	var hash string
-	var id int64
-	err = s.pool.QueryRow(ctx, `SELECT id, password FROM managers WHERE phone = $1`, phone).Scan(&id, &hash)
+func NewService(repo Repository, opts Options) *Service {
+	if opts.Loyalty.PointValue <= 0 {
+		opts.Loyalty.PointValue = 1
+	}
+	return &Service{repo: repo, opts: opts}
+}
 
-	if err == pgx.ErrNoRows {
+func (s *Service) GetToken(ctx context.Context, phone string, password string) (token string, err error) {
+	id, hash, err := s.repo.ManagerPasswordByPhone(ctx, phone)
+	if err == ErrUserNotFound {
 		return "", ErrUserNotFound
 	}
 	if err != nil {
+		log.Print(err)
 		return "", ErrInternal
 	}
 	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
@@ -95,30 +159,25 @@ func (s *Service) GetToken(ctx context.Context, phone string, password string) (
 		return "", ErrPasswordInvalid
 	}
 
-	buffer := make([]byte, 256)
-	n, err := rand.Read(buffer)
-	if n != len(buffer) || err != nil {
+	token, err = generateToken()
+	if err != nil {
 		return "", ErrInternal
 	}
-
-	token = hex.EncodeToString(buffer)
-	_, err = s.pool.Exec(ctx, `INSERT INTO managers_tokens(token, manager_id) VALUES($1, $2)`, token, id)
+	err = s.repo.SaveManagerToken(ctx, token, id, time.Now().Add(s.opts.TokenTTL))
 	if err != nil {
+		log.Print(err)
 		return "", ErrInternal
 	}
 	return token, nil
 }
 
 func (s *Service) IDByToken(ctx context.Context, token string) (id int64, err error) {
-	expireTime := time.Now()
-	err = s.pool.QueryRow(ctx, `
-		SELECT manager_id, expire FROM managers_tokens WHERE token = $1
-	`, token).Scan(&id, &expireTime)
-
-	if err == pgx.ErrNoRows {
+	id, expireTime, err := s.repo.ManagerToken(ctx, token)
+	if err == ErrTokenNotFound {
 		return 0, nil
 	}
 	if err != nil {
+		log.Print(err)
 		return 0, ErrInternal
 	}
 	if time.Now().After(expireTime) {
@@ -128,41 +187,61 @@ func (s *Service) IDByToken(ctx context.Context, token string) (id int64, err er
 	return id, nil
 }
 
-func (s *Service) Register(ctx context.Context, reg *Registration) (string, error) {
-	item := &Manager{}
-	err := s.pool.QueryRow(ctx, `
-		INSERT INTO managers (name, phone, roles) 
-		VALUES ($1, $2, $3) 
-		ON CONFLICT (phone) DO NOTHING 
-		RETURNING id, name, phone, active, created
-	`, reg.Name, reg.Phone, reg.Roles).Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
+// Register creates a manager with the given roles, MANAGER if none given,
+// on behalf of the admin adminID.
+func (s *Service) Register(ctx context.Context, adminID int64, reg *Registration) (token string, err error) {
+	if len(reg.Roles) == 0 {
+		reg.Roles = []string{RoleManager}
+	}
+	reg.Roles, err = normalizeRoles(reg.Roles)
 	if err != nil {
-		log.Print(err)
-		return "", ErrInternal
+		return "", err
 	}
-
-	buffer := make([]byte, 256)
-	n, err := rand.Read(buffer)
-	if n != len(buffer) || err != nil {
-		return "", ErrInternal
+	if reg.Salary < 0 || reg.Plan < 0 {
+		return "", ErrInvalidPlan
 	}
 
-	token := hex.EncodeToString(buffer)
-	_, err = s.pool.Exec(ctx, `INSERT INTO managers_tokens(token, manager_id) VALUES($1, $2)`, token, item.ID)
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		if reg.Boss_id != nil {
+			boss, err := repo.Subordinates(ctx, *reg.Boss_id)
+			if err != nil {
+				return err
+			}
+			if len(boss) == 0 {
+				return ErrBossNotFound
+			}
+		}
+		item, err := repo.CreateManager(ctx, reg)
+		if err != nil {
+			return err
+		}
+		err = audit(ctx, repo, adminID, ActionManagerRegister, EntityManager, item.ID, nil, item)
+		if err != nil {
+			return err
+		}
+		token, err = generateToken()
+		if err != nil {
+			return err
+		}
+		return repo.SaveManagerToken(ctx, token, item.ID, time.Now().Add(s.opts.TokenTTL))
+	})
+	if err == ErrPhoneUsed || err == ErrBossNotFound {
+		return "", err
+	}
 	if err != nil {
+		log.Print(err)
 		return "", ErrInternal
 	}
 	return token, nil
 }
 
 func (s *Service) AuthentificateManager(ctx context.Context, token string) (id int64, err error) {
-	expireTime := time.Now()
-	err = s.pool.QueryRow(ctx, `SELECT manager_id, expire FROM managers_tokens WHERE token = $1`, token).Scan(&id, &expireTime)
-
-	if err == pgx.ErrNoRows {
+	id, expireTime, err := s.repo.ManagerToken(ctx, token)
+	if err == ErrTokenNotFound {
 		return 0, ErrUserNotFound
 	}
 	if err != nil {
+		log.Print(err)
 		return 0, ErrInternal
 	}
 	if time.Now().After(expireTime) {
@@ -171,70 +250,258 @@ func (s *Service) AuthentificateManager(ctx context.Context, token string) (id i
 	return id, nil
 }
 
-func (s *Service) SaveProduct(ctx context.Context, product *Product) (*Product, error) {
-	if product.ID == 0 {
-		err := s.pool.QueryRow(ctx, `
-			INSERT INTO products (name, price, qty) VALUES ($1, $2, $3)
-			RETURNING id, active, created
-			`, product.Name, product.Price, product.Qty).Scan(&product.ID, &product.Active, &product.Created)
+// SaveProduct creates a product when its id is 0 and updates it otherwise.
+// The initial qty of a new product is posted as a receipt; later stock
+// changes go through the ledger only, so qty is ignored on update.
+func (s *Service) SaveProduct(ctx context.Context, managerID int64, product *Product) (*Product, error) {
+	if product.Price <= 0 || product.Qty < 0 {
+		return nil, ErrInvalidProduct
+	}
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		if product.ID != 0 {
+			before, err := repo.LockProducts(ctx, []int64{product.ID})
+			if err != nil {
+				return err
+			}
+			if len(before) == 0 {
+				return ErrProductNotFound
+			}
+			err = repo.UpdateProduct(ctx, product)
+			if err != nil {
+				return err
+			}
+			return audit(ctx, repo, managerID, ActionProductUpdate, EntityProduct, product.ID, before[0], product)
+		}
+
+		initial := product.Qty
+		product.Qty = 0
+		err := repo.CreateProduct(ctx, product)
 		if err != nil {
-			return nil, ErrInternal
+			return err
+		}
+		if initial > 0 {
+			movement := &StockMovement{
+				Product_id: product.ID,
+				Manager_id: managerID,
+				Kind:       MovementReceipt,
+				Qty:        initial,
+				Reason:     "initial stock",
+			}
+			err = moveStock(ctx, repo, movement)
+			if err != nil {
+				return err
+			}
+			product.Qty = movement.Balance
 		}
-		return product, nil
+		return audit(ctx, repo, managerID, ActionProductCreate, EntityProduct, product.ID, nil, product)
+	})
+	if err == ErrProductNotFound {
+		return nil, ErrProductNotFound
 	}
-
-	err := s.pool.QueryRow(ctx, `
-		UPDATE  products SET name = $1, price = $2, qty = $3
-		WHERE id = $4 RETURNING id, active, created`,
-		product.Name, product.Price, product.Qty, product.ID).Scan(&product.ID, &product.Active, &product.Created)
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
 	return product, nil
 }
 
+// MakeSale records a sale handed over on the spot, or a draft order when the
+// status is draft; drafts are priced now but take no stock until confirmed.
+// A sale may be paid from the wallet of the customer as it is made, the
+// wallet balance being checked in the same transaction, and with loyalty
+// points, which come off the prices after the promo code, if any.
 func (s *Service) MakeSale(ctx context.Context, sale *Sale) (*Sale, error) {
-	active := false
-	qty := 0
-	err := s.pool.QueryRow(ctx, `
-		INSERT INTO sales (manager_id, customer_id) VALUES ($1, $2)
-		RETURNING id, created
-		`, sale.Manager_id, sale.Customer_id).Scan(&sale.ID, &sale.Created)
+	if len(sale.Positions) == 0 {
+		return nil, ErrEmptySale
+	}
+	switch sale.Status {
+	case "":
+		sale.Status = SaleFulfilled
+	case SaleDraft, SaleFulfilled:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	if sale.Wallet < 0 {
+		return nil, ErrInvalidPayment
+	}
+	if sale.Points < 0 {
+		return nil, ErrInvalidDiscount
+	}
+	if (sale.Wallet > 0 || sale.Points > 0) && sale.Status == SaleDraft {
+		return nil, ErrSaleNotPayable
+	}
+	sale.Promo = promoCode(sale.Promo)
+	err := s.checkPositions(ctx, sale)
 	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.WithTx(ctx, func(repo Repository) error {
+		err := s.placeSale(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+		err = audit(ctx, repo, sale.Manager_id, ActionSaleCreate, EntitySale, sale.ID, nil, sale)
+		if err != nil || sale.Wallet == 0 {
+			return err
+		}
+
+		total := 0
+		for _, v := range sale.Positions {
+			total += v.Price * v.Qty
+		}
+		if sale.Wallet > total {
+			return ErrPaymentExceedsDue
+		}
+		payment, err := payFromWallet(ctx, repo, sale.Manager_id, sale, sale.Wallet)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, sale.Manager_id, ActionPaymentCreate, EntityPayment, payment.ID, nil, payment)
+	})
+	switch err {
+	case nil:
+		return sale, nil
+	case ErrProductNotFound, ErrProductInactive, ErrInsufficientStock, ErrInvalidDiscount,
+		customers.ErrUserNotFound, ErrCustomerInactive, ErrPaymentExceedsDue, ErrInsufficientFunds,
+		ErrInsufficientPoints, ErrPromoNotFound, ErrPromoNotActive, ErrPromoExhausted, ErrPromoMinBasket,
+		ErrPromoNotApplicable:
+		return nil, err
+	default:
 		log.Print(err)
 		return nil, ErrInternal
 	}
-	positionsQuery := "INSERT INTO sales_positions(sale_id, product_id, qty, price) VALUES "
+}
+
+// checkPositions validates the quantities and manual discounts of the
+// positions; only managers allowed to may give manual discounts.
+func (s *Service) checkPositions(ctx context.Context, sale *Sale) error {
+	discounted := false
 	for _, v := range sale.Positions {
-		if err := s.pool.QueryRow(ctx, `SELECT qty, active from products where id = $1`, v.Product_id).Scan(&qty, &active); err != nil {
-			return nil, ErrInternal
+		if v.Qty <= 0 {
+			return ErrInvalidQty
 		}
-		if qty < v.Qty || !active {
-			return nil, ErrInternal
+		if v.Discount_percent < 0 || v.Discount_percent > 100 || v.Discount_amount < 0 ||
+			(v.Discount_percent > 0 && v.Discount_amount > 0) {
+			return ErrInvalidDiscount
 		}
-		if _, err := s.pool.Exec(ctx, `UPDATE products set qty = $1 where id = $2`, qty-v.Qty, v.Product_id); err != nil {
-			return nil, ErrInternal
+		if v.Discount_percent > 0 || v.Discount_amount > 0 {
+			discounted = true
 		}
-		positionsQuery += "(" + strconv.FormatInt(sale.ID, 10) + "," + strconv.FormatInt(v.Product_id, 10) + "," + strconv.Itoa(v.Qty) + "," + strconv.Itoa(v.Price) + "),"
 	}
-	positionsQuery = positionsQuery[:len(positionsQuery)-1] + ";"
-	_, err = s.pool.Exec(ctx, positionsQuery)
+	if discounted && !s.HasPermission(ctx, sale.Manager_id, PermissionSalesDiscount) {
+		return ErrDiscountForbidden
+	}
+	return nil
+}
+
+// placeSale prices the positions of a validated sale at the current product
+// prices, applying the promo code and redeeming the loyalty points asked
+// for, and stores the sale in its initial status, taking the products off
+// stock unless it is a draft. Fulfilled sales earn points at once.
+// Manager_id is 0 for sales made by customers.
+func (s *Service) placeSale(ctx context.Context, repo Repository, sale *Sale) error {
+	products, err := priceSale(ctx, repo, sale)
 	if err != nil {
-		log.Print(err)
-		return nil, ErrInternal
+		return err
+	}
+	var promo *Promo
+	if sale.Promo != "" {
+		promo, err = applyPromo(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+	}
+	if sale.Points > 0 {
+		err = s.priceWithPoints(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = repo.CreateSale(ctx, sale)
+	if err != nil {
+		return err
+	}
+	if promo != nil {
+		err = repo.CreatePromoRedemption(ctx, &PromoRedemption{
+			Promo_id:    promo.ID,
+			Customer_id: sale.Customer_id,
+			Sale_id:     sale.ID,
+			Discount:    promoDiscount(sale),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if sale.Points > 0 {
+		err = spendPoints(ctx, repo, &PointsTransaction{
+			Customer_id: sale.Customer_id,
+			Kind:        PointsRedeemed,
+			Points:      -sale.Points,
+			Sale_id:     &sale.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if sale.Status != SaleDraft {
+		kind := MovementSale
+		if sale.Status == SaleConfirmed {
+			kind = MovementReserve
+		}
+		err = takeStock(ctx, repo, sale, products, kind, sale.Manager_id)
+		if err != nil {
+			return err
+		}
+	}
+	if sale.Status == SaleFulfilled {
+		err = earnPoints(ctx, repo, sale)
+		if err != nil {
+			return err
+		}
 	}
+	return repo.CreateStatusChange(ctx, &StatusChange{
+		Sale_id:    sale.ID,
+		Status:     sale.Status,
+		Manager_id: sale.Manager_id,
+	})
+}
 
-	return sale, nil
+// priceSale checks the customer of the sale and prices its positions at the
+// current prices of the products, which it locks and returns, less the
+// manual discounts.
+func priceSale(ctx context.Context, repo Repository, sale *Sale) ([]*Product, error) {
+	customer, err := repo.Customer(ctx, sale.Customer_id)
+	if err != nil {
+		return nil, err
+	}
+	if !customer.Active {
+		return nil, ErrCustomerInactive
+	}
+	products, err := lockSaleProducts(ctx, repo, sale)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string)
+	prices := make(map[int64]int)
+	for _, product := range products {
+		names[product.ID] = product.Name
+		prices[product.ID] = product.Price
+	}
+	for _, v := range sale.Positions {
+		v.Name = names[v.Product_id]
+		v.Base_price = prices[v.Product_id]
+		v.Price = v.Base_price - v.Base_price*v.Discount_percent/100 - v.Discount_amount
+		if v.Price < 0 {
+			return nil, ErrInvalidDiscount
+		}
+	}
+	return products, nil
 }
 
 func (s *Service) GetSales(ctx context.Context, id int64) (total int, err error) {
-	err = s.pool.QueryRow(ctx, `
-		SELECT COALESCE(SUM(sp.price * sp.qty),0) total
-		FROM managers m
-		LEFT JOIN sales s on s.manager_id = $1
-		LEFT JOIN sales_positions sp ON sp.sale_id = s.id
-		GROUP BY m.id
-	`, id).Scan(&total)
+	total, err = s.repo.SalesTotal(ctx, id)
 	if err != nil {
 		log.Print(err)
 		return 0, ErrInternal
@@ -242,104 +509,183 @@ func (s *Service) GetSales(ctx context.Context, id int64) (total int, err error)
 	return total, nil
 }
 
-func (s *Service) GetProducts(ctx context.Context) ([]*Product, error) {
-	var products []*Product
-	rows, err := s.pool.Query(ctx, `SELECT id, name, price, qty, active, created FROM products`)
+func (s *Service) GetProducts(ctx context.Context, filter *listing.ProductFilter) (*ProductsPage, error) {
+	products, err := s.repo.Products(ctx, filter)
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
+	page := &ProductsPage{Items: products}
+	if len(products) > filter.Limit {
+		page.Items = products[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = (&listing.Cursor{Sort: filter.Sort, Key: productKey(last, filter.Sort), ID: last.ID}).Encode()
+	}
+	return page, nil
+}
+
+func (s *Service) SearchProducts(ctx context.Context, search *listing.Search) ([]*ProductMatch, error) {
+	items, err := s.repo.SearchProducts(ctx, search)
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
-	defer rows.Close()
+	return items, nil
+}
+
+// RemoveProductByID archives the product: it leaves the customer catalog and
+// can no longer be sold, but stays in sales history and reports.
+func (s *Service) RemoveProductByID(ctx context.Context, managerID int64, id int64) (*Product, error) {
+	return s.setProductActive(ctx, managerID, id, false)
+}
+
+func (s *Service) RestoreProductByID(ctx context.Context, managerID int64, id int64) (*Product, error) {
+	return s.setProductActive(ctx, managerID, id, true)
+}
 
-	for rows.Next() {
-		product := &Product{}
-		err = rows.Scan(&product.ID, &product.Name, &product.Price, &product.Qty, &product.Active, &product.Created)
+func (s *Service) setProductActive(ctx context.Context, managerID int64, id int64, active bool) (*Product, error) {
+	var product *Product
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		before, err := repo.LockProducts(ctx, []int64{id})
 		if err != nil {
-			log.Print(err)
-			return nil, err
+			return err
 		}
-		products = append(products, product)
+		if len(before) == 0 {
+			return ErrProductNotFound
+		}
+		product, err = repo.SetProductActive(ctx, id, active)
+		if err != nil {
+			return err
+		}
+		action := ActionProductArchive
+		if active {
+			action = ActionProductRestore
+		}
+		return audit(ctx, repo, managerID, action, EntityProduct, id, before[0], product)
+	})
+	if err == ErrProductNotFound {
+		return nil, ErrProductNotFound
 	}
-	err = rows.Err()
 	if err != nil {
 		log.Print(err)
-		return nil, err
-	}
-	return products, nil
-}
-
-func (s *Service) RemoveProductByID(ctx context.Context, id int64) (*Product, error) {
-	product := &Product{}
-	err := s.pool.QueryRow(ctx, `
-		DELETE FROM products WHERE id = $1 RETURNING id, name, price, qty
-	`, id).Scan(&product.ID, &product.Name, &product.Price, &product.Qty)
-	if err != nil {
 		return nil, ErrInternal
 	}
 
 	return product, nil
 }
 
-func (s *Service) ChangeCustomer(ctx context.Context, item *customers.Customer) (*customers.Customer, error) {
-	customer := &customers.Customer{}
-	err := s.pool.QueryRow(ctx, `
-		UPDATE customers SET name = $1, phone = $2 WHERE id = $3
-		RETURNING id, name, phone, active, created
-	`, item.Name, item.Phone, item.ID).Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Active, &customer.Created)
+func (s *Service) ChangeCustomer(ctx context.Context, managerID int64, item *customers.Customer) (*customers.Customer, error) {
+	if item.Name == "" || item.Phone == "" {
+		return nil, ErrInvalidCustomer
+	}
+	var customer *customers.Customer
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		before, err := repo.Customer(ctx, item.ID)
+		if err != nil {
+			return err
+		}
+		customer, err = repo.UpdateCustomer(ctx, item)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, managerID, ActionCustomerUpdate, EntityCustomer, customer.ID, before, customer)
+	})
+	if err == customers.ErrUserNotFound {
+		return nil, customers.ErrUserNotFound
+	}
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
-	return item, nil
+	return customer, nil
 }
 
-func (s *Service) GetCustomers(ctx context.Context) ([]*customers.Customer, error) {
-	var items []*customers.Customer
-	rows, err := s.pool.Query(ctx, `SELECT id, name, phone, active, created FROM customers`)
+func (s *Service) GetCustomers(ctx context.Context, filter *listing.CustomerFilter) (*CustomersPage, error) {
+	items, err := s.repo.Customers(ctx, filter)
 	if err != nil {
+		log.Print(err)
 		return nil, ErrInternal
 	}
-	if err != nil {
-		return nil, ErrInternal
+	page := &CustomersPage{Items: items}
+	if len(items) > filter.Limit {
+		page.Items = items[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = (&listing.Cursor{Sort: filter.Sort, Key: customerKey(last, filter.Sort), ID: last.ID}).Encode()
 	}
-	defer rows.Close()
+	return page, nil
+}
 
-	for rows.Next() {
-		customer := &customers.Customer{}
-		err = rows.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Active, &customer.Created)
+// RemoveCustomerByID archives the customer, who can no longer log in or
+// buy; their purchases stay in sales history.
+func (s *Service) RemoveCustomerByID(ctx context.Context, managerID int64, id int64) (*customers.Customer, error) {
+	return s.setCustomerActive(ctx, managerID, id, false)
+}
+
+func (s *Service) RestoreCustomerByID(ctx context.Context, managerID int64, id int64) (*customers.Customer, error) {
+	return s.setCustomerActive(ctx, managerID, id, true)
+}
+
+func (s *Service) setCustomerActive(ctx context.Context, managerID int64, id int64, active bool) (*customers.Customer, error) {
+	var customer *customers.Customer
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		before, err := repo.Customer(ctx, id)
+		if err != nil {
+			return err
+		}
+		customer, err = repo.SetCustomerActive(ctx, id, active)
 		if err != nil {
-			log.Print(err)
-			return nil, err
+			return err
 		}
-		items = append(items, customer)
+		action := ActionCustomerArchive
+		if active {
+			action = ActionCustomerRestore
+		}
+		return audit(ctx, repo, managerID, action, EntityCustomer, id, before, customer)
+	})
+	if err == customers.ErrUserNotFound {
+		return nil, customers.ErrUserNotFound
 	}
-	err = rows.Err()
 	if err != nil {
 		log.Print(err)
-		return nil, err
+		return nil, ErrInternal
 	}
-	return items, nil
+
+	return customer, nil
 }
 
-func (s *Service) RemoveCustomerByID(ctx context.Context, id int64) (*customers.Customer, error) {
-	customer := &customers.Customer{}
-	err := s.pool.QueryRow(ctx, `
-		DELETE FROM customers WHERE id = $1 RETURNING id, phone
-	`, id).Scan(&customer.ID, &customer.Phone)
-	if err != nil {
-		return nil, ErrInternal
+// productKey returns the value of the listing sort field of product.
+func productKey(product *Product, sort string) interface{} {
+	switch sort {
+	case "name":
+		return product.Name
+	case "price":
+		return int64(product.Price)
+	case "created":
+		return product.Created
+	default:
+		return product.ID
 	}
+}
 
-	return customer, nil
+// customerKey returns the value of the listing sort field of customer.
+func customerKey(customer *customers.Customer, sort string) interface{} {
+	switch sort {
+	case "name":
+		return customer.Name
+	case "phone":
+		return customer.Phone
+	case "created":
+		return customer.Created
+	default:
+		return customer.ID
+	}
 }
 
-func (s *Service) IsAdmin(ctx context.Context, id int64) bool {
-	err := s.pool.QueryRow(ctx, `
-		select id from managers where 'ADMIN' =  any (roles) and id = $1
-	`, id).Scan(&id)
-	if err != nil {
-		return false
+func generateToken() (string, error) {
+	buffer := make([]byte, 256)
+	n, err := rand.Read(buffer)
+	if n != len(buffer) || err != nil {
+		return "", ErrInternal
 	}
-	return true
+	return hex.EncodeToString(buffer), nil
 }
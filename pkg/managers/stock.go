@@ -0,0 +1,140 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMovement = errors.New("invalid stock movement")
+var ErrAdjustmentForbidden = errors.New("stock adjustment not permitted")
+
+// Kinds of stock movements. Sales and returns are posted by MakeSale and
+// MakeReturn, reservations and their releases by order status changes, the
+// rest by managers through PostStockMovement.
+const (
+	MovementReceipt    = "receipt"
+	MovementSale       = "sale"
+	MovementReturn     = "return"
+	MovementReserve    = "reserve"
+	MovementRelease    = "release"
+	MovementAdjustment = "adjustment"
+	MovementWriteOff   = "write_off"
+)
+
+// StockMovement is an entry of the append-only stock ledger. Qty is the
+// signed change of products.qty and Balance the stock right after it.
+type StockMovement struct {
+	ID         int64     `json:"id"`
+	Product_id int64     `json:"product_id"`
+	Manager_id int64     `json:"manager_id"`
+	Kind       string    `json:"kind"`
+	Qty        int       `json:"qty"`
+	Balance    int       `json:"balance"`
+	Reason     string    `json:"reason"`
+	Sale_id    *int64    `json:"sale_id,omitempty"`
+	Return_id  *int64    `json:"return_id,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// StockDiscrepancy is a product whose stock differs from the ledger sum.
+type StockDiscrepancy struct {
+	Product_id int64  `json:"product_id"`
+	Name       string `json:"name"`
+	Qty        int    `json:"qty"`
+	Ledger_qty int    `json:"ledger_qty"`
+}
+
+// PostStockMovement records a receipt, write-off or adjustment and applies
+// it to the product stock. Receipts and write-offs take a positive qty;
+// adjustments take a signed one, need a reason and the stock:adjust
+// permission.
+func (s *Service) PostStockMovement(ctx context.Context, movement *StockMovement) (*StockMovement, error) {
+	movement.Reason = strings.TrimSpace(movement.Reason)
+	switch movement.Kind {
+	case MovementReceipt:
+		if movement.Qty <= 0 {
+			return nil, ErrInvalidMovement
+		}
+	case MovementWriteOff:
+		if movement.Qty <= 0 || movement.Reason == "" {
+			return nil, ErrInvalidMovement
+		}
+		movement.Qty = -movement.Qty
+	case MovementAdjustment:
+		if movement.Qty == 0 || movement.Reason == "" {
+			return nil, ErrInvalidMovement
+		}
+		if !s.HasPermission(ctx, movement.Manager_id, PermissionStockAdjust) {
+			return nil, ErrAdjustmentForbidden
+		}
+	default:
+		return nil, ErrInvalidMovement
+	}
+	movement.Sale_id, movement.Return_id = nil, nil
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		products, err := repo.LockProducts(ctx, []int64{movement.Product_id})
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			return ErrProductNotFound
+		}
+		if products[0].Qty+movement.Qty < 0 {
+			return ErrInsufficientStock
+		}
+		err = moveStock(ctx, repo, movement)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, movement.Manager_id, ActionStockMovement, EntityProduct, movement.Product_id,
+			map[string]interface{}{"qty": products[0].Qty},
+			map[string]interface{}{"qty": movement.Balance, "movement_id": movement.ID, "kind": movement.Kind})
+	})
+	switch err {
+	case nil:
+		return movement, nil
+	case ErrProductNotFound, ErrInsufficientStock:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+// GetStockMovements returns the ledger of the product, oldest first.
+func (s *Service) GetStockMovements(ctx context.Context, productID int64) ([]*StockMovement, error) {
+	items, err := s.repo.StockMovements(ctx, productID)
+	if err == ErrProductNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// GetStockDiscrepancies lists products whose qty does not match the ledger.
+func (s *Service) GetStockDiscrepancies(ctx context.Context) ([]*StockDiscrepancy, error) {
+	items, err := s.repo.StockDiscrepancies(ctx)
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// moveStock applies movement.Qty to the product and appends the movement
+// to the ledger with the resulting balance; it must run inside WithTx.
+func moveStock(ctx context.Context, repo Repository, movement *StockMovement) error {
+	balance, err := repo.AddProductQty(ctx, movement.Product_id, movement.Qty)
+	if err != nil {
+		return err
+	}
+	movement.Balance = balance
+	return repo.CreateStockMovement(ctx, movement)
+}
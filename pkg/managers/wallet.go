@@ -0,0 +1,169 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+var ErrInsufficientFunds = errors.New("insufficient wallet balance")
+
+// Kinds of wallet transactions.
+const (
+	WalletTopUp  = "top_up"
+	WalletSpend  = "spend"
+	WalletRefund = "refund"
+)
+
+// Ledger accounts. Every wallet transaction moves its amount between the
+// wallet of the customer and the cash desk or the sales of the shop, so that
+// the entries of a transaction always sum to zero.
+const (
+	AccountWallet = "wallet"
+	AccountCash   = "cash"
+	AccountSales  = "sales"
+)
+
+// WalletEntry is one side of a wallet transaction; the amount is positive
+// for the account receiving the money.
+type WalletEntry struct {
+	ID             int64  `json:"id"`
+	Transaction_id int64  `json:"transaction_id"`
+	Account        string `json:"account"`
+	Amount         int    `json:"amount"`
+}
+
+// WalletTransaction changes the balance of a customer wallet by Amount,
+// which is negative for spending. Sale_id and Payment_id are set for
+// spending and refunds, Manager_id is 0 when no manager took part.
+type WalletTransaction struct {
+	ID          int64          `json:"id"`
+	Customer_id int64          `json:"customer_id"`
+	Kind        string         `json:"kind"`
+	Amount      int            `json:"amount"`
+	Balance     int            `json:"balance"`
+	Sale_id     *int64         `json:"sale_id"`
+	Payment_id  *int64         `json:"payment_id"`
+	Manager_id  int64          `json:"manager_id"`
+	Comment     string         `json:"comment"`
+	Created     time.Time      `json:"created"`
+	Entries     []*WalletEntry `json:"entries"`
+}
+
+// Wallet is the stored value of a customer with its history, newest first.
+type Wallet struct {
+	Customer_id int64                `json:"customer_id"`
+	Balance     int                  `json:"balance"`
+	History     []*WalletTransaction `json:"history"`
+}
+
+// TopUpWallet puts amount the customer paid in at the cash desk into their
+// wallet on behalf of managerID.
+func (s *Service) TopUpWallet(ctx context.Context, managerID int64, customerID int64, amount int, comment string) (*Wallet, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	err := s.repo.WithTx(ctx, func(repo Repository) error {
+		customer, err := repo.Customer(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		if !customer.Active {
+			return ErrCustomerInactive
+		}
+		transaction := &WalletTransaction{
+			Customer_id: customerID,
+			Kind:        WalletTopUp,
+			Amount:      amount,
+			Manager_id:  managerID,
+			Comment:     comment,
+		}
+		err = moveWallet(ctx, repo, transaction)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repo, managerID, ActionWalletTopUp, EntityWallet, customerID,
+			nil, map[string]interface{}{"amount": amount, "balance": transaction.Balance})
+	})
+	switch err {
+	case nil:
+		return s.GetWallet(ctx, customerID)
+	case customers.ErrUserNotFound, ErrCustomerInactive:
+		return nil, err
+	default:
+		log.Print(err)
+		return nil, ErrInternal
+	}
+}
+
+func (s *Service) GetWallet(ctx context.Context, customerID int64) (*Wallet, error) {
+	history, err := s.repo.WalletTransactions(ctx, customerID)
+	if err == customers.ErrUserNotFound {
+		return nil, customers.ErrUserNotFound
+	}
+	if err != nil {
+		log.Print(err)
+		return nil, ErrInternal
+	}
+	wallet := &Wallet{Customer_id: customerID, History: history}
+	if len(history) > 0 {
+		wallet.Balance = history[0].Balance
+	}
+	return wallet, nil
+}
+
+// payFromWallet takes amount for the locked sale from the wallet of its
+// customer, recording it as a wallet payment.
+func payFromWallet(ctx context.Context, repo Repository, managerID int64, sale *Sale, amount int) (*Payment, error) {
+	payment := &Payment{
+		Sale_id:    sale.ID,
+		Method:     TenderWallet,
+		Amount:     amount,
+		Status:     PaymentCaptured,
+		Manager_id: managerID,
+	}
+	err := repo.CreatePayment(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	err = moveWallet(ctx, repo, &WalletTransaction{
+		Customer_id: sale.Customer_id,
+		Kind:        WalletSpend,
+		Amount:      -amount,
+		Sale_id:     &sale.ID,
+		Payment_id:  &payment.ID,
+		Manager_id:  managerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// moveWallet books the transaction against the locked wallet of the
+// customer, failing if the balance would go below zero. The counter entry
+// goes to the cash desk for top-ups and to sales otherwise.
+func moveWallet(ctx context.Context, repo Repository, transaction *WalletTransaction) error {
+	balance, err := repo.LockWallet(ctx, transaction.Customer_id)
+	if err != nil {
+		return err
+	}
+	if balance+transaction.Amount < 0 {
+		return ErrInsufficientFunds
+	}
+	counter := AccountSales
+	if transaction.Kind == WalletTopUp {
+		counter = AccountCash
+	}
+	transaction.Balance = balance + transaction.Amount
+	transaction.Entries = []*WalletEntry{
+		{Account: AccountWallet, Amount: transaction.Amount},
+		{Account: counter, Amount: -transaction.Amount},
+	}
+	return repo.CreateWalletTransaction(ctx, transaction)
+}
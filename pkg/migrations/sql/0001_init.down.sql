@@ -0,0 +1,7 @@
+DROP TABLE sales_positions;
+DROP TABLE sales;
+DROP TABLE products;
+DROP TABLE managers_tokens;
+DROP TABLE managers;
+DROP TABLE customers_tokens;
+DROP TABLE customers;
@@ -1,4 +1,3 @@
-
 CREATE TABLE customers
 (
     id BIGSERIAL PRIMARY KEY,
@@ -65,4 +64,4 @@ CREATE TABLE sales_positions
     price       INTEGER NOT NULL,
     qty         INTEGER NOT NULL,
     created     timestamp NOT NULL default current_timestamp 
-);
\ No newline at end of file
+);
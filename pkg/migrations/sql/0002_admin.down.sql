@@ -0,0 +1,2 @@
+DELETE FROM managers_tokens WHERE manager_id IN (SELECT id FROM managers WHERE phone = '+992000000001');
+DELETE FROM managers WHERE phone = '+992000000001';
@@ -1,2 +1,2 @@
 INSERT INTO managers (name, phone, password, roles)
-values ('vasya', '+992000000001', '$2a$10$oc/QUw9dRpQAtWeqrs/ma.w7gH23qJHAWDrrLI6GkYTg/b9J.YMo.', '{"MANAGER","ADMIN"}' );
\ No newline at end of file
+values ('vasya', '+992000000001', '$2a$10$oc/QUw9dRpQAtWeqrs/ma.w7gH23qJHAWDrrLI6GkYTg/b9J.YMo.', '{"MANAGER","ADMIN"}' );
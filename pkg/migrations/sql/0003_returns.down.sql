@@ -0,0 +1,2 @@
+DROP TABLE returns_positions;
+DROP TABLE returns;
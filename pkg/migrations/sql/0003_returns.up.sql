@@ -0,0 +1,23 @@
+CREATE TABLE returns
+(
+    id          BIGSERIAL PRIMARY KEY,
+    sale_id     BIGINT NOT NULL REFERENCES sales (id) ON DELETE CASCADE,
+    manager_id  BIGINT NOT NULL REFERENCES managers,
+    reason      TEXT NOT NULL DEFAULT '',
+    refund      INTEGER NOT NULL DEFAULT 0,
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE returns_positions
+(
+    id               BIGSERIAL PRIMARY KEY,
+    return_id        BIGINT NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+    sale_position_id BIGINT NOT NULL REFERENCES sales_positions (id) ON DELETE CASCADE,
+    product_id       BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price            INTEGER NOT NULL,
+    qty              INTEGER NOT NULL CHECK (qty > 0),
+    created          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX returns_sale_id_idx ON returns (sale_id);
+CREATE INDEX returns_positions_sale_position_id_idx ON returns_positions (sale_position_id);
@@ -0,0 +1,4 @@
+ALTER TABLE sales_positions
+    DROP COLUMN base_price,
+    DROP COLUMN discount_percent,
+    DROP COLUMN discount_amount;
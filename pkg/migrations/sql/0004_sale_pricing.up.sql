@@ -0,0 +1,6 @@
+ALTER TABLE sales_positions
+    ADD COLUMN base_price       INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN discount_percent INTEGER NOT NULL DEFAULT 0 CHECK (discount_percent BETWEEN 0 AND 100),
+    ADD COLUMN discount_amount  INTEGER NOT NULL DEFAULT 0 CHECK (discount_amount >= 0);
+
+UPDATE sales_positions SET base_price = price;
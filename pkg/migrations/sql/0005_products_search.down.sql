@@ -0,0 +1,3 @@
+DROP TRIGGER products_search_update ON products;
+DROP FUNCTION products_search_update();
+ALTER TABLE products DROP COLUMN search;
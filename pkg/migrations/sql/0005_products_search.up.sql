@@ -0,0 +1,15 @@
+ALTER TABLE products ADD COLUMN search TSVECTOR;
+
+UPDATE products SET search = to_tsvector('simple', name);
+
+CREATE FUNCTION products_search_update() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search := to_tsvector('simple', NEW.name);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_search_update BEFORE INSERT OR UPDATE OF name ON products
+    FOR EACH ROW EXECUTE PROCEDURE products_search_update();
+
+CREATE INDEX products_search_idx ON products USING GIN (search);
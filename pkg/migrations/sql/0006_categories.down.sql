@@ -0,0 +1,2 @@
+DROP TABLE products_categories;
+DROP TABLE categories;
@@ -0,0 +1,17 @@
+CREATE TABLE categories
+(
+    id        BIGSERIAL PRIMARY KEY,
+    parent_id BIGINT REFERENCES categories,
+    name      TEXT NOT NULL,
+    created   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE products_categories
+(
+    product_id  BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    category_id BIGINT NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+CREATE INDEX products_categories_category_id_idx ON products_categories (category_id);
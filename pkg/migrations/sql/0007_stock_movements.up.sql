@@ -0,0 +1,19 @@
+CREATE TABLE stock_movements
+(
+    id         BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    manager_id BIGINT REFERENCES managers,
+    kind       TEXT NOT NULL CHECK (kind IN ('receipt', 'sale', 'return', 'adjustment', 'write_off')),
+    qty        INTEGER NOT NULL CHECK (qty <> 0),
+    balance    INTEGER NOT NULL CHECK (balance >= 0),
+    reason     TEXT NOT NULL DEFAULT '',
+    sale_id    BIGINT REFERENCES sales (id) ON DELETE SET NULL,
+    return_id  BIGINT REFERENCES returns (id) ON DELETE SET NULL,
+    created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX stock_movements_product_id_idx ON stock_movements (product_id, id);
+
+-- stock that existed before the ledger becomes its opening balance
+INSERT INTO stock_movements (product_id, kind, qty, balance, reason)
+SELECT id, 'adjustment', qty, qty, 'opening balance' FROM products WHERE qty > 0;
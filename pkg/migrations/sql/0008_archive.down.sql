@@ -0,0 +1,15 @@
+ALTER TABLE sales DROP CONSTRAINT sales_customer_id_fkey;
+
+ALTER TABLE stock_movements
+    DROP CONSTRAINT stock_movements_product_id_fkey,
+    ADD CONSTRAINT stock_movements_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE;
+
+ALTER TABLE returns_positions
+    DROP CONSTRAINT returns_positions_product_id_fkey,
+    ADD CONSTRAINT returns_positions_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE;
+
+ALTER TABLE sales_positions
+    DROP CONSTRAINT sales_positions_product_id_fkey,
+    ADD CONSTRAINT sales_positions_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE;
+
+ALTER TABLE sales_positions DROP COLUMN name;
@@ -0,0 +1,22 @@
+-- sales positions keep the product name they were sold under
+ALTER TABLE sales_positions ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+UPDATE sales_positions sp SET name = p.name FROM products p WHERE p.id = sp.product_id;
+
+-- products and customers are archived instead of deleted, so history must
+-- never be removed together with them
+ALTER TABLE sales_positions
+    DROP CONSTRAINT sales_positions_product_id_fkey,
+    ADD CONSTRAINT sales_positions_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id);
+
+ALTER TABLE returns_positions
+    DROP CONSTRAINT returns_positions_product_id_fkey,
+    ADD CONSTRAINT returns_positions_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id);
+
+ALTER TABLE stock_movements
+    DROP CONSTRAINT stock_movements_product_id_fkey,
+    ADD CONSTRAINT stock_movements_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id);
+
+-- older sales may already point to deleted customers
+ALTER TABLE sales
+    ADD CONSTRAINT sales_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES customers (id) NOT VALID;
@@ -0,0 +1,2 @@
+-- roles assigned by 0009 are indistinguishable from deliberate ones
+SELECT 1;
@@ -0,0 +1,2 @@
+-- managers created without roles keep the access they had before permissions
+UPDATE managers SET roles = '{MANAGER}' WHERE roles = '{}';
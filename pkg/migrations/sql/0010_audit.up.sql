@@ -0,0 +1,16 @@
+CREATE TABLE audit_log
+(
+    id         BIGSERIAL PRIMARY KEY,
+    manager_id BIGINT NOT NULL REFERENCES managers,
+    action     TEXT NOT NULL,
+    entity     TEXT NOT NULL,
+    entity_id  BIGINT NOT NULL,
+    before     JSONB,
+    after      JSONB,
+    request_id TEXT NOT NULL DEFAULT '',
+    created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_manager_id_idx ON audit_log (manager_id, created);
+CREATE INDEX audit_log_entity_idx ON audit_log (entity, entity_id, created);
+CREATE INDEX audit_log_created_idx ON audit_log (created);
@@ -0,0 +1 @@
+DROP TABLE managers_plans;
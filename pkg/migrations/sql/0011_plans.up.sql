@@ -0,0 +1,12 @@
+CREATE TABLE managers_plans
+(
+    id         BIGSERIAL PRIMARY KEY,
+    manager_id BIGINT NOT NULL REFERENCES managers,
+    period     TEXT NOT NULL CHECK (period IN ('month', 'quarter')),
+    start      DATE NOT NULL,
+    amount     INTEGER NOT NULL CHECK (amount >= 0),
+    set_by     BIGINT NOT NULL REFERENCES managers,
+    created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX managers_plans_manager_id_idx ON managers_plans (manager_id, period, start, id);
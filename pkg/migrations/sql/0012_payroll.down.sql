@@ -0,0 +1,3 @@
+DROP TABLE payroll_lines;
+DROP TABLE payroll_statements;
+DROP TABLE commission_rules;
@@ -0,0 +1,43 @@
+CREATE TABLE commission_rules
+(
+    id             BIGSERIAL PRIMARY KEY,
+    kind           TEXT NOT NULL CHECK (kind IN ('flat', 'tiered', 'category')),
+    rate_bp        INTEGER NOT NULL CHECK (rate_bp BETWEEN 0 AND 10000),
+    min_attainment INTEGER NOT NULL DEFAULT 0,
+    category_id    BIGINT REFERENCES categories ON DELETE CASCADE,
+    created        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE payroll_statements
+(
+    id           BIGSERIAL PRIMARY KEY,
+    manager_id   BIGINT NOT NULL REFERENCES managers,
+    name         TEXT NOT NULL,
+    month        DATE NOT NULL,
+    salary       INTEGER NOT NULL,
+    plan         INTEGER NOT NULL,
+    actual       INTEGER NOT NULL,
+    attainment   INTEGER NOT NULL,
+    commission   INTEGER NOT NULL,
+    total        INTEGER NOT NULL,
+    finalized_by BIGINT NOT NULL REFERENCES managers,
+    finalized    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (manager_id, month)
+);
+
+CREATE TABLE payroll_lines
+(
+    id           BIGSERIAL PRIMARY KEY,
+    statement_id BIGINT NOT NULL REFERENCES payroll_statements ON DELETE CASCADE,
+    sale_id      BIGINT NOT NULL REFERENCES sales,
+    position_id  BIGINT NOT NULL REFERENCES sales_positions,
+    product_id   BIGINT NOT NULL REFERENCES products,
+    name         TEXT NOT NULL,
+    qty          INTEGER NOT NULL,
+    amount       INTEGER NOT NULL,
+    rule_id      BIGINT,
+    rate_bp      INTEGER NOT NULL,
+    commission   INTEGER NOT NULL
+);
+
+CREATE INDEX payroll_lines_statement_id_idx ON payroll_lines (statement_id, id);
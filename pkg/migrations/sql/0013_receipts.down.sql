@@ -0,0 +1,3 @@
+ALTER TABLE sales DROP COLUMN receipt_no;
+
+DROP TABLE counters;
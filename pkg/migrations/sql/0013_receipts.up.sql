@@ -0,0 +1,15 @@
+CREATE TABLE counters
+(
+    name  TEXT PRIMARY KEY,
+    value BIGINT NOT NULL
+);
+
+ALTER TABLE sales ADD COLUMN receipt_no BIGINT UNIQUE;
+
+UPDATE sales SET receipt_no = numbered.n
+FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM sales) AS numbered
+WHERE numbered.id = sales.id;
+
+ALTER TABLE sales ALTER COLUMN receipt_no SET NOT NULL;
+
+INSERT INTO counters (name, value) VALUES ('receipt', (SELECT COUNT(*) FROM sales));
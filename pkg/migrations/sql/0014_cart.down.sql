@@ -0,0 +1,4 @@
+-- fails while there are sales without a manager, which would lose history
+ALTER TABLE sales ALTER COLUMN manager_id SET NOT NULL;
+
+DROP TABLE cart_items;
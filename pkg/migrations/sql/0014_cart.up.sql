@@ -0,0 +1,11 @@
+CREATE TABLE cart_items
+(
+    customer_id BIGINT NOT NULL REFERENCES customers ON DELETE CASCADE,
+    product_id  BIGINT NOT NULL REFERENCES products,
+    qty         INTEGER NOT NULL CHECK (qty > 0),
+    added       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (customer_id, product_id)
+);
+
+-- sales checked out by customers themselves have no manager
+ALTER TABLE sales ALTER COLUMN manager_id DROP NOT NULL;
@@ -0,0 +1,13 @@
+-- reserved stock left the shelf for an order and released stock came back,
+-- which is a sale and a return without order statuses
+UPDATE stock_movements SET kind = 'sale' WHERE kind = 'reserve';
+UPDATE stock_movements SET kind = 'return' WHERE kind = 'release';
+
+ALTER TABLE stock_movements
+    DROP CONSTRAINT stock_movements_kind_check,
+    ADD CONSTRAINT stock_movements_kind_check
+        CHECK (kind IN ('receipt', 'sale', 'return', 'adjustment', 'write_off'));
+
+DROP TABLE sales_status_history;
+
+ALTER TABLE sales DROP COLUMN status;
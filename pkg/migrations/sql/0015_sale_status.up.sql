@@ -0,0 +1,25 @@
+-- every sale made so far was handed over on the spot
+ALTER TABLE sales ADD COLUMN status TEXT NOT NULL DEFAULT 'fulfilled'
+    CHECK (status IN ('draft', 'confirmed', 'paid', 'fulfilled', 'cancelled'));
+ALTER TABLE sales ALTER COLUMN status DROP DEFAULT;
+
+CREATE INDEX sales_status_idx ON sales (status);
+
+CREATE TABLE sales_status_history
+(
+    id         BIGSERIAL PRIMARY KEY,
+    sale_id    BIGINT NOT NULL REFERENCES sales ON DELETE CASCADE,
+    status     TEXT NOT NULL,
+    manager_id BIGINT REFERENCES managers,
+    created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX sales_status_history_sale_id_idx ON sales_status_history (sale_id, id);
+
+INSERT INTO sales_status_history (sale_id, status, manager_id, created)
+SELECT id, status, manager_id, created FROM sales;
+
+ALTER TABLE stock_movements
+    DROP CONSTRAINT stock_movements_kind_check,
+    ADD CONSTRAINT stock_movements_kind_check
+        CHECK (kind IN ('receipt', 'sale', 'return', 'reserve', 'release', 'adjustment', 'write_off'));
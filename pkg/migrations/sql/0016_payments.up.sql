@@ -0,0 +1,17 @@
+CREATE TABLE payments
+(
+    id         BIGSERIAL PRIMARY KEY,
+    sale_id    BIGINT NOT NULL REFERENCES sales,
+    method     TEXT NOT NULL CHECK (method IN ('cash', 'card')),
+    provider   TEXT NOT NULL DEFAULT '',
+    reference  TEXT NOT NULL DEFAULT '',
+    amount     INTEGER NOT NULL CHECK (amount > 0),
+    refunded   INTEGER NOT NULL DEFAULT 0,
+    status     TEXT NOT NULL CHECK (status IN ('captured', 'refunded', 'failed')),
+    manager_id BIGINT NOT NULL REFERENCES managers,
+    created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (refunded BETWEEN 0 AND amount)
+);
+
+CREATE INDEX payments_sale_id_idx ON payments (sale_id, id);
+CREATE UNIQUE INDEX payments_reference_idx ON payments (provider, reference) WHERE method = 'card';
@@ -0,0 +1,9 @@
+DROP TABLE wallet_entries;
+DROP TABLE wallet_transactions;
+DROP TABLE wallets;
+
+DELETE FROM payments WHERE method = 'wallet';
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_method_check,
+    ADD CONSTRAINT payments_method_check CHECK (method IN ('cash', 'card'));
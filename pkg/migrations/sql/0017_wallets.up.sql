@@ -0,0 +1,37 @@
+CREATE TABLE wallets
+(
+    customer_id BIGINT PRIMARY KEY REFERENCES customers,
+    balance     INTEGER NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE wallet_transactions
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES wallets,
+    kind        TEXT NOT NULL CHECK (kind IN ('top_up', 'spend', 'refund')),
+    amount      INTEGER NOT NULL CHECK (amount <> 0),
+    balance     INTEGER NOT NULL CHECK (balance >= 0),
+    sale_id     BIGINT REFERENCES sales,
+    payment_id  BIGINT REFERENCES payments,
+    manager_id  BIGINT REFERENCES managers,
+    comment     TEXT NOT NULL DEFAULT '',
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX wallet_transactions_customer_id_idx ON wallet_transactions (customer_id, id);
+
+-- the two entries of a transaction sum to zero
+CREATE TABLE wallet_entries
+(
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES wallet_transactions,
+    account        TEXT NOT NULL CHECK (account IN ('wallet', 'cash', 'sales')),
+    amount         INTEGER NOT NULL
+);
+
+CREATE INDEX wallet_entries_transaction_id_idx ON wallet_entries (transaction_id);
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_method_check,
+    ADD CONSTRAINT payments_method_check CHECK (method IN ('cash', 'card', 'wallet'));
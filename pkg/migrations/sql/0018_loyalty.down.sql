@@ -0,0 +1,5 @@
+DROP TABLE points_transactions;
+DROP TABLE loyalty_accounts;
+DROP TABLE loyalty_rules;
+
+ALTER TABLE sales_positions DROP COLUMN points_discount;
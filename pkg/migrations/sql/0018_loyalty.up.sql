@@ -0,0 +1,38 @@
+ALTER TABLE sales_positions ADD COLUMN points_discount INTEGER NOT NULL DEFAULT 0 CHECK (points_discount >= 0);
+
+CREATE TABLE loyalty_rules
+(
+    id          BIGSERIAL PRIMARY KEY,
+    kind        TEXT NOT NULL CHECK (kind IN ('earn', 'category', 'tier')),
+    rate_bp     INTEGER NOT NULL DEFAULT 0 CHECK (rate_bp >= 0),
+    multiplier  INTEGER NOT NULL DEFAULT 0 CHECK (multiplier >= 0),
+    category_id BIGINT REFERENCES categories ON DELETE CASCADE,
+    tier        TEXT NOT NULL DEFAULT '',
+    min_spend   INTEGER NOT NULL DEFAULT 0,
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE loyalty_accounts
+(
+    customer_id BIGINT PRIMARY KEY REFERENCES customers,
+    balance     INTEGER NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- remaining is what is left of earned points, spent oldest first
+CREATE TABLE points_transactions
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES loyalty_accounts,
+    kind        TEXT NOT NULL CHECK (kind IN ('earn', 'redeem', 'revoke', 'expire')),
+    points      INTEGER NOT NULL CHECK (points <> 0),
+    balance     INTEGER NOT NULL CHECK (balance >= 0),
+    remaining   INTEGER NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    sale_id     BIGINT REFERENCES sales,
+    return_id   BIGINT REFERENCES returns,
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX points_transactions_customer_id_idx ON points_transactions (customer_id, id);
+CREATE INDEX points_transactions_lots_idx ON points_transactions (customer_id, id) WHERE remaining > 0;
+CREATE INDEX points_transactions_sale_id_idx ON points_transactions (sale_id) WHERE sale_id IS NOT NULL;
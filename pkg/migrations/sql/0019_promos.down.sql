@@ -0,0 +1,4 @@
+DROP TABLE promo_redemptions;
+DROP TABLE promos;
+
+ALTER TABLE sales_positions DROP COLUMN promo_discount;
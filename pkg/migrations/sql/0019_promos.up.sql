@@ -0,0 +1,34 @@
+ALTER TABLE sales_positions ADD COLUMN promo_discount INTEGER NOT NULL DEFAULT 0 CHECK (promo_discount >= 0);
+
+-- value is a percent for percent codes and an amount for fixed ones;
+-- 0 limits mean no limit
+CREATE TABLE promos
+(
+    id                    BIGSERIAL PRIMARY KEY,
+    code                  TEXT NOT NULL UNIQUE,
+    kind                  TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value                 INTEGER NOT NULL CHECK (value > 0),
+    min_basket            INTEGER NOT NULL DEFAULT 0 CHECK (min_basket >= 0),
+    product_id            BIGINT REFERENCES products,
+    category_id           BIGINT REFERENCES categories,
+    starts                TIMESTAMP,
+    ends                  TIMESTAMP,
+    max_uses              INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+    max_uses_per_customer INTEGER NOT NULL DEFAULT 0 CHECK (max_uses_per_customer >= 0),
+    active                BOOLEAN NOT NULL DEFAULT TRUE,
+    created               TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (product_id IS NULL OR category_id IS NULL),
+    CHECK (ends > starts)
+);
+
+CREATE TABLE promo_redemptions
+(
+    id          BIGSERIAL PRIMARY KEY,
+    promo_id    BIGINT NOT NULL REFERENCES promos,
+    customer_id BIGINT NOT NULL REFERENCES customers,
+    sale_id     BIGINT NOT NULL UNIQUE REFERENCES sales,
+    discount    INTEGER NOT NULL CHECK (discount >= 0),
+    created     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX promo_redemptions_promo_id_idx ON promo_redemptions (promo_id, customer_id);
@@ -0,0 +1,7 @@
+-- pending refunds already count in refunded
+UPDATE payments SET status = CASE WHEN refunded = amount THEN 'refunded' ELSE 'captured' END
+WHERE status = 'refund_pending';
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_status_check,
+    ADD CONSTRAINT payments_status_check CHECK (status IN ('captured', 'refunded', 'failed'));
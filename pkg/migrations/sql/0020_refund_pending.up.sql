@@ -0,0 +1,3 @@
+ALTER TABLE payments
+    DROP CONSTRAINT payments_status_check,
+    ADD CONSTRAINT payments_status_check CHECK (status IN ('captured', 'refund_pending', 'refunded', 'failed'));
@@ -0,0 +1,7 @@
+-- refunded points become earned ones outside of any sale, so that balances
+-- stay as they are and returns of the sale do not revoke them
+UPDATE points_transactions SET kind = 'earn', sale_id = NULL WHERE kind = 'refund';
+
+ALTER TABLE points_transactions
+    DROP CONSTRAINT points_transactions_kind_check,
+    ADD CONSTRAINT points_transactions_kind_check CHECK (kind IN ('earn', 'redeem', 'revoke', 'expire'));
@@ -0,0 +1,3 @@
+ALTER TABLE points_transactions
+    DROP CONSTRAINT points_transactions_kind_check,
+    ADD CONSTRAINT points_transactions_kind_check CHECK (kind IN ('earn', 'redeem', 'revoke', 'refund', 'expire'));
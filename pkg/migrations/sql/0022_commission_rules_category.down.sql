@@ -0,0 +1,3 @@
+ALTER TABLE commission_rules
+    DROP CONSTRAINT commission_rules_category_id_fkey,
+    ADD CONSTRAINT commission_rules_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+-- deleting a category must not take the commission rules scoped to it along
+ALTER TABLE commission_rules
+    DROP CONSTRAINT commission_rules_category_id_fkey,
+    ADD CONSTRAINT commission_rules_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories;
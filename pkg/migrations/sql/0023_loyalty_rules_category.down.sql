@@ -0,0 +1,3 @@
+ALTER TABLE loyalty_rules
+    DROP CONSTRAINT loyalty_rules_category_id_fkey,
+    ADD CONSTRAINT loyalty_rules_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories ON DELETE CASCADE;
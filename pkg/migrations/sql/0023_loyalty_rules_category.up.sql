@@ -0,0 +1,4 @@
+-- deleting a category must not take the loyalty rules scoped to it along
+ALTER TABLE loyalty_rules
+    DROP CONSTRAINT loyalty_rules_category_id_fkey,
+    ADD CONSTRAINT loyalty_rules_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories;
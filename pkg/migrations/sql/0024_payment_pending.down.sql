@@ -0,0 +1,11 @@
+-- payments that never reached the gateway are dropped, the others brought
+-- nothing in, as failed ones
+DELETE FROM payments WHERE status IN ('pending', 'voided') AND reference = '';
+UPDATE payments SET status = 'failed' WHERE status IN ('pending', 'voided');
+
+DROP INDEX payments_reference_idx;
+CREATE UNIQUE INDEX payments_reference_idx ON payments (provider, reference) WHERE method = 'card';
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_status_check,
+    ADD CONSTRAINT payments_status_check CHECK (status IN ('captured', 'refund_pending', 'refunded', 'failed'));
@@ -0,0 +1,10 @@
+-- card payments are booked as pending before the gateway captures them and
+-- voided if the payment does not go through; pending ones have no gateway
+-- reference yet
+ALTER TABLE payments
+    DROP CONSTRAINT payments_status_check,
+    ADD CONSTRAINT payments_status_check
+        CHECK (status IN ('pending', 'captured', 'refund_pending', 'refunded', 'voided', 'failed'));
+
+DROP INDEX payments_reference_idx;
+CREATE UNIQUE INDEX payments_reference_idx ON payments (provider, reference) WHERE method = 'card' AND reference <> '';
@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const FakeName = "fake"
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of the webhook body.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeDeclinedToken is the card token the fake gateway always declines.
+const FakeDeclinedToken = "tok_declined"
+
+// Fake is a gateway running in-process for development and tests. It
+// approves every non-empty card token except FakeDeclinedToken and keeps
+// its payments in memory only.
+type Fake struct {
+	mu       sync.Mutex
+	secret   []byte
+	seq      int64
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	authorized int
+	captured   int
+	refunded   int
+}
+
+func NewFake(secret string) *Fake {
+	return &Fake{secret: []byte(secret), payments: make(map[string]*fakePayment)}
+}
+
+func (f *Fake) Name() string {
+	return FakeName
+}
+
+func (f *Fake) Authorize(ctx context.Context, amount int, token string) (string, error) {
+	if token == "" || token == FakeDeclinedToken || amount <= 0 {
+		return "", ErrDeclined
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	reference := FakeName + "_" + strconv.FormatInt(f.seq, 10)
+	f.payments[reference] = &fakePayment{authorized: amount}
+	return reference, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, reference string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[reference]
+	if !ok {
+		return ErrNotFound
+	}
+	if amount <= 0 || payment.captured+amount > payment.authorized {
+		return ErrInvalidAmount
+	}
+	payment.captured += amount
+	return nil
+}
+
+func (f *Fake) Refund(ctx context.Context, reference string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[reference]
+	if !ok {
+		return ErrNotFound
+	}
+	if payment.captured == 0 {
+		payment.authorized = 0
+		return nil
+	}
+	if amount <= 0 || payment.refunded+amount > payment.captured {
+		return ErrInvalidAmount
+	}
+	payment.refunded += amount
+	return nil
+}
+
+// VerifyWebhook accepts bodies signed with Sign; without a secret nothing
+// is accepted.
+func (f *Fake) VerifyWebhook(header http.Header, body []byte) (*Event, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || len(f.secret) == 0 || !hmac.Equal(signature, f.sign(body)) {
+		return nil, ErrInvalidSignature
+	}
+	var event *Event
+	err = json.Unmarshal(body, &event)
+	if err != nil || event == nil {
+		return nil, ErrInvalidSignature
+	}
+	return event, nil
+}
+
+// Sign returns the FakeSignatureHeader value for body, letting tests and
+// developers post webhooks as the gateway would.
+func (f *Fake) Sign(body []byte) string {
+	return hex.EncodeToString(f.sign(body))
+}
+
+func (f *Fake) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,65 @@
+// Package payments connects the shop to card payment gateways. Amounts are
+// in the same minor units as product prices.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var ErrDeclined = errors.New("payment declined")
+var ErrNotFound = errors.New("no such payment at the gateway")
+var ErrInvalidAmount = errors.New("amount exceeds what the gateway holds")
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+var ErrUnknownProvider = errors.New("unknown payment provider")
+
+// Types of the events gateways notify about through webhooks.
+const (
+	// EventRefunded reports the total amount refunded so far, including
+	// refunds made at the gateway itself.
+	EventRefunded = "refunded"
+	// EventFailed reports a captured payment reversed by the card issuer.
+	EventFailed = "failed"
+)
+
+// Provider is a card payment gateway.
+type Provider interface {
+	// Name identifies the gateway in stored payments.
+	Name() string
+	// Authorize holds amount on the card given by its gateway token and
+	// returns the gateway reference of the payment.
+	Authorize(ctx context.Context, amount int, token string) (reference string, err error)
+	// Capture takes amount of the authorized one off the card.
+	Capture(ctx context.Context, reference string, amount int) error
+	// Refund gives amount of a captured payment back, or releases an
+	// authorization that was never captured.
+	Refund(ctx context.Context, reference string, amount int) error
+	// VerifyWebhook checks that a notification was sent by the gateway and
+	// decodes it.
+	VerifyWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+// Event is a webhook notification about a payment.
+type Event struct {
+	Reference string `json:"reference"`
+	Type      string `json:"type"`
+	Amount    int    `json:"amount"`
+}
+
+// Options select the gateway card payments go through.
+type Options struct {
+	// Provider is the gateway name; only the bundled fake one is known.
+	Provider string
+	// WebhookSecret signs the notifications of the gateway.
+	WebhookSecret string
+}
+
+func New(opts Options) (Provider, error) {
+	switch opts.Provider {
+	case FakeName:
+		return NewFake(opts.WebhookSecret), nil
+	default:
+		return nil, ErrUnknownProvider
+	}
+}
@@ -0,0 +1,50 @@
+package receipts
+
+import (
+	"html/template"
+	"io"
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt #{{.Number}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: .25em .5em; border-bottom: 1px solid #ddd; }
+td.n, th.n { text-align: right; }
+tfoot td { border: none; }
+.total td { font-weight: bold; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+{{if .Seller}}<h1>{{.Seller}}</h1>{{end}}
+<h2>Receipt #{{.Number}}</h2>
+<p>{{.Created.Format "2006-01-02 15:04:05"}}{{if .Manager}}<br>
+Manager: {{.Manager}}{{end}}{{if .Customer}}<br>
+Customer: {{.Customer}}{{end}}</p>
+<table>
+<thead>
+<tr><th>Product</th><th class="n">Qty</th><th class="n">Price</th><th class="n">Discount</th><th class="n">Amount</th></tr>
+</thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Name}}</td><td class="n">{{.Qty}}</td><td class="n">{{.Price}}</td><td class="n">{{if .Discount}}{{.Discount}}{{end}}</td><td class="n">{{.Amount}}</td></tr>
+{{end}}</tbody>
+<tfoot>
+{{if .Discount}}<tr><td colspan="4">Subtotal</td><td class="n">{{.Subtotal}}</td></tr>
+<tr><td colspan="4">Discount</td><td class="n">-{{.Discount}}</td></tr>
+{{end}}<tr class="total"><td colspan="4">Total</td><td class="n">{{.Total}}</td></tr>
+{{if .Tax_percent}}<tr><td colspan="4">incl. VAT {{.Tax_percent}}%</td><td class="n">{{.Tax}}</td></tr>
+{{end}}</tfoot>
+</table>
+</body>
+</html>
+`))
+
+// HTML writes the receipt as a printable HTML page.
+func HTML(w io.Writer, r *Receipt) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,154 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PDF page geometry in points: A4 with the text layout in 10pt Courier,
+// whose characters are 6pt wide.
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfFontSize   = 10
+	pdfLeading    = 12
+	pdfCharWidth  = 6
+	pdfWidth      = MaxWidth
+)
+
+// PDF writes the receipt as a PDF document. It uses the standard Courier
+// fonts, which cover Latin-1 only: Cyrillic is transliterated and any other
+// character is printed as '?'.
+func PDF(w io.Writer, r *Receipt) error {
+	latin := *r
+	latin.Seller, latin.Manager, latin.Customer = toLatin(r.Seller), toLatin(r.Manager), toLatin(r.Customer)
+	latin.Lines = make([]*Line, 0, len(r.Lines))
+	for _, line := range r.Lines {
+		copied := *line
+		copied.Name = toLatin(line.Name)
+		latin.Lines = append(latin.Lines, &copied)
+	}
+	lines := layout(&latin, pdfWidth)
+
+	perPage := (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	pages := make([][]textLine, 0)
+	for len(lines) > perPage {
+		pages = append(pages, lines[:perPage])
+		lines = lines[perPage:]
+	}
+	pages = append(pages, lines)
+
+	var doc pdfDocument
+	doc.add("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	doc.add(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	doc.add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	doc.add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		doc.add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		content := pdfContent(page)
+		doc.add(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	_, err := w.Write(doc.bytes())
+	return err
+}
+
+// pdfContent draws the lines of one page from its top left corner.
+func pdfContent(lines []textLine) string {
+	var b strings.Builder
+	left := (pdfPageWidth - pdfWidth*pdfCharWidth) / 2
+	fmt.Fprintf(&b, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, left, pdfPageHeight-pdfMargin)
+	for _, line := range lines {
+		text := line.text
+		if line.center {
+			text = strings.Repeat(" ", (pdfWidth-len(winAnsi(text)))/2) + text
+		}
+		if line.bold {
+			fmt.Fprintf(&b, "/F2 %d Tf ", pdfFontSize)
+		}
+		fmt.Fprintf(&b, "(%s) Tj T*", pdfEscape(winAnsi(text)))
+		if line.bold {
+			fmt.Fprintf(&b, " /F1 %d Tf", pdfFontSize)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("ET")
+	return b.String()
+}
+
+// pdfDocument collects numbered objects and writes them with the cross
+// reference table PDF readers need to find them.
+type pdfDocument struct {
+	objects []string
+}
+
+func (d *pdfDocument) add(object string) {
+	d.objects = append(d.objects, object)
+}
+
+func (d *pdfDocument) bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, 0, len(d.objects))
+	for i, object := range d.objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, xref)
+	return b.Bytes()
+}
+
+// winAnsi encodes s in Latin-1, which WinAnsiEncoding agrees with for every
+// printable character but a few in 0x80-0x9F that receipts do not use.
+func winAnsi(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x20 || r > 0xFF || r >= 0x7F && r < 0xA0 {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return string(b)
+}
+
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+// cyrillic transliterates Russian and Tajik letters.
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'ғ': "gh", 'ӣ': "i", 'қ': "q", 'ӯ': "u", 'ҳ': "h", 'ҷ': "j",
+}
+
+func toLatin(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		lower := []rune(strings.ToLower(string(r)))[0]
+		latin, ok := cyrillic[lower]
+		switch {
+		case !ok:
+			b.WriteRune(r)
+		case lower != r && latin != "":
+			b.WriteString(strings.ToUpper(latin[:1]) + latin[1:])
+		default:
+			b.WriteString(latin)
+		}
+	}
+	return b.String()
+}
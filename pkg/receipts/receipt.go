@@ -0,0 +1,67 @@
+// Package receipts renders sales as printable receipts in HTML, ESC/POS
+// text for thermal printers and PDF.
+package receipts
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("no such receipt")
+
+// Options are the shop-wide settings printed on every receipt.
+type Options struct {
+	// Seller is the shop name heading the receipt.
+	Seller string
+	// TaxPercent is the VAT rate already included in the prices.
+	TaxPercent int
+}
+
+// Receipt is a sale as printed. Number is the sequential receipt number
+// given to the sale when it was made.
+type Receipt struct {
+	Number      int64     `json:"number"`
+	Sale_id     int64     `json:"sale_id"`
+	Created     time.Time `json:"created"`
+	Seller      string    `json:"seller"`
+	Manager_id  int64     `json:"manager_id"`
+	Manager     string    `json:"manager"`
+	Customer_id int64     `json:"customer_id"`
+	Customer    string    `json:"customer"`
+	Lines       []*Line   `json:"lines"`
+	Subtotal    int       `json:"subtotal"`
+	Discount    int       `json:"discount"`
+	Total       int       `json:"total"`
+	Tax_percent int       `json:"tax_percent"`
+	Tax         int       `json:"tax"`
+}
+
+// Line is one sale position; Discount and Amount are for the whole quantity.
+type Line struct {
+	Product_id int64  `json:"product_id"`
+	Name       string `json:"name"`
+	Qty        int    `json:"qty"`
+	Base_price int    `json:"base_price"`
+	Price      int    `json:"price"`
+	Discount   int    `json:"discount"`
+	Amount     int    `json:"amount"`
+}
+
+// Complete applies opts and computes the line and receipt totals from the
+// quantities and prices of the lines. The tax is the part of the total that
+// is VAT, rounded half up.
+func (r *Receipt) Complete(opts Options) {
+	r.Seller, r.Tax_percent = opts.Seller, opts.TaxPercent
+	r.Subtotal, r.Discount, r.Total = 0, 0, 0
+	for _, line := range r.Lines {
+		line.Amount = line.Price * line.Qty
+		line.Discount = (line.Base_price - line.Price) * line.Qty
+		r.Subtotal += line.Base_price * line.Qty
+		r.Discount += line.Discount
+		r.Total += line.Amount
+	}
+	r.Tax = 0
+	if r.Tax_percent > 0 {
+		r.Tax = (2*r.Total*r.Tax_percent + 100 + r.Tax_percent) / (2 * (100 + r.Tax_percent))
+	}
+}
@@ -0,0 +1,156 @@
+package receipts
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Widths of the text receipt in characters.
+const (
+	DefaultWidth = 42
+	MinWidth     = 32
+	MaxWidth     = 64
+)
+
+// ESC/POS commands used by Text.
+const (
+	escInit        = "\x1b@"
+	escAlignLeft   = "\x1ba\x00"
+	escAlignCenter = "\x1ba\x01"
+	escBoldOn      = "\x1bE\x01"
+	escBoldOff     = "\x1bE\x00"
+	escFeedAndCut  = "\x1bd\x04\x1dV\x42\x00"
+)
+
+// textLine is one printed line of the text layout shared by Text and PDF.
+type textLine struct {
+	text   string
+	bold   bool
+	center bool
+}
+
+// layout lays the receipt out in lines of at most width characters.
+func layout(r *Receipt, width int) []textLine {
+	rule := textLine{text: strings.Repeat("-", width)}
+	lines := make([]textLine, 0)
+	if r.Seller != "" {
+		for _, text := range wrap(r.Seller, width) {
+			lines = append(lines, textLine{text: text, bold: true, center: true})
+		}
+	}
+	lines = append(lines,
+		textLine{text: "Receipt #" + strconv.FormatInt(r.Number, 10), bold: true, center: true},
+		textLine{text: r.Created.Format("2006-01-02 15:04:05"), center: true},
+		rule,
+	)
+	for _, line := range r.Lines {
+		for _, text := range wrap(line.Name, width) {
+			lines = append(lines, textLine{text: text})
+		}
+		lines = append(lines, textLine{text: columns("  "+strconv.Itoa(line.Qty)+" x "+strconv.Itoa(line.Price),
+			strconv.Itoa(line.Amount), width)})
+		if line.Discount != 0 {
+			lines = append(lines, textLine{text: columns("  discount", "-"+strconv.Itoa(line.Discount), width)})
+		}
+	}
+	lines = append(lines, rule)
+	if r.Discount != 0 {
+		lines = append(lines,
+			textLine{text: columns("Subtotal", strconv.Itoa(r.Subtotal), width)},
+			textLine{text: columns("Discount", "-"+strconv.Itoa(r.Discount), width)},
+		)
+	}
+	lines = append(lines, textLine{text: columns("TOTAL", strconv.Itoa(r.Total), width), bold: true})
+	if r.Tax_percent > 0 {
+		lines = append(lines, textLine{text: columns("incl. VAT "+strconv.Itoa(r.Tax_percent)+"%", strconv.Itoa(r.Tax), width)})
+	}
+	lines = append(lines, rule)
+	if r.Manager != "" {
+		lines = append(lines, textLine{text: columns("Manager", r.Manager, width)})
+	}
+	if r.Customer != "" {
+		lines = append(lines, textLine{text: columns("Customer", r.Customer, width)})
+	}
+	return lines
+}
+
+// Text writes the receipt as UTF-8 text wrapped in ESC/POS commands, width
+// characters wide, ending with a paper cut.
+func Text(w io.Writer, r *Receipt, width int) error {
+	var b strings.Builder
+	b.WriteString(escInit)
+	for _, line := range layout(r, width) {
+		if line.center {
+			b.WriteString(escAlignCenter)
+		}
+		if line.bold {
+			b.WriteString(escBoldOn)
+		}
+		b.WriteString(line.text)
+		if line.bold {
+			b.WriteString(escBoldOff)
+		}
+		if line.center {
+			b.WriteString(escAlignLeft)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(escFeedAndCut)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// columns puts left and right at the edges of a line of width characters,
+// cutting left if they do not fit.
+func columns(left string, right string, width int) string {
+	room := width - utf8.RuneCountInString(right) - 1
+	if room < 0 {
+		return right
+	}
+	left = cut(left, room)
+	return left + strings.Repeat(" ", width-utf8.RuneCountInString(left)-utf8.RuneCountInString(right)) + right
+}
+
+// wrap splits s into lines of at most width characters, breaking at spaces
+// where possible.
+func wrap(s string, width int) []string {
+	lines := make([]string, 0, 1)
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for utf8.RuneCountInString(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			head := cut(word, width)
+			lines = append(lines, head)
+			word = word[len(head):]
+		}
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func cut(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
@@ -0,0 +1,17 @@
+// Package requestid carries the id of the HTTP request being served through
+// a context, so that services can record it without depending on net/http.
+package requestid
+
+import "context"
+
+var contextKey = &struct{ name string }{"request id"}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey, id)
+}
+
+// FromContext returns the request id stored in ctx or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey).(string)
+	return id
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateAuditEntry(ctx context.Context, entry *managers.AuditEntry) error {
+	defer r.lock()()
+
+	row := auditRow{
+		ID:        r.db.next("audit_log"),
+		ManagerID: entry.Manager_id,
+		Action:    entry.Action,
+		Entity:    entry.Entity,
+		EntityID:  entry.Entity_id,
+		Before:    entry.Before,
+		After:     entry.After,
+		RequestID: entry.Request_id,
+		Created:   time.Now(),
+	}
+	r.db.auditLog[row.ID] = row
+	entry.ID, entry.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) AuditEntries(ctx context.Context, filter *managers.AuditFilter) ([]*managers.AuditEntry, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	for id, row := range r.db.auditLog {
+		switch {
+		case filter.ManagerID != 0 && row.ManagerID != filter.ManagerID:
+		case filter.Entity != "" && row.Entity != filter.Entity:
+		case filter.EntityID != 0 && row.EntityID != filter.EntityID:
+		case filter.From != nil && row.Created.Before(*filter.From):
+		case filter.To != nil && row.Created.After(*filter.To):
+		default:
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	items := make([]*managers.AuditEntry, 0)
+	for i := filter.Offset; i < len(ids) && len(items) < filter.Limit; i++ {
+		row := r.db.auditLog[ids[i]]
+		items = append(items, &managers.AuditEntry{
+			ID:         row.ID,
+			Manager_id: row.ManagerID,
+			Action:     row.Action,
+			Entity:     row.Entity,
+			Entity_id:  row.EntityID,
+			Before:     row.Before,
+			After:      row.After,
+			Request_id: row.RequestID,
+			Created:    row.Created,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CartItems(ctx context.Context, customerID int64) ([]*managers.CartItem, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	for key := range r.db.cartItems {
+		if key.CustomerID == customerID {
+			ids = append(ids, key.ProductID)
+		}
+	}
+	items := make([]*managers.CartItem, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		product := r.db.products[id]
+		items = append(items, &managers.CartItem{
+			Product_id: id,
+			Name:       product.Name,
+			Price:      product.Price,
+			Qty:        r.db.cartItems[cartItemKey{CustomerID: customerID, ProductID: id}].Qty,
+			Available:  product.Qty,
+			Active:     product.Active,
+		})
+	}
+	return items, nil
+}
+
+func (r *Managers) SetCartItem(ctx context.Context, customerID int64, productID int64, qty int) error {
+	defer r.lock()()
+
+	if _, ok := r.db.products[productID]; !ok {
+		return managers.ErrProductNotFound
+	}
+	key := cartItemKey{CustomerID: customerID, ProductID: productID}
+	row, ok := r.db.cartItems[key]
+	if !ok {
+		row.Added = time.Now()
+	}
+	row.Qty = qty
+	r.db.cartItems[key] = row
+	return nil
+}
+
+func (r *Managers) DeleteCartItem(ctx context.Context, customerID int64, productID int64) error {
+	defer r.lock()()
+
+	delete(r.db.cartItems, cartItemKey{CustomerID: customerID, ProductID: productID})
+	return nil
+}
+
+func (r *Managers) ClearCart(ctx context.Context, customerID int64) error {
+	defer r.lock()()
+
+	for key := range r.db.cartItems {
+		if key.CustomerID == customerID {
+			delete(r.db.cartItems, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateCategory(ctx context.Context, category *managers.Category) error {
+	defer r.lock()()
+
+	id := r.db.next("categories")
+	row := categoryRow{ID: id, ParentID: category.Parent_id, Name: category.Name, Created: time.Now()}
+	r.db.categories[id] = row
+	category.ID, category.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) UpdateCategory(ctx context.Context, category *managers.Category) error {
+	defer r.lock()()
+
+	row, ok := r.db.categories[category.ID]
+	if !ok {
+		return managers.ErrCategoryNotFound
+	}
+	row.ParentID, row.Name = category.Parent_id, category.Name
+	r.db.categories[row.ID] = row
+	category.Created = row.Created
+	return nil
+}
+
+func (r *Managers) Categories(ctx context.Context) ([]*managers.Category, error) {
+	defer r.lock()()
+
+	rows := r.db.sortedCategories()
+	items := make([]*managers.Category, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.category())
+	}
+	return items, nil
+}
+
+func (r *Managers) DeleteCategory(ctx context.Context, id int64) (*managers.Category, error) {
+	defer r.lock()()
+
+	row, ok := r.db.categories[id]
+	if !ok {
+		return nil, managers.ErrCategoryNotFound
+	}
+	delete(r.db.categories, id)
+	for key := range r.db.productsCategories {
+		if key.CategoryID == id {
+			delete(r.db.productsCategories, key)
+		}
+	}
+	return row.category(), nil
+}
+
+func (r *Managers) SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error {
+	defer r.lock()()
+
+	if _, ok := r.db.products[productID]; !ok {
+		return managers.ErrProductNotFound
+	}
+	for key := range r.db.productsCategories {
+		if key.ProductID == productID {
+			delete(r.db.productsCategories, key)
+		}
+	}
+	for _, id := range categoryIDs {
+		r.db.productsCategories[productCategoryKey{ProductID: productID, CategoryID: id}] = true
+	}
+	return nil
+}
+
+func (r *Managers) ProductCategories(ctx context.Context, productID int64) ([]*managers.Category, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.products[productID]; !ok {
+		return nil, managers.ErrProductNotFound
+	}
+	items := make([]*managers.Category, 0)
+	for _, row := range r.db.sortedCategories() {
+		if r.db.productsCategories[productCategoryKey{ProductID: productID, CategoryID: row.ID}] {
+			items = append(items, row.category())
+		}
+	}
+	return items, nil
+}
+
+func (r *Customers) Categories(ctx context.Context) ([]*customers.Category, error) {
+	defer r.lock()()
+
+	rows := r.db.sortedCategories()
+	items := make([]*customers.Category, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, &customers.Category{ID: row.ID, Name: row.Name, Parent: row.ParentID})
+	}
+	return items, nil
+}
+
+// sortedCategories returns every category ordered by name, then id.
+func (t *tables) sortedCategories() []categoryRow {
+	rows := make([]categoryRow, 0, len(t.categories))
+	for _, row := range t.categories {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Name != rows[j].Name {
+			return rows[i].Name < rows[j].Name
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows
+}
+
+// categoryProducts returns the ids of products assigned to the category or
+// any of its descendants.
+func (t *tables) categoryProducts(id int64) map[int64]bool {
+	tree := map[int64]bool{id: true}
+	for grown := true; grown; {
+		grown = false
+		for _, row := range t.categories {
+			if row.ParentID != nil && tree[*row.ParentID] && !tree[row.ID] {
+				tree[row.ID] = true
+				grown = true
+			}
+		}
+	}
+	products := make(map[int64]bool)
+	for key := range t.productsCategories {
+		if tree[key.CategoryID] {
+			products[key.ProductID] = true
+		}
+	}
+	return products
+}
+
+func (row categoryRow) category() *managers.Category {
+	return &managers.Category{ID: row.ID, Parent_id: row.ParentID, Name: row.Name, Created: row.Created}
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+)
+
+// Customers implements customers.Repository in memory.
+type Customers struct {
+	conn
+}
+
+func NewCustomers(db *DB) *Customers {
+	return &Customers{conn{db: db}}
+}
+
+func (r *Customers) CreateCustomer(ctx context.Context, reg *customers.Registration) (*customers.Customer, error) {
+	defer r.lock()()
+
+	for _, row := range r.db.customers {
+		if row.Phone == reg.Phone {
+			return nil, customers.ErrPhoneUsed
+		}
+	}
+	row := customerRow{
+		ID:       r.db.next("customers"),
+		Name:     reg.Name,
+		Phone:    reg.Phone,
+		Password: reg.Password,
+		Active:   true,
+		Created:  time.Now(),
+	}
+	r.db.customers[row.ID] = row
+	return row.customer(), nil
+}
+
+func (r *Customers) CustomerPasswordByPhone(ctx context.Context, phone string) (id int64, hash string, err error) {
+	defer r.lock()()
+
+	for _, row := range r.db.customers {
+		if row.Phone == phone && row.Active {
+			return row.ID, row.Password, nil
+		}
+	}
+	return 0, "", customers.ErrUserNotFound
+}
+
+func (r *Customers) SaveCustomerToken(ctx context.Context, token string, customerID int64, expire time.Time) error {
+	defer r.lock()()
+
+	r.db.customerTokens[token] = tokenRow{OwnerID: customerID, Expire: expire, Created: time.Now()}
+	return nil
+}
+
+func (r *Customers) CustomerToken(ctx context.Context, token string) (customerID int64, expire time.Time, err error) {
+	defer r.lock()()
+
+	row, ok := r.db.customerTokens[token]
+	if !ok {
+		return 0, time.Time{}, customers.ErrTokenNotFound
+	}
+	if customer, ok := r.db.customers[row.OwnerID]; !ok || !customer.Active {
+		return 0, time.Time{}, customers.ErrTokenNotFound
+	}
+	return row.OwnerID, row.Expire, nil
+}
+
+func (r *Customers) Products(ctx context.Context, filter *listing.ProductFilter) ([]*customers.Product, error) {
+	defer r.lock()()
+
+	rows := r.db.listProducts(filter)
+	items := make([]*customers.Product, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.customerProduct())
+	}
+	return items, nil
+}
+
+func (r *Customers) Purchases(ctx context.Context, customerID int64) ([]*customers.Purchase, error) {
+	defer r.lock()()
+
+	items := make([]*customers.Purchase, 0)
+	for _, saleID := range r.db.saleIDs() {
+		sale := r.db.sales[saleID]
+		if sale.CustomerID != customerID {
+			continue
+		}
+		purchase := &customers.Purchase{Sale_id: sale.ID, Status: sale.Status, Date: sale.Created}
+		for _, position := range r.db.positionsOf(saleID) {
+			purchase.Products = append(purchase.Products, &customers.Product{
+				ID:    position.ProductID,
+				Name:  position.Name,
+				Price: position.Price,
+				Qty:   position.Qty,
+			})
+		}
+		if len(purchase.Products) > 0 {
+			items = append(items, purchase)
+		}
+	}
+	return items, nil
+}
+
+func (row customerRow) customer() *customers.Customer {
+	return &customers.Customer{
+		ID:      row.ID,
+		Name:    row.Name,
+		Phone:   row.Phone,
+		Active:  row.Active,
+		Created: row.Created,
+	}
+}
+
+func (row productRow) customerProduct() *customers.Product {
+	return &customers.Product{
+		ID:      row.ID,
+		Name:    row.Name,
+		Price:   row.Price,
+		Qty:     row.Qty,
+		Active:  row.Active,
+		Created: row.Created,
+	}
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/khiki1995/crud/pkg/listing"
+)
+
+// listProducts returns at most filter.Limit+1 products matching filter in
+// listing order.
+func (t *tables) listProducts(filter *listing.ProductFilter) []productRow {
+	var inCategory map[int64]bool
+	if filter.CategoryID != nil {
+		inCategory = t.categoryProducts(*filter.CategoryID)
+	}
+	rows := make([]productRow, 0)
+	for _, row := range t.products {
+		if !row.matches(filter) || !filter.IsAfter(row.key(filter.Sort), row.ID) {
+			continue
+		}
+		if inCategory != nil && !inCategory[row.ID] {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return filter.Less(rows[i].key(filter.Sort), rows[i].ID, rows[j].key(filter.Sort), rows[j].ID)
+	})
+	if len(rows) > filter.Limit+1 {
+		rows = rows[:filter.Limit+1]
+	}
+	return rows
+}
+
+func (t *tables) listCustomers(filter *listing.CustomerFilter) []customerRow {
+	rows := make([]customerRow, 0)
+	for _, row := range t.customers {
+		if !row.matches(filter) || !filter.IsAfter(row.key(filter.Sort), row.ID) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return filter.Less(rows[i].key(filter.Sort), rows[i].ID, rows[j].key(filter.Sort), rows[j].ID)
+	})
+	if len(rows) > filter.Limit+1 {
+		rows = rows[:filter.Limit+1]
+	}
+	return rows
+}
+
+func (row productRow) matches(filter *listing.ProductFilter) bool {
+	switch {
+	case filter.Active != nil && row.Active != *filter.Active:
+	case filter.MinPrice != nil && row.Price < *filter.MinPrice:
+	case filter.MaxPrice != nil && row.Price > *filter.MaxPrice:
+	case filter.Name != "" && !containsFold(row.Name, filter.Name):
+	case filter.CreatedFrom != nil && row.Created.Before(*filter.CreatedFrom):
+	case filter.CreatedTo != nil && row.Created.After(*filter.CreatedTo):
+	default:
+		return true
+	}
+	return false
+}
+
+func (row productRow) key(sort string) interface{} {
+	switch sort {
+	case "name":
+		return row.Name
+	case "price":
+		return int64(row.Price)
+	case "created":
+		return row.Created
+	default:
+		return row.ID
+	}
+}
+
+func (row customerRow) matches(filter *listing.CustomerFilter) bool {
+	switch {
+	case filter.Active != nil && row.Active != *filter.Active:
+	case filter.Name != "" && !containsFold(row.Name, filter.Name):
+	case filter.PhonePrefix != "" && !strings.HasPrefix(row.Phone, filter.PhonePrefix):
+	case filter.CreatedFrom != nil && row.Created.Before(*filter.CreatedFrom):
+	case filter.CreatedTo != nil && row.Created.After(*filter.CreatedTo):
+	default:
+		return true
+	}
+	return false
+}
+
+func (row customerRow) key(sort string) interface{} {
+	switch sort {
+	case "name":
+		return row.Name
+	case "phone":
+		return row.Phone
+	case "created":
+		return row.Created
+	default:
+		return row.ID
+	}
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateLoyaltyRule(ctx context.Context, rule *managers.LoyaltyRule) error {
+	defer r.lock()()
+
+	row := loyaltyRuleRow{
+		ID:         r.db.next("loyalty_rules"),
+		Kind:       rule.Kind,
+		RateBP:     rule.Rate_bp,
+		Multiplier: rule.Multiplier,
+		CategoryID: rule.Category_id,
+		Tier:       rule.Tier,
+		MinSpend:   rule.Min_spend,
+		Created:    time.Now(),
+	}
+	r.db.loyaltyRules[row.ID] = row
+	rule.ID, rule.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) LoyaltyRules(ctx context.Context) ([]*managers.LoyaltyRule, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0, len(r.db.loyaltyRules))
+	for id := range r.db.loyaltyRules {
+		ids = append(ids, id)
+	}
+	items := make([]*managers.LoyaltyRule, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, r.db.loyaltyRules[id].rule())
+	}
+	return items, nil
+}
+
+func (r *Managers) DeleteLoyaltyRule(ctx context.Context, id int64) (*managers.LoyaltyRule, error) {
+	defer r.lock()()
+
+	row, ok := r.db.loyaltyRules[id]
+	if !ok {
+		return nil, managers.ErrLoyaltyRuleNotFound
+	}
+	delete(r.db.loyaltyRules, id)
+	return row.rule(), nil
+}
+
+func (r *Managers) LockPoints(ctx context.Context, customerID int64) (int, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.customers[customerID]; !ok {
+		return 0, customers.ErrUserNotFound
+	}
+	return r.db.loyaltyAccounts[customerID], nil
+}
+
+func (r *Managers) CreatePointsTransaction(ctx context.Context, transaction *managers.PointsTransaction) error {
+	defer r.lock()()
+
+	row := pointsTransactionRow{
+		ID:         r.db.next("points_transactions"),
+		CustomerID: transaction.Customer_id,
+		Kind:       transaction.Kind,
+		Points:     transaction.Points,
+		Balance:    transaction.Balance,
+		Remaining:  transaction.Remaining,
+		SaleID:     transaction.Sale_id,
+		ReturnID:   transaction.Return_id,
+		Created:    time.Now(),
+	}
+	r.db.pointsTransactions[row.ID] = row
+	r.db.loyaltyAccounts[row.CustomerID] = row.Balance
+	transaction.ID, transaction.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) PointLots(ctx context.Context, customerID int64) ([]*managers.PointsTransaction, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	for id, row := range r.db.pointsTransactions {
+		if row.CustomerID == customerID && row.Remaining > 0 &&
+			(row.Kind == managers.PointsEarned || row.Kind == managers.PointsRefunded) {
+			ids = append(ids, id)
+		}
+	}
+	items := make([]*managers.PointsTransaction, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, r.db.pointsTransactions[id].transaction())
+	}
+	return items, nil
+}
+
+func (r *Managers) SetLotRemaining(ctx context.Context, id int64, remaining int) error {
+	defer r.lock()()
+
+	row := r.db.pointsTransactions[id]
+	row.Remaining = remaining
+	r.db.pointsTransactions[id] = row
+	return nil
+}
+
+func (r *Managers) PointsTransactions(ctx context.Context, customerID int64) ([]*managers.PointsTransaction, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.customers[customerID]; !ok {
+		return nil, customers.ErrUserNotFound
+	}
+	ids := make([]int64, 0)
+	for id, row := range r.db.pointsTransactions {
+		if row.CustomerID == customerID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	items := make([]*managers.PointsTransaction, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, r.db.pointsTransactions[id].transaction())
+	}
+	return items, nil
+}
+
+func (r *Managers) SalePoints(ctx context.Context, saleID int64) (int, error) {
+	defer r.lock()()
+
+	points := 0
+	for _, row := range r.db.pointsTransactions {
+		if row.Kind == managers.PointsEarned && row.SaleID != nil && *row.SaleID == saleID {
+			points += row.Points
+		}
+	}
+	return points, nil
+}
+
+func (r *Managers) CustomerSpend(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	defer r.lock()()
+
+	spend := 0
+	for _, position := range r.db.salesPositions {
+		sale := r.db.sales[position.SaleID]
+		if sale.CustomerID == customerID && sale.counted() && !sale.Created.Before(since) {
+			spend += position.Price * position.Qty
+		}
+	}
+	for _, ret := range r.db.returns {
+		sale := r.db.sales[ret.SaleID]
+		if sale.CustomerID == customerID && sale.counted() && !sale.Created.Before(since) {
+			spend -= ret.Refund
+		}
+	}
+	return spend, nil
+}
+
+func (row loyaltyRuleRow) rule() *managers.LoyaltyRule {
+	return &managers.LoyaltyRule{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Rate_bp:     row.RateBP,
+		Multiplier:  row.Multiplier,
+		Category_id: row.CategoryID,
+		Tier:        row.Tier,
+		Min_spend:   row.MinSpend,
+		Created:     row.Created,
+	}
+}
+
+func (row pointsTransactionRow) transaction() *managers.PointsTransaction {
+	return &managers.PointsTransaction{
+		ID:          row.ID,
+		Customer_id: row.CustomerID,
+		Kind:        row.Kind,
+		Points:      row.Points,
+		Balance:     row.Balance,
+		Remaining:   row.Remaining,
+		Sale_id:     row.SaleID,
+		Return_id:   row.ReturnID,
+		Created:     row.Created,
+	}
+}
@@ -0,0 +1,332 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// Managers implements managers.Repository in memory.
+type Managers struct {
+	conn
+}
+
+func NewManagers(db *DB) *Managers {
+	return &Managers{conn{db: db}}
+}
+
+func (r *Managers) WithTx(ctx context.Context, fn func(repo managers.Repository) error) error {
+	return r.tx(func(c conn) error {
+		return fn(&Managers{c})
+	})
+}
+
+func (r *Managers) CreateManager(ctx context.Context, reg *managers.Registration) (*managers.Manager, error) {
+	defer r.lock()()
+
+	for _, row := range r.db.managers {
+		if row.Phone == reg.Phone {
+			return nil, managers.ErrPhoneUsed
+		}
+	}
+	roles := make([]string, len(reg.Roles))
+	copy(roles, reg.Roles)
+	row := managerRow{
+		ID:      r.db.next("managers"),
+		Name:    reg.Name,
+		Phone:   reg.Phone,
+		Roles:   roles,
+		Active:  true,
+		Created: time.Now(),
+	}
+	if reg.Boss_id != nil {
+		row.BossID = *reg.Boss_id
+	}
+	row.Department = reg.Department
+	row.Salary, row.Plan = reg.Salary, reg.Plan
+	r.db.managers[row.ID] = row
+	return row.manager(), nil
+}
+
+func (r *Managers) ManagerPasswordByPhone(ctx context.Context, phone string) (id int64, hash string, err error) {
+	defer r.lock()()
+
+	for _, row := range r.db.managers {
+		if row.Phone == phone {
+			return row.ID, row.Password, nil
+		}
+	}
+	return 0, "", managers.ErrUserNotFound
+}
+
+func (r *Managers) Manager(ctx context.Context, id int64) (*managers.Manager, error) {
+	defer r.lock()()
+
+	row, ok := r.db.managers[id]
+	if !ok {
+		return nil, managers.ErrUserNotFound
+	}
+	return row.manager(), nil
+}
+
+func (r *Managers) AllManagers(ctx context.Context) ([]*managers.Manager, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0, len(r.db.managers))
+	for id := range r.db.managers {
+		ids = append(ids, id)
+	}
+	items := make([]*managers.Manager, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, r.db.managers[id].manager())
+	}
+	return items, nil
+}
+
+func (r *Managers) ManagerRoles(ctx context.Context, id int64) ([]string, error) {
+	defer r.lock()()
+
+	row, ok := r.db.managers[id]
+	if !ok {
+		return nil, managers.ErrUserNotFound
+	}
+	return row.Roles, nil
+}
+
+func (r *Managers) SetManagerRoles(ctx context.Context, id int64, roles []string) error {
+	defer r.lock()()
+
+	row, ok := r.db.managers[id]
+	if !ok {
+		return managers.ErrUserNotFound
+	}
+	row.Roles = make([]string, len(roles))
+	copy(row.Roles, roles)
+	r.db.managers[id] = row
+	return nil
+}
+
+func (r *Managers) SaveManagerToken(ctx context.Context, token string, managerID int64, expire time.Time) error {
+	defer r.lock()()
+
+	r.db.managerTokens[token] = tokenRow{OwnerID: managerID, Expire: expire, Created: time.Now()}
+	return nil
+}
+
+func (r *Managers) ManagerToken(ctx context.Context, token string) (managerID int64, expire time.Time, err error) {
+	defer r.lock()()
+
+	row, ok := r.db.managerTokens[token]
+	if !ok {
+		return 0, time.Time{}, managers.ErrTokenNotFound
+	}
+	return row.OwnerID, row.Expire, nil
+}
+
+func (r *Managers) CreateProduct(ctx context.Context, product *managers.Product) error {
+	defer r.lock()()
+
+	row := productRow{
+		ID:      r.db.next("products"),
+		Name:    product.Name,
+		Price:   product.Price,
+		Qty:     product.Qty,
+		Active:  true,
+		Created: time.Now(),
+	}
+	r.db.products[row.ID] = row
+	product.ID, product.Active, product.Created = row.ID, row.Active, row.Created
+	return nil
+}
+
+func (r *Managers) UpdateProduct(ctx context.Context, product *managers.Product) error {
+	defer r.lock()()
+
+	row, ok := r.db.products[product.ID]
+	if !ok {
+		return managers.ErrProductNotFound
+	}
+	row.Name, row.Price = product.Name, product.Price
+	r.db.products[row.ID] = row
+	product.Qty, product.Active, product.Created = row.Qty, row.Active, row.Created
+	return nil
+}
+
+func (r *Managers) Products(ctx context.Context, filter *listing.ProductFilter) ([]*managers.Product, error) {
+	defer r.lock()()
+
+	rows := r.db.listProducts(filter)
+	products := make([]*managers.Product, 0, len(rows))
+	for _, row := range rows {
+		products = append(products, row.product())
+	}
+	return products, nil
+}
+
+func (r *Managers) LockProducts(ctx context.Context, ids []int64) ([]*managers.Product, error) {
+	defer r.lock()()
+
+	found := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := r.db.products[id]; ok {
+			found = append(found, id)
+		}
+	}
+	products := make([]*managers.Product, 0, len(found))
+	for _, id := range sortedIDs(found) {
+		products = append(products, r.db.products[id].product())
+	}
+	return products, nil
+}
+
+func (r *Managers) AddProductQty(ctx context.Context, id int64, delta int) (int, error) {
+	defer r.lock()()
+
+	row, ok := r.db.products[id]
+	if !ok {
+		return 0, managers.ErrProductNotFound
+	}
+	row.Qty += delta
+	r.db.products[id] = row
+	return row.Qty, nil
+}
+
+func (r *Managers) SetProductActive(ctx context.Context, id int64, active bool) (*managers.Product, error) {
+	defer r.lock()()
+
+	row, ok := r.db.products[id]
+	if !ok {
+		return nil, managers.ErrProductNotFound
+	}
+	row.Active = active
+	r.db.products[id] = row
+	return row.product(), nil
+}
+func (r *Managers) UpdateCustomer(ctx context.Context, item *customers.Customer) (*customers.Customer, error) {
+	defer r.lock()()
+
+	row, ok := r.db.customers[item.ID]
+	if !ok {
+		return nil, customers.ErrUserNotFound
+	}
+	row.Name, row.Phone = item.Name, item.Phone
+	r.db.customers[row.ID] = row
+	return row.customer(), nil
+}
+
+func (r *Managers) Customers(ctx context.Context, filter *listing.CustomerFilter) ([]*customers.Customer, error) {
+	defer r.lock()()
+
+	rows := r.db.listCustomers(filter)
+	items := make([]*customers.Customer, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.customer())
+	}
+	return items, nil
+}
+
+func (r *Managers) Customer(ctx context.Context, id int64) (*customers.Customer, error) {
+	defer r.lock()()
+
+	row, ok := r.db.customers[id]
+	if !ok {
+		return nil, customers.ErrUserNotFound
+	}
+	return row.customer(), nil
+}
+
+func (r *Managers) SetCustomerActive(ctx context.Context, id int64, active bool) (*customers.Customer, error) {
+	defer r.lock()()
+
+	row, ok := r.db.customers[id]
+	if !ok {
+		return nil, customers.ErrUserNotFound
+	}
+	row.Active = active
+	r.db.customers[id] = row
+	return row.customer(), nil
+}
+func (r *Managers) CreateSale(ctx context.Context, sale *managers.Sale) error {
+	defer r.lock()()
+
+	if _, ok := r.db.managers[sale.Manager_id]; !ok && sale.Manager_id != 0 {
+		return managers.ErrUserNotFound
+	}
+	now := time.Now()
+	row := saleRow{
+		ID:         r.db.next("sales"),
+		ReceiptNo:  r.db.next("receipts"),
+		ManagerID:  sale.Manager_id,
+		CustomerID: sale.Customer_id,
+		Status:     sale.Status,
+		Created:    now,
+	}
+	r.db.sales[row.ID] = row
+	sale.ID, sale.Receipt_no, sale.Created = row.ID, row.ReceiptNo, row.Created
+
+	for _, v := range sale.Positions {
+		if _, ok := r.db.products[v.Product_id]; !ok {
+			return managers.ErrProductNotFound
+		}
+		position := salePositionRow{
+			ID:              r.db.next("sales_positions"),
+			SaleID:          sale.ID,
+			ProductID:       v.Product_id,
+			Name:            v.Name,
+			Price:           v.Price,
+			Qty:             v.Qty,
+			BasePrice:       v.Base_price,
+			DiscountPercent: v.Discount_percent,
+			DiscountAmount:  v.Discount_amount,
+			PromoDiscount:   v.Promo_discount,
+			PointsDiscount:  v.Points_discount,
+			Created:         now,
+		}
+		r.db.salesPositions[position.ID] = position
+		v.ID = position.ID
+	}
+	return nil
+}
+
+func (r *Managers) SalesTotal(ctx context.Context, managerID int64) (int, error) {
+	defer r.lock()()
+
+	total := 0
+	for _, position := range r.db.salesPositions {
+		if sale := r.db.sales[position.SaleID]; sale.ManagerID == managerID && sale.counted() {
+			total += position.Price * position.Qty
+		}
+	}
+	return total, nil
+}
+
+func (row productRow) product() *managers.Product {
+	return &managers.Product{
+		ID:      row.ID,
+		Name:    row.Name,
+		Price:   row.Price,
+		Qty:     row.Qty,
+		Active:  row.Active,
+		Created: row.Created,
+	}
+}
+
+func (row managerRow) manager() *managers.Manager {
+	roles := make([]string, len(row.Roles))
+	copy(roles, row.Roles)
+	return &managers.Manager{
+		ID:          row.ID,
+		Name:        row.Name,
+		Salary:      row.Salary,
+		Plan:        row.Plan,
+		Boss_id:     row.BossID,
+		Departament: row.Department,
+		Phone:       row.Phone,
+		Roles:       roles,
+		Active:      row.Active,
+		Created:     row.Created,
+	}
+}
@@ -0,0 +1,494 @@
+package memory
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// DB holds every table of the in-memory backend. Rows are stored by value so
+// that a snapshot only has to copy the maps.
+type DB struct {
+	mu sync.Mutex
+	tables
+}
+
+type tables struct {
+	seq                map[string]int64
+	customers          map[int64]customerRow
+	customerTokens     map[string]tokenRow
+	managers           map[int64]managerRow
+	managerTokens      map[string]tokenRow
+	products           map[int64]productRow
+	sales              map[int64]saleRow
+	salesPositions     map[int64]salePositionRow
+	returns            map[int64]returnRow
+	returnsPositions   map[int64]returnPositionRow
+	categories         map[int64]categoryRow
+	productsCategories map[productCategoryKey]bool
+	stockMovements     map[int64]stockMovementRow
+	auditLog           map[int64]auditRow
+	managersPlans      map[int64]planRow
+	commissionRules    map[int64]commissionRuleRow
+	payrollStatements  map[int64]statementRow
+	cartItems          map[cartItemKey]cartItemRow
+	salesStatusHistory map[int64]statusChangeRow
+	payments           map[int64]paymentRow
+	wallets            map[int64]int
+	walletTransactions map[int64]walletTransactionRow
+	walletEntries      map[int64]walletEntryRow
+	loyaltyRules       map[int64]loyaltyRuleRow
+	loyaltyAccounts    map[int64]int
+	pointsTransactions map[int64]pointsTransactionRow
+	promos             map[int64]promoRow
+	promoRedemptions   map[int64]promoRedemptionRow
+}
+
+type customerRow struct {
+	ID       int64
+	Name     string
+	Phone    string
+	Password string
+	Active   bool
+	Created  time.Time
+}
+
+type tokenRow struct {
+	OwnerID int64
+	Expire  time.Time
+	Created time.Time
+}
+
+type managerRow struct {
+	ID         int64
+	Name       string
+	Salary     int
+	Plan       int
+	BossID     int64
+	Department string
+	Phone      string
+	Password   string
+	Roles      []string
+	Active     bool
+	Created    time.Time
+}
+
+type productRow struct {
+	ID      int64
+	Name    string
+	Price   int
+	Qty     int
+	Active  bool
+	Created time.Time
+}
+
+type saleRow struct {
+	ID         int64
+	ReceiptNo  int64
+	ManagerID  int64
+	CustomerID int64
+	Status     string
+	Created    time.Time
+}
+
+// counted tells whether totals and reports count the sale: drafts have
+// taken no stock yet and cancelled orders gave it back.
+func (row saleRow) counted() bool {
+	return row.Status != managers.SaleDraft && row.Status != managers.SaleCancelled
+}
+
+type statusChangeRow struct {
+	ID        int64
+	SaleID    int64
+	Status    string
+	ManagerID int64
+	Created   time.Time
+}
+
+type salePositionRow struct {
+	ID              int64
+	SaleID          int64
+	ProductID       int64
+	Name            string
+	Price           int
+	Qty             int
+	BasePrice       int
+	DiscountPercent int
+	DiscountAmount  int
+	PromoDiscount   int
+	PointsDiscount  int
+	Created         time.Time
+}
+
+type returnRow struct {
+	ID        int64
+	SaleID    int64
+	ManagerID int64
+	Reason    string
+	Refund    int
+	Created   time.Time
+}
+
+type returnPositionRow struct {
+	ID             int64
+	ReturnID       int64
+	SalePositionID int64
+	ProductID      int64
+	Price          int
+	Qty            int
+	Created        time.Time
+}
+
+type stockMovementRow struct {
+	ID        int64
+	ProductID int64
+	ManagerID int64
+	Kind      string
+	Qty       int
+	Balance   int
+	Reason    string
+	SaleID    *int64
+	ReturnID  *int64
+	Created   time.Time
+}
+
+type paymentRow struct {
+	ID        int64
+	SaleID    int64
+	Method    string
+	Provider  string
+	Reference string
+	Amount    int
+	Refunded  int
+	Status    string
+	ManagerID int64
+	Created   time.Time
+}
+
+// walletTransactionRow keeps the balance of the wallet after the
+// transaction; wallets holds the current balance by customer id.
+type walletTransactionRow struct {
+	ID         int64
+	CustomerID int64
+	Kind       string
+	Amount     int
+	Balance    int
+	SaleID     *int64
+	PaymentID  *int64
+	ManagerID  int64
+	Comment    string
+	Created    time.Time
+}
+
+type walletEntryRow struct {
+	ID            int64
+	TransactionID int64
+	Account       string
+	Amount        int
+}
+
+type loyaltyRuleRow struct {
+	ID         int64
+	Kind       string
+	RateBP     int
+	Multiplier int
+	CategoryID *int64
+	Tier       string
+	MinSpend   int
+	Created    time.Time
+}
+
+// pointsTransactionRow keeps the balance of the account after the
+// transaction; loyaltyAccounts holds the current balance by customer id.
+type pointsTransactionRow struct {
+	ID         int64
+	CustomerID int64
+	Kind       string
+	Points     int
+	Balance    int
+	Remaining  int
+	SaleID     *int64
+	ReturnID   *int64
+	Created    time.Time
+}
+
+type promoRow struct {
+	ID                 int64
+	Code               string
+	Kind               string
+	Value              int
+	MinBasket          int
+	ProductID          *int64
+	CategoryID         *int64
+	Starts             *time.Time
+	Ends               *time.Time
+	MaxUses            int
+	MaxUsesPerCustomer int
+	Active             bool
+	Created            time.Time
+}
+
+type promoRedemptionRow struct {
+	ID         int64
+	PromoID    int64
+	CustomerID int64
+	SaleID     int64
+	Discount   int
+	Created    time.Time
+}
+
+type auditRow struct {
+	ID        int64
+	ManagerID int64
+	Action    string
+	Entity    string
+	EntityID  int64
+	Before    []byte
+	After     []byte
+	RequestID string
+	Created   time.Time
+}
+
+type planRow struct {
+	ID        int64
+	ManagerID int64
+	Period    string
+	Start     time.Time
+	Amount    int
+	SetBy     int64
+	Created   time.Time
+}
+
+type commissionRuleRow struct {
+	ID            int64
+	Kind          string
+	RateBP        int
+	MinAttainment int
+	CategoryID    *int64
+	Created       time.Time
+}
+
+// statementRow keeps the lines of a finalized statement inline; they are
+// never changed once written.
+type statementRow struct {
+	ID          int64
+	ManagerID   int64
+	Name        string
+	Month       time.Time
+	Salary      int
+	Plan        int
+	Actual      int
+	Attainment  int
+	Commission  int
+	Total       int
+	Lines       []managers.CommissionLine
+	FinalizedBy int64
+	Finalized   time.Time
+}
+
+type categoryRow struct {
+	ID       int64
+	ParentID *int64
+	Name     string
+	Created  time.Time
+}
+
+type productCategoryKey struct {
+	ProductID  int64
+	CategoryID int64
+}
+
+type cartItemKey struct {
+	CustomerID int64
+	ProductID  int64
+}
+
+type cartItemRow struct {
+	Qty   int
+	Added time.Time
+}
+
+// NewDB creates an empty database seeded with the same admin manager as
+// migration 0002_admin (phone +992000000001, password secret).
+func NewDB() *DB {
+	db := &DB{tables: tables{
+		seq:                make(map[string]int64),
+		customers:          make(map[int64]customerRow),
+		customerTokens:     make(map[string]tokenRow),
+		managers:           make(map[int64]managerRow),
+		managerTokens:      make(map[string]tokenRow),
+		products:           make(map[int64]productRow),
+		sales:              make(map[int64]saleRow),
+		salesPositions:     make(map[int64]salePositionRow),
+		returns:            make(map[int64]returnRow),
+		returnsPositions:   make(map[int64]returnPositionRow),
+		categories:         make(map[int64]categoryRow),
+		productsCategories: make(map[productCategoryKey]bool),
+		stockMovements:     make(map[int64]stockMovementRow),
+		auditLog:           make(map[int64]auditRow),
+		managersPlans:      make(map[int64]planRow),
+		commissionRules:    make(map[int64]commissionRuleRow),
+		payrollStatements:  make(map[int64]statementRow),
+		cartItems:          make(map[cartItemKey]cartItemRow),
+		salesStatusHistory: make(map[int64]statusChangeRow),
+		payments:           make(map[int64]paymentRow),
+		wallets:            make(map[int64]int),
+		walletTransactions: make(map[int64]walletTransactionRow),
+		walletEntries:      make(map[int64]walletEntryRow),
+		loyaltyRules:       make(map[int64]loyaltyRuleRow),
+		loyaltyAccounts:    make(map[int64]int),
+		pointsTransactions: make(map[int64]pointsTransactionRow),
+		promos:             make(map[int64]promoRow),
+		promoRedemptions:   make(map[int64]promoRedemptionRow),
+	}}
+
+	id := db.next("managers")
+	db.managers[id] = managerRow{
+		ID:       id,
+		Name:     "vasya",
+		Phone:    "+992000000001",
+		Password: "$2a$10$oc/QUw9dRpQAtWeqrs/ma.w7gH23qJHAWDrrLI6GkYTg/b9J.YMo.",
+		Roles:    []string{"MANAGER", "ADMIN"},
+		Active:   true,
+		Created:  time.Now(),
+	}
+	return db
+}
+
+// next returns the next value of the named sequence.
+func (t *tables) next(name string) int64 {
+	t.seq[name]++
+	return t.seq[name]
+}
+
+// snapshot copies every table so that a failed transaction can be undone.
+func (t *tables) snapshot() tables {
+	return tables{
+		seq:                copyMap(t.seq).(map[string]int64),
+		customers:          copyMap(t.customers).(map[int64]customerRow),
+		customerTokens:     copyMap(t.customerTokens).(map[string]tokenRow),
+		managers:           copyMap(t.managers).(map[int64]managerRow),
+		managerTokens:      copyMap(t.managerTokens).(map[string]tokenRow),
+		products:           copyMap(t.products).(map[int64]productRow),
+		sales:              copyMap(t.sales).(map[int64]saleRow),
+		salesPositions:     copyMap(t.salesPositions).(map[int64]salePositionRow),
+		returns:            copyMap(t.returns).(map[int64]returnRow),
+		returnsPositions:   copyMap(t.returnsPositions).(map[int64]returnPositionRow),
+		categories:         copyMap(t.categories).(map[int64]categoryRow),
+		productsCategories: copyMap(t.productsCategories).(map[productCategoryKey]bool),
+		stockMovements:     copyMap(t.stockMovements).(map[int64]stockMovementRow),
+		auditLog:           copyMap(t.auditLog).(map[int64]auditRow),
+		managersPlans:      copyMap(t.managersPlans).(map[int64]planRow),
+		commissionRules:    copyMap(t.commissionRules).(map[int64]commissionRuleRow),
+		payrollStatements:  copyMap(t.payrollStatements).(map[int64]statementRow),
+		cartItems:          copyMap(t.cartItems).(map[cartItemKey]cartItemRow),
+		salesStatusHistory: copyMap(t.salesStatusHistory).(map[int64]statusChangeRow),
+		payments:           copyMap(t.payments).(map[int64]paymentRow),
+		wallets:            copyMap(t.wallets).(map[int64]int),
+		walletTransactions: copyMap(t.walletTransactions).(map[int64]walletTransactionRow),
+		walletEntries:      copyMap(t.walletEntries).(map[int64]walletEntryRow),
+		loyaltyRules:       copyMap(t.loyaltyRules).(map[int64]loyaltyRuleRow),
+		loyaltyAccounts:    copyMap(t.loyaltyAccounts).(map[int64]int),
+		pointsTransactions: copyMap(t.pointsTransactions).(map[int64]pointsTransactionRow),
+		promos:             copyMap(t.promos).(map[int64]promoRow),
+		promoRedemptions:   copyMap(t.promoRedemptions).(map[int64]promoRedemptionRow),
+	}
+}
+
+// conn is embedded by repositories. Outside of a transaction every call
+// takes the database lock; inside one the lock is already held by WithTx.
+type conn struct {
+	db   *DB
+	inTx bool
+}
+
+func (c conn) lock() func() {
+	if c.inTx {
+		return func() {}
+	}
+	c.db.mu.Lock()
+	return c.db.mu.Unlock
+}
+
+// tx runs fn holding the database lock and restores the previous state of
+// all tables if fn fails.
+func (c conn) tx(fn func(c conn) error) error {
+	defer c.lock()()
+
+	saved := c.db.snapshot()
+	err := fn(conn{db: c.db, inTx: true})
+	if err != nil {
+		c.db.tables = saved
+		return err
+	}
+	return nil
+}
+
+func sortedIDs(keys []int64) []int64 {
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func (t *tables) saleIDs() []int64 {
+	ids := make([]int64, 0, len(t.sales))
+	for id := range t.sales {
+		ids = append(ids, id)
+	}
+	return sortedIDs(ids)
+}
+
+func (t *tables) positionsOf(saleID int64) []salePositionRow {
+	ids := make([]int64, 0)
+	for id, row := range t.salesPositions {
+		if row.SaleID == saleID {
+			ids = append(ids, id)
+		}
+	}
+	positions := make([]salePositionRow, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		positions = append(positions, t.salesPositions[id])
+	}
+	return positions
+}
+
+// sale returns the sale with its positions.
+func (t *tables) sale(id int64) *managers.Sale {
+	row := t.sales[id]
+	sale := &managers.Sale{
+		ID:          row.ID,
+		Receipt_no:  row.ReceiptNo,
+		Manager_id:  row.ManagerID,
+		Customer_id: row.CustomerID,
+		Status:      row.Status,
+		Created:     row.Created,
+	}
+	for _, position := range t.positionsOf(id) {
+		sale.Positions = append(sale.Positions, &managers.SalePosition{
+			ID:               position.ID,
+			Product_id:       position.ProductID,
+			Name:             position.Name,
+			Qty:              position.Qty,
+			Base_price:       position.BasePrice,
+			Discount_percent: position.DiscountPercent,
+			Discount_amount:  position.DiscountAmount,
+			Promo_discount:   position.PromoDiscount,
+			Points_discount:  position.PointsDiscount,
+			Price:            position.Price,
+		})
+	}
+	return sale
+}
+
+// copyMap returns a shallow copy of any map.
+func copyMap(m interface{}) interface{} {
+	src := reflect.ValueOf(m)
+	dst := reflect.MakeMapWithSize(src.Type(), src.Len())
+	iter := src.MapRange()
+	for iter.Next() {
+		dst.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return dst.Interface()
+}
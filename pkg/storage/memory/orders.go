@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) SalesByStatus(ctx context.Context, status string) ([]*managers.Sale, error) {
+	defer r.lock()()
+
+	items := make([]*managers.Sale, 0)
+	for _, saleID := range r.db.saleIDs() {
+		if r.db.sales[saleID].Status != status {
+			continue
+		}
+		sale := r.db.sale(saleID)
+		if len(sale.Positions) > 0 {
+			items = append(items, sale)
+		}
+	}
+	return items, nil
+}
+
+func (r *Managers) SetSaleStatus(ctx context.Context, id int64, status string) error {
+	defer r.lock()()
+
+	row, ok := r.db.sales[id]
+	if !ok {
+		return managers.ErrSaleNotFound
+	}
+	row.Status = status
+	r.db.sales[id] = row
+	return nil
+}
+
+func (r *Managers) CreateStatusChange(ctx context.Context, change *managers.StatusChange) error {
+	defer r.lock()()
+
+	row := statusChangeRow{
+		ID:        r.db.next("sales_status_history"),
+		SaleID:    change.Sale_id,
+		Status:    change.Status,
+		ManagerID: change.Manager_id,
+		Created:   time.Now(),
+	}
+	r.db.salesStatusHistory[row.ID] = row
+	change.ID, change.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) StatusHistory(ctx context.Context, saleID int64) ([]*managers.StatusChange, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.sales[saleID]; !ok {
+		return nil, managers.ErrSaleNotFound
+	}
+	ids := make([]int64, 0)
+	for id, row := range r.db.salesStatusHistory {
+		if row.SaleID == saleID {
+			ids = append(ids, id)
+		}
+	}
+	items := make([]*managers.StatusChange, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		row := r.db.salesStatusHistory[id]
+		items = append(items, &managers.StatusChange{
+			ID:         row.ID,
+			Sale_id:    row.SaleID,
+			Status:     row.Status,
+			Manager_id: row.ManagerID,
+			Created:    row.Created,
+		})
+	}
+	return items, nil
+}
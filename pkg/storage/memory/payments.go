@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreatePayment(ctx context.Context, payment *managers.Payment) error {
+	defer r.lock()()
+
+	if _, ok := r.db.sales[payment.Sale_id]; !ok {
+		return managers.ErrSaleNotFound
+	}
+	row := paymentRow{
+		ID:        r.db.next("payments"),
+		SaleID:    payment.Sale_id,
+		Method:    payment.Method,
+		Provider:  payment.Provider,
+		Reference: payment.Reference,
+		Amount:    payment.Amount,
+		Refunded:  payment.Refunded,
+		Status:    payment.Status,
+		ManagerID: payment.Manager_id,
+		Created:   time.Now(),
+	}
+	r.db.payments[row.ID] = row
+	payment.ID, payment.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) UpdatePayment(ctx context.Context, payment *managers.Payment) error {
+	defer r.lock()()
+
+	row, ok := r.db.payments[payment.ID]
+	if !ok {
+		return managers.ErrPaymentNotFound
+	}
+	row.Provider, row.Reference = payment.Provider, payment.Reference
+	row.Refunded, row.Status = payment.Refunded, payment.Status
+	r.db.payments[row.ID] = row
+	return nil
+}
+
+func (r *Managers) LockPayment(ctx context.Context, id int64) (*managers.Payment, error) {
+	defer r.lock()()
+
+	row, ok := r.db.payments[id]
+	if !ok {
+		return nil, managers.ErrPaymentNotFound
+	}
+	return row.payment(), nil
+}
+
+func (r *Managers) PaymentByReference(ctx context.Context, provider string, reference string) (*managers.Payment, error) {
+	defer r.lock()()
+
+	for _, row := range r.db.payments {
+		if row.Method == managers.TenderCard && row.Provider == provider && row.Reference == reference {
+			return row.payment(), nil
+		}
+	}
+	return nil, managers.ErrPaymentNotFound
+}
+
+func (r *Managers) SalePayments(ctx context.Context, saleID int64) ([]*managers.Payment, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.sales[saleID]; !ok {
+		return nil, managers.ErrSaleNotFound
+	}
+	return r.db.paymentsOf(saleID), nil
+}
+
+func (r *Managers) Reconciliation(ctx context.Context, period managers.Period) ([]*managers.Reconciliation, error) {
+	defer r.lock()()
+
+	items := make([]*managers.Reconciliation, 0)
+	for _, saleID := range r.db.saleIDs() {
+		sale := r.db.sales[saleID]
+		payments := r.db.paymentsOf(saleID)
+		if !sale.counted() && len(payments) == 0 ||
+			period.From != nil && sale.Created.Before(*period.From) ||
+			period.To != nil && sale.Created.After(*period.To) {
+			continue
+		}
+		item := &managers.Reconciliation{
+			Sale_id:    sale.ID,
+			Receipt_no: sale.ReceiptNo,
+			Status:     sale.Status,
+			Created:    sale.Created,
+		}
+		if sale.Status != managers.SaleCancelled {
+			for _, position := range r.db.positionsOf(saleID) {
+				item.Total += position.Price * position.Qty
+			}
+		}
+		for _, ret := range r.db.returns {
+			if ret.SaleID == saleID {
+				item.Returned += ret.Refund
+			}
+		}
+		for _, payment := range payments {
+			if payment.Status != managers.PaymentVoided && payment.Status != managers.PaymentFailed {
+				item.Paid += payment.Amount - payment.Refunded
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// paymentsOf returns the payments of the sale ordered by id.
+func (t *tables) paymentsOf(saleID int64) []*managers.Payment {
+	ids := make([]int64, 0)
+	for id, row := range t.payments {
+		if row.SaleID == saleID {
+			ids = append(ids, id)
+		}
+	}
+	items := make([]*managers.Payment, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, t.payments[id].payment())
+	}
+	return items
+}
+
+func (row paymentRow) payment() *managers.Payment {
+	return &managers.Payment{
+		ID:         row.ID,
+		Sale_id:    row.SaleID,
+		Method:     row.Method,
+		Provider:   row.Provider,
+		Reference:  row.Reference,
+		Amount:     row.Amount,
+		Refunded:   row.Refunded,
+		Status:     row.Status,
+		Manager_id: row.ManagerID,
+		Created:    row.Created,
+	}
+}
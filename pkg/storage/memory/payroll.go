@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) ManagerSales(ctx context.Context, managerID int64, period managers.Period) ([]*managers.Sale, error) {
+	defer r.lock()()
+
+	items := make([]*managers.Sale, 0)
+	for _, saleID := range r.db.saleIDs() {
+		row := r.db.sales[saleID]
+		switch {
+		case row.ManagerID != managerID:
+		case period.From != nil && row.Created.Before(*period.From):
+		case period.To != nil && row.Created.After(*period.To):
+		case !row.counted():
+		default:
+			sale := r.db.sale(saleID)
+			if len(sale.Positions) > 0 {
+				items = append(items, sale)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (r *Managers) CreateCommissionRule(ctx context.Context, rule *managers.CommissionRule) error {
+	defer r.lock()()
+
+	row := commissionRuleRow{
+		ID:            r.db.next("commission_rules"),
+		Kind:          rule.Kind,
+		RateBP:        rule.Rate_bp,
+		MinAttainment: rule.Min_attainment,
+		CategoryID:    rule.Category_id,
+		Created:       time.Now(),
+	}
+	r.db.commissionRules[row.ID] = row
+	rule.ID, rule.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) CommissionRules(ctx context.Context) ([]*managers.CommissionRule, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0, len(r.db.commissionRules))
+	for id := range r.db.commissionRules {
+		ids = append(ids, id)
+	}
+	items := make([]*managers.CommissionRule, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, r.db.commissionRules[id].rule())
+	}
+	return items, nil
+}
+
+func (r *Managers) DeleteCommissionRule(ctx context.Context, id int64) (*managers.CommissionRule, error) {
+	defer r.lock()()
+
+	row, ok := r.db.commissionRules[id]
+	if !ok {
+		return nil, managers.ErrCommissionRuleNotFound
+	}
+	delete(r.db.commissionRules, id)
+	return row.rule(), nil
+}
+
+func (r *Managers) CreateStatement(ctx context.Context, statement *managers.Statement) error {
+	defer r.lock()()
+
+	for _, row := range r.db.payrollStatements {
+		if row.ManagerID == statement.Manager_id && row.Month.Equal(statement.Month) {
+			return managers.ErrStatementFinalized
+		}
+	}
+	row := statementRow{
+		ID:          r.db.next("payroll_statements"),
+		ManagerID:   statement.Manager_id,
+		Name:        statement.Name,
+		Month:       statement.Month,
+		Salary:      statement.Salary,
+		Plan:        statement.Plan,
+		Actual:      statement.Actual,
+		Attainment:  statement.Attainment,
+		Commission:  statement.Commission,
+		Total:       statement.Total,
+		Lines:       make([]managers.CommissionLine, 0, len(statement.Lines)),
+		FinalizedBy: statement.Finalized_by,
+		Finalized:   time.Now(),
+	}
+	for _, line := range statement.Lines {
+		row.Lines = append(row.Lines, *line)
+	}
+	r.db.payrollStatements[row.ID] = row
+	statement.ID, statement.Finalized = row.ID, &row.Finalized
+	return nil
+}
+
+func (r *Managers) Statement(ctx context.Context, managerID int64, month time.Time) (*managers.Statement, error) {
+	defer r.lock()()
+
+	for _, row := range r.db.payrollStatements {
+		if row.ManagerID == managerID && row.Month.Equal(month) {
+			return row.statement(), nil
+		}
+	}
+	return nil, managers.ErrStatementNotFound
+}
+
+func (r *Managers) Statements(ctx context.Context, month time.Time) ([]*managers.Statement, error) {
+	defer r.lock()()
+
+	byManager := make(map[int64]statementRow)
+	ids := make([]int64, 0)
+	for _, row := range r.db.payrollStatements {
+		if row.Month.Equal(month) {
+			byManager[row.ManagerID] = row
+			ids = append(ids, row.ManagerID)
+		}
+	}
+	items := make([]*managers.Statement, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, byManager[id].statement())
+	}
+	return items, nil
+}
+
+func (row commissionRuleRow) rule() *managers.CommissionRule {
+	return &managers.CommissionRule{
+		ID:             row.ID,
+		Kind:           row.Kind,
+		Rate_bp:        row.RateBP,
+		Min_attainment: row.MinAttainment,
+		Category_id:    row.CategoryID,
+		Created:        row.Created,
+	}
+}
+
+func (row statementRow) statement() *managers.Statement {
+	finalized := row.Finalized
+	statement := &managers.Statement{
+		ID:           row.ID,
+		Manager_id:   row.ManagerID,
+		Name:         row.Name,
+		Month:        row.Month,
+		Status:       managers.StatementFinal,
+		Salary:       row.Salary,
+		Plan:         row.Plan,
+		Actual:       row.Actual,
+		Attainment:   row.Attainment,
+		Commission:   row.Commission,
+		Total:        row.Total,
+		Lines:        make([]*managers.CommissionLine, 0, len(row.Lines)),
+		Finalized_by: row.FinalizedBy,
+		Finalized:    &finalized,
+	}
+	for i := range row.Lines {
+		line := row.Lines[i]
+		statement.Lines = append(statement.Lines, &line)
+	}
+	return statement
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreatePlan(ctx context.Context, plan *managers.Plan) error {
+	defer r.lock()()
+
+	if _, ok := r.db.managers[plan.Manager_id]; !ok {
+		return managers.ErrUserNotFound
+	}
+	row := planRow{
+		ID:        r.db.next("managers_plans"),
+		ManagerID: plan.Manager_id,
+		Period:    plan.Period,
+		Start:     plan.Start,
+		Amount:    plan.Amount,
+		SetBy:     plan.Set_by,
+		Created:   time.Now(),
+	}
+	r.db.managersPlans[row.ID] = row
+	plan.ID, plan.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) Plans(ctx context.Context, managerID int64) ([]*managers.Plan, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	for id, row := range r.db.managersPlans {
+		if row.ManagerID == managerID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	items := make([]*managers.Plan, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, r.db.managersPlans[id].plan())
+	}
+	return items, nil
+}
+
+func (r *Managers) PlanTargets(ctx context.Context, period string, start time.Time) (map[int64]int, error) {
+	defer r.lock()()
+
+	months := 1
+	if period == managers.PlanQuarter {
+		months = 3
+	}
+	targets := make(map[int64]int)
+	for id, row := range r.db.managers {
+		targets[id] = row.Plan * months
+	}
+	latest := make(map[int64]int64)
+	for id, row := range r.db.managersPlans {
+		if row.Period == period && row.Start.Equal(start) && id > latest[row.ManagerID] {
+			latest[row.ManagerID] = id
+			targets[row.ManagerID] = row.Amount
+		}
+	}
+	return targets, nil
+}
+
+func (row planRow) plan() *managers.Plan {
+	return &managers.Plan{
+		ID:         row.ID,
+		Manager_id: row.ManagerID,
+		Period:     row.Period,
+		Start:      row.Start,
+		Amount:     row.Amount,
+		Set_by:     row.SetBy,
+		Created:    row.Created,
+	}
+}
@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreatePromo(ctx context.Context, promo *managers.Promo) error {
+	defer r.lock()()
+
+	for _, row := range r.db.promos {
+		if row.Code == promo.Code {
+			return managers.ErrPromoCodeUsed
+		}
+	}
+	row := promoRow{
+		ID:                 r.db.next("promos"),
+		Code:               promo.Code,
+		Kind:               promo.Kind,
+		Value:              promo.Value,
+		MinBasket:          promo.Min_basket,
+		ProductID:          promo.Product_id,
+		CategoryID:         promo.Category_id,
+		Starts:             promo.Starts,
+		Ends:               promo.Ends,
+		MaxUses:            promo.Max_uses,
+		MaxUsesPerCustomer: promo.Max_uses_per_customer,
+		Active:             promo.Active,
+		Created:            time.Now(),
+	}
+	r.db.promos[row.ID] = row
+	promo.ID, promo.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) Promos(ctx context.Context) ([]*managers.Promo, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0, len(r.db.promos))
+	for id := range r.db.promos {
+		ids = append(ids, id)
+	}
+	items := make([]*managers.Promo, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		items = append(items, r.db.promo(r.db.promos[id]))
+	}
+	return items, nil
+}
+
+func (r *Managers) Promo(ctx context.Context, id int64) (*managers.Promo, error) {
+	defer r.lock()()
+
+	row, ok := r.db.promos[id]
+	if !ok {
+		return nil, managers.ErrPromoNotFound
+	}
+	return r.db.promo(row), nil
+}
+
+func (r *Managers) LockPromo(ctx context.Context, code string) (*managers.Promo, error) {
+	defer r.lock()()
+
+	for _, row := range r.db.promos {
+		if row.Code == code {
+			return r.db.promo(row), nil
+		}
+	}
+	return nil, managers.ErrPromoNotFound
+}
+
+func (r *Managers) SetPromoActive(ctx context.Context, id int64, active bool) error {
+	defer r.lock()()
+
+	row, ok := r.db.promos[id]
+	if !ok {
+		return managers.ErrPromoNotFound
+	}
+	row.Active = active
+	r.db.promos[id] = row
+	return nil
+}
+
+func (r *Managers) CustomerPromoUses(ctx context.Context, promoID int64, customerID int64) (int, error) {
+	defer r.lock()()
+
+	uses := 0
+	for _, row := range r.db.promoRedemptions {
+		if row.PromoID == promoID && row.CustomerID == customerID &&
+			r.db.sales[row.SaleID].Status != managers.SaleCancelled {
+			uses++
+		}
+	}
+	return uses, nil
+}
+
+func (r *Managers) CreatePromoRedemption(ctx context.Context, redemption *managers.PromoRedemption) error {
+	defer r.lock()()
+
+	row := promoRedemptionRow{
+		ID:         r.db.next("promo_redemptions"),
+		PromoID:    redemption.Promo_id,
+		CustomerID: redemption.Customer_id,
+		SaleID:     redemption.Sale_id,
+		Discount:   redemption.Discount,
+		Created:    time.Now(),
+	}
+	r.db.promoRedemptions[row.ID] = row
+	redemption.ID, redemption.Created = row.ID, row.Created
+	redemption.Status = r.db.sales[row.SaleID].Status
+	return nil
+}
+
+func (r *Managers) PromoRedemptions(ctx context.Context, promoID int64) ([]*managers.PromoRedemption, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	for id, row := range r.db.promoRedemptions {
+		if row.PromoID == promoID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	items := make([]*managers.PromoRedemption, 0, len(ids))
+	for _, id := range ids {
+		row := r.db.promoRedemptions[id]
+		items = append(items, &managers.PromoRedemption{
+			ID:          row.ID,
+			Promo_id:    row.PromoID,
+			Customer_id: row.CustomerID,
+			Sale_id:     row.SaleID,
+			Status:      r.db.sales[row.SaleID].Status,
+			Discount:    row.Discount,
+			Created:     row.Created,
+		})
+	}
+	return items, nil
+}
+
+// promo returns the promo code with its uses for sales that were not
+// cancelled.
+func (t *tables) promo(row promoRow) *managers.Promo {
+	promo := &managers.Promo{
+		ID:                    row.ID,
+		Code:                  row.Code,
+		Kind:                  row.Kind,
+		Value:                 row.Value,
+		Min_basket:            row.MinBasket,
+		Product_id:            row.ProductID,
+		Category_id:           row.CategoryID,
+		Starts:                row.Starts,
+		Ends:                  row.Ends,
+		Max_uses:              row.MaxUses,
+		Max_uses_per_customer: row.MaxUsesPerCustomer,
+		Active:                row.Active,
+		Created:               row.Created,
+	}
+	for _, redemption := range t.promoRedemptions {
+		if redemption.PromoID == row.ID && t.sales[redemption.SaleID].Status != managers.SaleCancelled {
+			promo.Uses++
+			promo.Discount += redemption.Discount
+		}
+	}
+	return promo
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/khiki1995/crud/pkg/receipts"
+)
+
+func (r *Managers) SaleReceipt(ctx context.Context, id int64) (*receipts.Receipt, error) {
+	defer r.lock()()
+
+	return r.db.saleReceipt(id)
+}
+
+func (r *Customers) SaleReceipt(ctx context.Context, id int64) (*receipts.Receipt, error) {
+	defer r.lock()()
+
+	return r.db.saleReceipt(id)
+}
+
+func (t *tables) saleReceipt(id int64) (*receipts.Receipt, error) {
+	sale, ok := t.sales[id]
+	if !ok {
+		return nil, receipts.ErrNotFound
+	}
+	receipt := &receipts.Receipt{
+		Number:      sale.ReceiptNo,
+		Sale_id:     sale.ID,
+		Created:     sale.Created,
+		Manager_id:  sale.ManagerID,
+		Manager:     t.managers[sale.ManagerID].Name,
+		Customer_id: sale.CustomerID,
+		Customer:    t.customers[sale.CustomerID].Name,
+		Lines:       make([]*receipts.Line, 0),
+	}
+	for _, position := range t.positionsOf(id) {
+		receipt.Lines = append(receipt.Lines, &receipts.Line{
+			Product_id: position.ProductID,
+			Name:       position.Name,
+			Qty:        position.Qty,
+			Base_price: position.BasePrice,
+			Price:      position.Price,
+		})
+	}
+	return receipt, nil
+}
@@ -0,0 +1,208 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) ManagerTeam(ctx context.Context, id int64) (*managers.Team, error) {
+	defer r.lock()()
+
+	row, ok := r.db.managers[id]
+	if !ok {
+		return nil, managers.ErrUserNotFound
+	}
+	team := &managers.Team{Department: row.Department}
+	if row.BossID != 0 {
+		bossID := row.BossID
+		team.Boss_id = &bossID
+	}
+	return team, nil
+}
+
+func (r *Managers) SetManagerTeam(ctx context.Context, id int64, team *managers.Team) error {
+	defer r.lock()()
+
+	row, ok := r.db.managers[id]
+	if !ok {
+		return managers.ErrUserNotFound
+	}
+	row.BossID = 0
+	if team.Boss_id != nil {
+		row.BossID = *team.Boss_id
+	}
+	row.Department = team.Department
+	r.db.managers[id] = row
+	return nil
+}
+
+func (r *Managers) Subordinates(ctx context.Context, id int64) ([]int64, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	if _, ok := r.db.managers[id]; !ok {
+		return ids, nil
+	}
+	tree := map[int64]bool{id: true}
+	for grown := true; grown; {
+		grown = false
+		for _, row := range r.db.managers {
+			if row.BossID != 0 && tree[row.BossID] && !tree[row.ID] {
+				tree[row.ID] = true
+				grown = true
+			}
+		}
+	}
+	for id := range tree {
+		ids = append(ids, id)
+	}
+	return sortedIDs(ids), nil
+}
+
+func (r *Managers) SalesByManager(ctx context.Context, period managers.Period) ([]*managers.ManagerSales, error) {
+	defer r.lock()()
+
+	inPeriod := func(row saleRow) bool {
+		return (period.From == nil || !row.Created.Before(*period.From)) &&
+			(period.To == nil || !row.Created.After(*period.To))
+	}
+	totals := make(map[int64]*managers.SalesTotals)
+	for id := range r.db.managers {
+		totals[id] = &managers.SalesTotals{}
+	}
+	for _, saleID := range r.db.saleIDs() {
+		sale := r.db.sales[saleID]
+		if !sale.counted() || !inPeriod(sale) || totals[sale.ManagerID] == nil {
+			continue
+		}
+		totals[sale.ManagerID].Sales_count++
+		for _, position := range r.db.positionsOf(saleID) {
+			totals[sale.ManagerID].Sales_total += position.Price * position.Qty
+		}
+	}
+	for _, ret := range r.db.returns {
+		sale := r.db.sales[ret.SaleID]
+		if !inPeriod(saleRow{Created: ret.Created}) || totals[sale.ManagerID] == nil {
+			continue
+		}
+		totals[sale.ManagerID].Returns_total += ret.Refund
+	}
+
+	ids := make([]int64, 0, len(r.db.managers))
+	for id := range r.db.managers {
+		ids = append(ids, id)
+	}
+	items := make([]*managers.ManagerSales, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		row := r.db.managers[id]
+		item := &managers.ManagerSales{Manager_id: row.ID, Name: row.Name, Department: row.Department}
+		if row.BossID != 0 {
+			bossID := row.BossID
+			item.Boss_id = &bossID
+		}
+		item.SalesTotals = *totals[id]
+		item.Net_total = item.Sales_total - item.Returns_total
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *Managers) SalesReport(ctx context.Context, query *managers.SalesReportQuery, fn func(row *managers.SalesReportRow) error) error {
+	rows, err := r.salesReport(query)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		err = fn(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// salesReport builds the whole report under the lock so that fn runs
+// without holding it.
+func (r *Managers) salesReport(query *managers.SalesReportQuery) ([]*managers.SalesReportRow, error) {
+	defer r.lock()()
+
+	type group struct {
+		row   *managers.SalesReportRow
+		order int64
+		sales map[int64]bool
+	}
+	groups := make(map[string]*group)
+	for _, saleID := range r.db.saleIDs() {
+		sale := r.db.sales[saleID]
+		if !sale.counted() ||
+			query.Period.From != nil && sale.Created.Before(*query.Period.From) ||
+			query.Period.To != nil && sale.Created.After(*query.Period.To) {
+			continue
+		}
+		for _, position := range r.db.positionsOf(saleID) {
+			var order int64
+			var key, label string
+			switch query.Group_by {
+			case managers.ReportByProduct:
+				order, label = position.ProductID, r.db.products[position.ProductID].Name
+			case managers.ReportByCustomer:
+				order, label = sale.CustomerID, r.db.customers[sale.CustomerID].Name
+			case managers.ReportByManager:
+				order, label = sale.ManagerID, r.db.managers[sale.ManagerID].Name
+			default:
+				key = reportDay(query.Group_by, sale.Created.In(query.Location)).Format("2006-01-02")
+				label = key
+			}
+			if key == "" {
+				key = strconv.FormatInt(order, 10)
+			}
+
+			g, ok := groups[key]
+			if !ok {
+				g = &group{
+					row:   &managers.SalesReportRow{Key: key, Label: label},
+					order: order,
+					sales: make(map[int64]bool),
+				}
+				groups[key] = g
+			}
+			g.sales[saleID] = true
+			g.row.Units += position.Qty
+			g.row.Revenue += position.Price * position.Qty
+		}
+	}
+
+	items := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		g.row.Sales_count = len(g.sales)
+		items = append(items, g)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].order != items[j].order {
+			return items[i].order < items[j].order
+		}
+		return items[i].row.Key < items[j].row.Key
+	})
+	rows := make([]*managers.SalesReportRow, 0, len(items))
+	for _, g := range items {
+		rows = append(rows, g.row)
+	}
+	return rows, nil
+}
+
+// reportDay returns the first day of the day, week or month containing t.
+func reportDay(groupBy string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch groupBy {
+	case managers.ReportByWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case managers.ReportByMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) LockSale(ctx context.Context, id int64) (*managers.Sale, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.sales[id]; !ok {
+		return nil, managers.ErrSaleNotFound
+	}
+	return r.db.sale(id), nil
+}
+
+func (r *Managers) ReturnedQty(ctx context.Context, saleID int64) (map[int64]int, error) {
+	defer r.lock()()
+
+	returned := make(map[int64]int)
+	for _, position := range r.db.returnsPositions {
+		if r.db.returns[position.ReturnID].SaleID == saleID {
+			returned[position.SalePositionID] += position.Qty
+		}
+	}
+	return returned, nil
+}
+
+func (r *Managers) CreateReturn(ctx context.Context, ret *managers.Return) error {
+	defer r.lock()()
+
+	if _, ok := r.db.sales[ret.Sale_id]; !ok {
+		return managers.ErrSaleNotFound
+	}
+	now := time.Now()
+	row := returnRow{
+		ID:        r.db.next("returns"),
+		SaleID:    ret.Sale_id,
+		ManagerID: ret.Manager_id,
+		Reason:    ret.Reason,
+		Refund:    ret.Refund,
+		Created:   now,
+	}
+	r.db.returns[row.ID] = row
+	ret.ID, ret.Created = row.ID, row.Created
+
+	for _, v := range ret.Positions {
+		position := returnPositionRow{
+			ID:             r.db.next("returns_positions"),
+			ReturnID:       ret.ID,
+			SalePositionID: v.Sale_position_id,
+			ProductID:      v.Product_id,
+			Price:          v.Price,
+			Qty:            v.Qty,
+			Created:        now,
+		}
+		r.db.returnsPositions[position.ID] = position
+		v.ID = position.ID
+	}
+	return nil
+}
+
+func (r *Managers) Returns(ctx context.Context, saleID int64) ([]*managers.Return, error) {
+	defer r.lock()()
+
+	ids := make([]int64, 0)
+	for id, row := range r.db.returns {
+		if saleID == 0 || row.SaleID == saleID {
+			ids = append(ids, id)
+		}
+	}
+	items := make([]*managers.Return, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		row := r.db.returns[id]
+		ret := &managers.Return{
+			ID:         row.ID,
+			Sale_id:    row.SaleID,
+			Manager_id: row.ManagerID,
+			Reason:     row.Reason,
+			Refund:     row.Refund,
+			Created:    row.Created,
+		}
+		positionIDs := make([]int64, 0)
+		for positionID, position := range r.db.returnsPositions {
+			if position.ReturnID == id {
+				positionIDs = append(positionIDs, positionID)
+			}
+		}
+		for _, positionID := range sortedIDs(positionIDs) {
+			position := r.db.returnsPositions[positionID]
+			ret.Positions = append(ret.Positions, &managers.ReturnPosition{
+				ID:               position.ID,
+				Sale_position_id: position.SalePositionID,
+				Product_id:       position.ProductID,
+				Qty:              position.Qty,
+				Price:            position.Price,
+			})
+		}
+		items = append(items, ret)
+	}
+	return items, nil
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+type productMatch struct {
+	row       productRow
+	rank      float64
+	highlight string
+}
+
+// searchProducts is the in-memory counterpart of the products full-text
+// search: a product matches when each term prefixes a word of its name, and
+// ranks by the share of name words matched.
+func (t *tables) searchProducts(search *listing.Search) []productMatch {
+	matches := make([]productMatch, 0)
+	for _, row := range t.products {
+		if search.Active != nil && row.Active != *search.Active {
+			continue
+		}
+		words := listing.Terms(row.Name)
+		matched := 0
+		found := true
+		for _, term := range search.Terms {
+			if !hasPrefixed(words, term) {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		for _, word := range words {
+			if prefixedBy(word, search.Terms) {
+				matched++
+			}
+		}
+		matches = append(matches, productMatch{
+			row:       row,
+			rank:      float64(matched) / float64(len(words)),
+			highlight: highlight(row.Name, search.Terms),
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank > matches[j].rank
+		}
+		return matches[i].row.ID < matches[j].row.ID
+	})
+
+	if search.Offset >= len(matches) {
+		return matches[:0]
+	}
+	matches = matches[search.Offset:]
+	if len(matches) > search.Limit {
+		matches = matches[:search.Limit]
+	}
+	return matches
+}
+
+func hasPrefixed(words []string, term string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func prefixedBy(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight wraps the words of name matched by terms in <b></b> like
+// ts_headline does.
+func highlight(name string, terms []string) string {
+	var b strings.Builder
+	word := make([]rune, 0)
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		if prefixedBy(strings.ToLower(string(word)), terms) {
+			b.WriteString("<b>" + string(word) + "</b>")
+		} else {
+			b.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+func (r *Managers) SearchProducts(ctx context.Context, search *listing.Search) ([]*managers.ProductMatch, error) {
+	defer r.lock()()
+
+	matches := r.db.searchProducts(search)
+	items := make([]*managers.ProductMatch, 0, len(matches))
+	for _, match := range matches {
+		items = append(items, &managers.ProductMatch{
+			Product:   match.row.product(),
+			Rank:      match.rank,
+			Highlight: match.highlight,
+		})
+	}
+	return items, nil
+}
+
+func (r *Customers) SearchProducts(ctx context.Context, search *listing.Search) ([]*customers.ProductMatch, error) {
+	defer r.lock()()
+
+	matches := r.db.searchProducts(search)
+	items := make([]*customers.ProductMatch, 0, len(matches))
+	for _, match := range matches {
+		items = append(items, &customers.ProductMatch{
+			Product:   match.row.customerProduct(),
+			Rank:      match.rank,
+			Highlight: match.highlight,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateStockMovement(ctx context.Context, movement *managers.StockMovement) error {
+	defer r.lock()()
+
+	row := stockMovementRow{
+		ID:        r.db.next("stock_movements"),
+		ProductID: movement.Product_id,
+		ManagerID: movement.Manager_id,
+		Kind:      movement.Kind,
+		Qty:       movement.Qty,
+		Balance:   movement.Balance,
+		Reason:    movement.Reason,
+		SaleID:    movement.Sale_id,
+		ReturnID:  movement.Return_id,
+		Created:   time.Now(),
+	}
+	r.db.stockMovements[row.ID] = row
+	movement.ID, movement.Created = row.ID, row.Created
+	return nil
+}
+
+func (r *Managers) StockMovements(ctx context.Context, productID int64) ([]*managers.StockMovement, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.products[productID]; !ok {
+		return nil, managers.ErrProductNotFound
+	}
+	ids := make([]int64, 0)
+	for id, row := range r.db.stockMovements {
+		if row.ProductID == productID {
+			ids = append(ids, id)
+		}
+	}
+	items := make([]*managers.StockMovement, 0, len(ids))
+	for _, id := range sortedIDs(ids) {
+		row := r.db.stockMovements[id]
+		items = append(items, &managers.StockMovement{
+			ID:         row.ID,
+			Product_id: row.ProductID,
+			Manager_id: row.ManagerID,
+			Kind:       row.Kind,
+			Qty:        row.Qty,
+			Balance:    row.Balance,
+			Reason:     row.Reason,
+			Sale_id:    row.SaleID,
+			Return_id:  row.ReturnID,
+			Created:    row.Created,
+		})
+	}
+	return items, nil
+}
+
+func (r *Managers) StockDiscrepancies(ctx context.Context) ([]*managers.StockDiscrepancy, error) {
+	defer r.lock()()
+
+	ledger := make(map[int64]int)
+	for _, row := range r.db.stockMovements {
+		ledger[row.ProductID] += row.Qty
+	}
+	ids := make([]int64, 0, len(r.db.products))
+	for id := range r.db.products {
+		ids = append(ids, id)
+	}
+	items := make([]*managers.StockDiscrepancy, 0)
+	for _, id := range sortedIDs(ids) {
+		row := r.db.products[id]
+		if row.Qty != ledger[id] {
+			items = append(items, &managers.StockDiscrepancy{
+				Product_id: row.ID,
+				Name:       row.Name,
+				Qty:        row.Qty,
+				Ledger_qty: ledger[id],
+			})
+		}
+	}
+	return items, nil
+}
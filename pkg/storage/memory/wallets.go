@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) LockWallet(ctx context.Context, customerID int64) (int, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.customers[customerID]; !ok {
+		return 0, customers.ErrUserNotFound
+	}
+	return r.db.wallets[customerID], nil
+}
+
+func (r *Managers) CreateWalletTransaction(ctx context.Context, transaction *managers.WalletTransaction) error {
+	defer r.lock()()
+
+	row := walletTransactionRow{
+		ID:         r.db.next("wallet_transactions"),
+		CustomerID: transaction.Customer_id,
+		Kind:       transaction.Kind,
+		Amount:     transaction.Amount,
+		Balance:    transaction.Balance,
+		SaleID:     transaction.Sale_id,
+		PaymentID:  transaction.Payment_id,
+		ManagerID:  transaction.Manager_id,
+		Comment:    transaction.Comment,
+		Created:    time.Now(),
+	}
+	r.db.walletTransactions[row.ID] = row
+	transaction.ID, transaction.Created = row.ID, row.Created
+	for _, entry := range transaction.Entries {
+		entryRow := walletEntryRow{
+			ID:            r.db.next("wallet_entries"),
+			TransactionID: row.ID,
+			Account:       entry.Account,
+			Amount:        entry.Amount,
+		}
+		r.db.walletEntries[entryRow.ID] = entryRow
+		entry.ID, entry.Transaction_id = entryRow.ID, row.ID
+	}
+	r.db.wallets[row.CustomerID] = row.Balance
+	return nil
+}
+
+func (r *Managers) WalletTransactions(ctx context.Context, customerID int64) ([]*managers.WalletTransaction, error) {
+	defer r.lock()()
+
+	if _, ok := r.db.customers[customerID]; !ok {
+		return nil, customers.ErrUserNotFound
+	}
+
+	ids := make([]int64, 0)
+	for id, row := range r.db.walletTransactions {
+		if row.CustomerID == customerID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	items := make([]*managers.WalletTransaction, 0, len(ids))
+	byID := make(map[int64]*managers.WalletTransaction, len(ids))
+	for _, id := range ids {
+		row := r.db.walletTransactions[id]
+		item := &managers.WalletTransaction{
+			ID:          row.ID,
+			Customer_id: row.CustomerID,
+			Kind:        row.Kind,
+			Amount:      row.Amount,
+			Balance:     row.Balance,
+			Sale_id:     row.SaleID,
+			Payment_id:  row.PaymentID,
+			Manager_id:  row.ManagerID,
+			Comment:     row.Comment,
+			Created:     row.Created,
+			Entries:     make([]*managers.WalletEntry, 0, 2),
+		}
+		items = append(items, item)
+		byID[id] = item
+	}
+
+	entryIDs := make([]int64, 0)
+	for id, row := range r.db.walletEntries {
+		if _, ok := byID[row.TransactionID]; ok {
+			entryIDs = append(entryIDs, id)
+		}
+	}
+	for _, id := range sortedIDs(entryIDs) {
+		row := r.db.walletEntries[id]
+		item := byID[row.TransactionID]
+		item.Entries = append(item.Entries, &managers.WalletEntry{
+			ID:             row.ID,
+			Transaction_id: row.TransactionID,
+			Account:        row.Account,
+			Amount:         row.Amount,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateAuditEntry(ctx context.Context, entry *managers.AuditEntry) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO audit_log (manager_id, action, entity, entity_id, before, after, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created
+	`, entry.Manager_id, entry.Action, entry.Entity, entry.Entity_id, []byte(entry.Before), []byte(entry.After),
+		entry.Request_id).Scan(&entry.ID, &entry.Created)
+}
+
+func (r *Managers) AuditEntries(ctx context.Context, filter *managers.AuditFilter) ([]*managers.AuditEntry, error) {
+	c := &conditions{}
+	if filter.ManagerID != 0 {
+		c.add("manager_id = ?", filter.ManagerID)
+	}
+	if filter.Entity != "" {
+		c.add("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		c.add("entity_id = ?", filter.EntityID)
+	}
+	if filter.From != nil {
+		c.add("created >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		c.add("created <= ?", *filter.To)
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT id, manager_id, action, entity, entity_id, before, after, request_id, created
+		FROM audit_log`+c.where()+fmt.Sprintf(" ORDER BY id DESC LIMIT %d OFFSET %d", filter.Limit, filter.Offset),
+		c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.AuditEntry, 0)
+	for rows.Next() {
+		item := &managers.AuditEntry{}
+		var before, after []byte
+		err = rows.Scan(&item.ID, &item.Manager_id, &item.Action, &item.Entity, &item.Entity_id,
+			&before, &after, &item.Request_id, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		item.Before, item.After = before, after
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
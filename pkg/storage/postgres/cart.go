@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CartItems(ctx context.Context, customerID int64) ([]*managers.CartItem, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT ci.product_id, p.name, p.price, ci.qty, p.qty, p.active
+		FROM cart_items ci
+		INNER JOIN products p ON p.id = ci.product_id
+		WHERE ci.customer_id = $1
+		ORDER BY ci.product_id
+	`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.CartItem, 0)
+	for rows.Next() {
+		item := &managers.CartItem{}
+		err = rows.Scan(&item.Product_id, &item.Name, &item.Price, &item.Qty, &item.Available, &item.Active)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) SetCartItem(ctx context.Context, customerID int64, productID int64, qty int) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO cart_items (customer_id, product_id, qty) VALUES ($1, $2, $3)
+		ON CONFLICT (customer_id, product_id) DO UPDATE SET qty = excluded.qty
+	`, customerID, productID, qty)
+	return err
+}
+
+func (r *Managers) DeleteCartItem(ctx context.Context, customerID int64, productID int64) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM cart_items WHERE customer_id = $1 AND product_id = $2
+	`, customerID, productID)
+	return err
+}
+
+func (r *Managers) ClearCart(ctx context.Context, customerID int64) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM cart_items WHERE customer_id = $1`, customerID)
+	return err
+}
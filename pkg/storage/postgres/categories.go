@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateCategory(ctx context.Context, category *managers.Category) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO categories (parent_id, name) VALUES ($1, $2) RETURNING id, created
+	`, category.Parent_id, category.Name).Scan(&category.ID, &category.Created)
+}
+
+func (r *Managers) UpdateCategory(ctx context.Context, category *managers.Category) error {
+	err := r.db.QueryRow(ctx, `
+		UPDATE categories SET parent_id = $1, name = $2 WHERE id = $3 RETURNING created
+	`, category.Parent_id, category.Name, category.ID).Scan(&category.Created)
+	if err == pgx.ErrNoRows {
+		return managers.ErrCategoryNotFound
+	}
+	return err
+}
+
+func (r *Managers) Categories(ctx context.Context) ([]*managers.Category, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, parent_id, name, created FROM categories ORDER BY name, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return scanCategories(rows)
+}
+
+func (r *Managers) DeleteCategory(ctx context.Context, id int64) (*managers.Category, error) {
+	category := &managers.Category{}
+	err := r.db.QueryRow(ctx, `
+		DELETE FROM categories WHERE id = $1 RETURNING id, parent_id, name, created
+	`, id).Scan(&category.ID, &category.Parent_id, &category.Name, &category.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (r *Managers) SetProductCategories(ctx context.Context, productID int64, categoryIDs []int64) error {
+	err := r.db.QueryRow(ctx, `SELECT id FROM products WHERE id = $1 FOR UPDATE`, productID).Scan(&productID)
+	if err == pgx.ErrNoRows {
+		return managers.ErrProductNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `DELETE FROM products_categories WHERE product_id = $1`, productID)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO products_categories (product_id, category_id)
+		SELECT $1, unnest($2::BIGINT[])
+	`, productID, categoryIDs)
+	return err
+}
+
+func (r *Managers) ProductCategories(ctx context.Context, productID int64) ([]*managers.Category, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, managers.ErrProductNotFound
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.parent_id, c.name, c.created
+		FROM categories c
+		INNER JOIN products_categories pc ON pc.category_id = c.id
+		WHERE pc.product_id = $1
+		ORDER BY c.name, c.id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCategories(rows)
+}
+
+func scanCategories(rows pgx.Rows) ([]*managers.Category, error) {
+	defer rows.Close()
+
+	items := make([]*managers.Category, 0)
+	for rows.Next() {
+		item := &managers.Category{}
+		err := rows.Scan(&item.ID, &item.Parent_id, &item.Name, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Customers) Categories(ctx context.Context) ([]*customers.Category, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, parent_id, name FROM categories ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*customers.Category, 0)
+	for rows.Next() {
+		item := &customers.Category{}
+		err = rows.Scan(&item.ID, &item.Parent, &item.Name)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+)
+
+// Customers implements customers.Repository on top of Postgres.
+type Customers struct {
+	db querier
+}
+
+func NewCustomers(pool *pgxpool.Pool) *Customers {
+	return &Customers{db: pool}
+}
+
+func (r *Customers) CreateCustomer(ctx context.Context, reg *customers.Registration) (*customers.Customer, error) {
+	item := &customers.Customer{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO customers (name, phone, password)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (phone) DO NOTHING
+		RETURNING id, name, phone, active, created
+	`, reg.Name, reg.Phone, reg.Password).Scan(&item.ID, &item.Name, &item.Phone, &item.Active, &item.Created)
+	if err == pgx.ErrNoRows {
+		return nil, customers.ErrPhoneUsed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *Customers) CustomerPasswordByPhone(ctx context.Context, phone string) (id int64, hash string, err error) {
+	err = r.db.QueryRow(ctx, `SELECT id, password FROM customers WHERE phone = $1 AND active`, phone).Scan(&id, &hash)
+	if err == pgx.ErrNoRows {
+		return 0, "", customers.ErrUserNotFound
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return id, hash, nil
+}
+
+func (r *Customers) SaveCustomerToken(ctx context.Context, token string, customerID int64, expire time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO customers_tokens(token, customer_id, expire) VALUES($1, $2, $3)
+	`, token, customerID, expire.UTC())
+	return err
+}
+
+func (r *Customers) CustomerToken(ctx context.Context, token string) (customerID int64, expire time.Time, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT t.customer_id, t.expire
+		FROM customers_tokens t
+		INNER JOIN customers c ON c.id = t.customer_id
+		WHERE t.token = $1 AND c.active
+	`, token).Scan(&customerID, &expire)
+	if err == pgx.ErrNoRows {
+		return 0, time.Time{}, customers.ErrTokenNotFound
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return customerID, expire, nil
+}
+
+func (r *Customers) Products(ctx context.Context, filter *listing.ProductFilter) ([]*customers.Product, error) {
+	items := make([]*customers.Product, 0)
+	c := productConditions(filter)
+	order := c.page(productColumns[filter.Sort], filter.Params)
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, price, qty, active, created FROM products`+c.where()+order, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &customers.Product{}
+		err = rows.Scan(&item.ID, &item.Name, &item.Price, &item.Qty, &item.Active, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Customers) Purchases(ctx context.Context, customerID int64) ([]*customers.Purchase, error) {
+	items := make([]*customers.Purchase, 0)
+	rows, err := r.db.Query(ctx, `
+		SELECT s.id, s.status, s.created as Date, sp.product_id as ID, sp.name as Name, sp.price as Price, sp.qty as Qty
+		FROM sales s
+		INNER JOIN sales_positions sp ON sp.sale_id = s.id and s.customer_id = $1
+		GROUP BY s.id, sp.product_id, sp.name, sp.price, sp.qty
+		ORDER BY s.created, s.id
+	`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		purchase := &customers.Purchase{}
+		product := &customers.Product{}
+		err = rows.Scan(&purchase.Sale_id, &purchase.Status, &purchase.Date, &product.ID, &product.Name, &product.Price, &product.Qty)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, p := range items {
+			if p.Sale_id == purchase.Sale_id {
+				p.Products = append(p.Products, product)
+				found = true
+				break
+			}
+		}
+		if !found {
+			purchase.Products = append(purchase.Products, product)
+			items = append(items, purchase)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/khiki1995/crud/pkg/listing"
+)
+
+// conditions collects WHERE clauses written with ? placeholders and numbers
+// them as $1, $2, ... in the order they are added.
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+func (c *conditions) add(clause string, args ...interface{}) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		clause = strings.Replace(clause, "?", "$"+strconv.Itoa(len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// page adds the keyset condition for params.After and returns the ORDER BY
+// and LIMIT clauses; column must come from a whitelist, never from input.
+func (c *conditions) page(column string, params listing.Params) string {
+	direction, compare := "ASC", ">"
+	if params.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if params.After != nil {
+		c.add(fmt.Sprintf("(%s, id) %s (?, ?)", column, compare), params.After.Key, params.After.ID)
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", column, direction, direction, params.Limit+1)
+}
+
+var productColumns = map[string]string{"id": "id", "name": "name", "price": "price", "created": "created"}
+var customerColumns = map[string]string{"id": "id", "name": "name", "phone": "phone", "created": "created"}
+
+// productConditions translates filter into conditions on the products table.
+func productConditions(filter *listing.ProductFilter) *conditions {
+	c := &conditions{}
+	if filter.Active != nil {
+		c.add("active = ?", *filter.Active)
+	}
+	if filter.MinPrice != nil {
+		c.add("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		c.add("price <= ?", *filter.MaxPrice)
+	}
+	if filter.Name != "" {
+		c.add("name ILIKE ?", "%"+escapeLike(filter.Name)+"%")
+	}
+	if filter.CreatedFrom != nil {
+		c.add("created >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		c.add("created <= ?", *filter.CreatedTo)
+	}
+	if filter.CategoryID != nil {
+		c.add(`id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE id = ?
+				UNION ALL
+				SELECT c.id FROM categories c INNER JOIN tree t ON c.parent_id = t.id
+			)
+			SELECT pc.product_id FROM products_categories pc INNER JOIN tree t ON t.id = pc.category_id
+		)`, *filter.CategoryID)
+	}
+	return c
+}
+
+func customerConditions(filter *listing.CustomerFilter) *conditions {
+	c := &conditions{}
+	if filter.Active != nil {
+		c.add("active = ?", *filter.Active)
+	}
+	if filter.Name != "" {
+		c.add("name ILIKE ?", "%"+escapeLike(filter.Name)+"%")
+	}
+	if filter.PhonePrefix != "" {
+		c.add("phone LIKE ?", escapeLike(filter.PhonePrefix)+"%")
+	}
+	if filter.CreatedFrom != nil {
+		c.add("created >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		c.add("created <= ?", *filter.CreatedTo)
+	}
+	return c
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
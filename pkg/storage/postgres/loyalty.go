@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+const loyaltyRuleColumns = `id, kind, rate_bp, multiplier, category_id, tier, min_spend, created`
+
+const pointsTransactionColumns = `id, customer_id, kind, points, balance, remaining, sale_id, return_id, created`
+
+func scanLoyaltyRule(row pgx.Row) (*managers.LoyaltyRule, error) {
+	item := &managers.LoyaltyRule{}
+	err := row.Scan(&item.ID, &item.Kind, &item.Rate_bp, &item.Multiplier, &item.Category_id, &item.Tier,
+		&item.Min_spend, &item.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrLoyaltyRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *Managers) CreateLoyaltyRule(ctx context.Context, rule *managers.LoyaltyRule) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO loyalty_rules (kind, rate_bp, multiplier, category_id, tier, min_spend)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created
+	`, rule.Kind, rule.Rate_bp, rule.Multiplier, rule.Category_id, rule.Tier, rule.Min_spend).Scan(&rule.ID, &rule.Created)
+}
+
+func (r *Managers) LoyaltyRules(ctx context.Context) ([]*managers.LoyaltyRule, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+loyaltyRuleColumns+` FROM loyalty_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.LoyaltyRule, 0)
+	for rows.Next() {
+		item, err := scanLoyaltyRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) DeleteLoyaltyRule(ctx context.Context, id int64) (*managers.LoyaltyRule, error) {
+	return scanLoyaltyRule(r.db.QueryRow(ctx, `
+		DELETE FROM loyalty_rules WHERE id = $1 RETURNING `+loyaltyRuleColumns, id))
+}
+
+func (r *Managers) LockPoints(ctx context.Context, customerID int64) (int, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)`, customerID).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, customers.ErrUserNotFound
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO loyalty_accounts (customer_id) VALUES ($1) ON CONFLICT (customer_id) DO NOTHING
+	`, customerID)
+	if err != nil {
+		return 0, err
+	}
+	var balance int
+	err = r.db.QueryRow(ctx, `
+		SELECT balance FROM loyalty_accounts WHERE customer_id = $1 FOR UPDATE
+	`, customerID).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+func (r *Managers) CreatePointsTransaction(ctx context.Context, transaction *managers.PointsTransaction) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO points_transactions (customer_id, kind, points, balance, remaining, sale_id, return_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created
+	`, transaction.Customer_id, transaction.Kind, transaction.Points, transaction.Balance, transaction.Remaining,
+		transaction.Sale_id, transaction.Return_id).Scan(&transaction.ID, &transaction.Created)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(ctx, `
+		UPDATE loyalty_accounts SET balance = $2 WHERE customer_id = $1
+	`, transaction.Customer_id, transaction.Balance)
+	return err
+}
+
+func (r *Managers) PointLots(ctx context.Context, customerID int64) ([]*managers.PointsTransaction, error) {
+	return r.pointsTransactions(ctx, `
+		SELECT `+pointsTransactionColumns+` FROM points_transactions
+		WHERE customer_id = $1 AND kind IN ('earn', 'refund') AND remaining > 0
+		ORDER BY id
+	`, customerID)
+}
+
+func (r *Managers) SetLotRemaining(ctx context.Context, id int64, remaining int) error {
+	_, err := r.db.Exec(ctx, `UPDATE points_transactions SET remaining = $2 WHERE id = $1`, id, remaining)
+	return err
+}
+
+func (r *Managers) PointsTransactions(ctx context.Context, customerID int64) ([]*managers.PointsTransaction, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)`, customerID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, customers.ErrUserNotFound
+	}
+
+	return r.pointsTransactions(ctx, `
+		SELECT `+pointsTransactionColumns+` FROM points_transactions
+		WHERE customer_id = $1
+		ORDER BY id DESC
+	`, customerID)
+}
+
+func (r *Managers) SalePoints(ctx context.Context, saleID int64) (int, error) {
+	var points int
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(points), 0) FROM points_transactions WHERE sale_id = $1 AND kind = 'earn'
+	`, saleID).Scan(&points)
+	return points, err
+}
+
+func (r *Managers) CustomerSpend(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	var spend int
+	err := r.db.QueryRow(ctx, `
+		SELECT
+			COALESCE((SELECT SUM(sp.price * sp.qty) FROM sales s
+				INNER JOIN sales_positions sp ON sp.sale_id = s.id
+				WHERE s.customer_id = $1 AND s.created >= $2 AND `+countedSales+`), 0) -
+			COALESCE((SELECT SUM(rt.refund) FROM sales s
+				INNER JOIN returns rt ON rt.sale_id = s.id
+				WHERE s.customer_id = $1 AND s.created >= $2 AND `+countedSales+`), 0)
+	`, customerID, since).Scan(&spend)
+	return spend, err
+}
+
+func (r *Managers) pointsTransactions(ctx context.Context, query string, args ...interface{}) ([]*managers.PointsTransaction, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.PointsTransaction, 0)
+	for rows.Next() {
+		item := &managers.PointsTransaction{}
+		err = rows.Scan(&item.ID, &item.Customer_id, &item.Kind, &item.Points, &item.Balance, &item.Remaining,
+			&item.Sale_id, &item.Return_id, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,347 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// Managers implements managers.Repository on top of Postgres.
+type Managers struct {
+	db querier
+}
+
+func NewManagers(pool *pgxpool.Pool) *Managers {
+	return &Managers{db: pool}
+}
+
+func (r *Managers) WithTx(ctx context.Context, fn func(repo managers.Repository) error) error {
+	return inTx(ctx, r.db, func(tx pgx.Tx) error {
+		return fn(&Managers{db: tx})
+	})
+}
+
+func (r *Managers) CreateManager(ctx context.Context, reg *managers.Registration) (*managers.Manager, error) {
+	item := &managers.Manager{}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO managers (name, phone, roles, boss_id, department, salary, plan)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+		ON CONFLICT (phone) DO NOTHING
+		RETURNING id, name, phone, roles, COALESCE(boss_id, 0), COALESCE(department, ''), salary, plan, active, created
+	`, reg.Name, reg.Phone, reg.Roles, reg.Boss_id, reg.Department, reg.Salary, reg.Plan).Scan(&item.ID, &item.Name,
+		&item.Phone, &item.Roles, &item.Boss_id, &item.Departament, &item.Salary, &item.Plan, &item.Active, &item.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrPhoneUsed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *Managers) ManagerPasswordByPhone(ctx context.Context, phone string) (id int64, hash string, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT id, COALESCE(password, '') FROM managers WHERE phone = $1
+	`, phone).Scan(&id, &hash)
+	if err == pgx.ErrNoRows {
+		return 0, "", managers.ErrUserNotFound
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return id, hash, nil
+}
+
+func (r *Managers) Manager(ctx context.Context, id int64) (*managers.Manager, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+managerColumns+` FROM managers WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanManagers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, managers.ErrUserNotFound
+	}
+	return items[0], nil
+}
+
+func (r *Managers) AllManagers(ctx context.Context) ([]*managers.Manager, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+managerColumns+` FROM managers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanManagers(rows)
+}
+
+func (r *Managers) ManagerRoles(ctx context.Context, id int64) ([]string, error) {
+	var roles []string
+	err := r.db.QueryRow(ctx, `SELECT roles FROM managers WHERE id = $1`, id).Scan(&roles)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *Managers) SetManagerRoles(ctx context.Context, id int64, roles []string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE managers SET roles = $2 WHERE id = $1`, id, roles)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return managers.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *Managers) SaveManagerToken(ctx context.Context, token string, managerID int64, expire time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO managers_tokens(token, manager_id, expire) VALUES($1, $2, $3)
+	`, token, managerID, expire.UTC())
+	return err
+}
+
+func (r *Managers) ManagerToken(ctx context.Context, token string) (managerID int64, expire time.Time, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT manager_id, expire FROM managers_tokens WHERE token = $1
+	`, token).Scan(&managerID, &expire)
+	if err == pgx.ErrNoRows {
+		return 0, time.Time{}, managers.ErrTokenNotFound
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return managerID, expire, nil
+}
+
+func (r *Managers) CreateProduct(ctx context.Context, product *managers.Product) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO products (name, price, qty) VALUES ($1, $2, $3)
+		RETURNING id, active, created
+	`, product.Name, product.Price, product.Qty).Scan(&product.ID, &product.Active, &product.Created)
+}
+
+func (r *Managers) UpdateProduct(ctx context.Context, product *managers.Product) error {
+	err := r.db.QueryRow(ctx, `
+		UPDATE products SET name = $1, price = $2
+		WHERE id = $3 RETURNING qty, active, created
+	`, product.Name, product.Price, product.ID).Scan(&product.Qty, &product.Active, &product.Created)
+	if err == pgx.ErrNoRows {
+		return managers.ErrProductNotFound
+	}
+	return err
+}
+
+func (r *Managers) Products(ctx context.Context, filter *listing.ProductFilter) ([]*managers.Product, error) {
+	c := productConditions(filter)
+	order := c.page(productColumns[filter.Sort], filter.Params)
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, price, qty, active, created FROM products`+c.where()+order, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanProducts(rows)
+}
+
+func (r *Managers) LockProducts(ctx context.Context, ids []int64) ([]*managers.Product, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, price, qty, active, created FROM products
+		WHERE id = ANY($1) ORDER BY id FOR UPDATE
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	return scanProducts(rows)
+}
+
+func (r *Managers) AddProductQty(ctx context.Context, id int64, delta int) (qty int, err error) {
+	err = r.db.QueryRow(ctx, `
+		UPDATE products SET qty = qty + $1 WHERE id = $2 RETURNING qty
+	`, delta, id).Scan(&qty)
+	if err == pgx.ErrNoRows {
+		return 0, managers.ErrProductNotFound
+	}
+	return qty, err
+}
+
+func (r *Managers) SetProductActive(ctx context.Context, id int64, active bool) (*managers.Product, error) {
+	product := &managers.Product{}
+	err := r.db.QueryRow(ctx, `
+		UPDATE products SET active = $2 WHERE id = $1 RETURNING id, name, price, qty, active, created
+	`, id, active).Scan(&product.ID, &product.Name, &product.Price, &product.Qty, &product.Active, &product.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+func (r *Managers) UpdateCustomer(ctx context.Context, item *customers.Customer) (*customers.Customer, error) {
+	customer := &customers.Customer{}
+	err := r.db.QueryRow(ctx, `
+		UPDATE customers SET name = $1, phone = $2 WHERE id = $3
+		RETURNING id, name, phone, active, created
+	`, item.Name, item.Phone, item.ID).Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Active, &customer.Created)
+	if err == pgx.ErrNoRows {
+		return nil, customers.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+func (r *Managers) Customers(ctx context.Context, filter *listing.CustomerFilter) ([]*customers.Customer, error) {
+	items := make([]*customers.Customer, 0)
+	c := customerConditions(filter)
+	order := c.page(customerColumns[filter.Sort], filter.Params)
+	rows, err := r.db.Query(ctx, `
+		SELECT id, name, phone, active, created FROM customers`+c.where()+order, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		customer := &customers.Customer{}
+		err = rows.Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Active, &customer.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, customer)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) Customer(ctx context.Context, id int64) (*customers.Customer, error) {
+	customer := &customers.Customer{}
+	err := r.db.QueryRow(ctx, `
+		SELECT id, name, phone, active, created FROM customers WHERE id = $1
+	`, id).Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Active, &customer.Created)
+	if err == pgx.ErrNoRows {
+		return nil, customers.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+func (r *Managers) SetCustomerActive(ctx context.Context, id int64, active bool) (*customers.Customer, error) {
+	customer := &customers.Customer{}
+	err := r.db.QueryRow(ctx, `
+		UPDATE customers SET active = $2 WHERE id = $1 RETURNING id, name, phone, active, created
+	`, id, active).Scan(&customer.ID, &customer.Name, &customer.Phone, &customer.Active, &customer.Created)
+	if err == pgx.ErrNoRows {
+		return nil, customers.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+func (r *Managers) CreateSale(ctx context.Context, sale *managers.Sale) error {
+	var managerID *int64
+	if sale.Manager_id != 0 {
+		managerID = &sale.Manager_id
+	}
+	err := r.db.QueryRow(ctx, `
+		WITH receipt AS (
+			UPDATE counters SET value = value + 1 WHERE name = 'receipt' RETURNING value
+		)
+		INSERT INTO sales (manager_id, customer_id, status, receipt_no) SELECT $1, $2, $3, value FROM receipt
+		RETURNING id, receipt_no, created
+	`, managerID, sale.Customer_id, sale.Status).Scan(&sale.ID, &sale.Receipt_no, &sale.Created)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, v := range sale.Positions {
+		batch.Queue(`
+			INSERT INTO sales_positions (sale_id, product_id, name, qty, price, base_price, discount_percent, discount_amount,
+				promo_discount, points_discount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`, sale.ID, v.Product_id, v.Name, v.Qty, v.Price, v.Base_price, v.Discount_percent, v.Discount_amount,
+			v.Promo_discount, v.Points_discount)
+	}
+	results := r.db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, v := range sale.Positions {
+		err = results.QueryRow().Scan(&v.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return results.Close()
+}
+
+func (r *Managers) SalesTotal(ctx context.Context, managerID int64) (total int, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(sp.price * sp.qty), 0)
+		FROM sales s
+		INNER JOIN sales_positions sp ON sp.sale_id = s.id
+		WHERE s.manager_id = $1 AND `+countedSales+`
+	`, managerID).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+const managerColumns = `id, name, salary, plan, COALESCE(boss_id, 0), COALESCE(department, ''), phone, roles, active, created`
+
+func scanManagers(rows pgx.Rows) ([]*managers.Manager, error) {
+	defer rows.Close()
+
+	items := make([]*managers.Manager, 0)
+	for rows.Next() {
+		item := &managers.Manager{}
+		err := rows.Scan(&item.ID, &item.Name, &item.Salary, &item.Plan, &item.Boss_id, &item.Departament,
+			&item.Phone, &item.Roles, &item.Active, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func scanProducts(rows pgx.Rows) ([]*managers.Product, error) {
+	defer rows.Close()
+
+	products := make([]*managers.Product, 0)
+	for rows.Next() {
+		product := &managers.Product{}
+		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Qty, &product.Active, &product.Created)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) SalesByStatus(ctx context.Context, status string) ([]*managers.Sale, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT s.id, s.receipt_no, COALESCE(s.manager_id, 0), s.customer_id, s.status, s.created,
+			sp.id, sp.product_id, sp.name, sp.qty, sp.price, sp.base_price, sp.discount_percent, sp.discount_amount,
+			sp.promo_discount, sp.points_discount
+		FROM sales s
+		INNER JOIN sales_positions sp ON sp.sale_id = s.id
+		WHERE s.status = $1
+		ORDER BY s.id, sp.id
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Sale, 0)
+	for rows.Next() {
+		sale := &managers.Sale{}
+		position := &managers.SalePosition{}
+		err = rows.Scan(&sale.ID, &sale.Receipt_no, &sale.Manager_id, &sale.Customer_id, &sale.Status, &sale.Created,
+			&position.ID, &position.Product_id, &position.Name, &position.Qty, &position.Price,
+			&position.Base_price, &position.Discount_percent, &position.Discount_amount, &position.Promo_discount,
+			&position.Points_discount)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 || items[len(items)-1].ID != sale.ID {
+			items = append(items, sale)
+		}
+		last := items[len(items)-1]
+		last.Positions = append(last.Positions, position)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) SetSaleStatus(ctx context.Context, id int64, status string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE sales SET status = $2 WHERE id = $1`, id, status)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return managers.ErrSaleNotFound
+	}
+	return nil
+}
+
+func (r *Managers) CreateStatusChange(ctx context.Context, change *managers.StatusChange) error {
+	var managerID *int64
+	if change.Manager_id != 0 {
+		managerID = &change.Manager_id
+	}
+	return r.db.QueryRow(ctx, `
+		INSERT INTO sales_status_history (sale_id, status, manager_id) VALUES ($1, $2, $3)
+		RETURNING id, created
+	`, change.Sale_id, change.Status, managerID).Scan(&change.ID, &change.Created)
+}
+
+func (r *Managers) StatusHistory(ctx context.Context, saleID int64) ([]*managers.StatusChange, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM sales WHERE id = $1)`, saleID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, managers.ErrSaleNotFound
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, sale_id, status, COALESCE(manager_id, 0), created
+		FROM sales_status_history WHERE sale_id = $1 ORDER BY id
+	`, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.StatusChange, 0)
+	for rows.Next() {
+		item := &managers.StatusChange{}
+		err = rows.Scan(&item.ID, &item.Sale_id, &item.Status, &item.Manager_id, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+const paymentColumns = `id, sale_id, method, provider, reference, amount, refunded, status, manager_id, created`
+
+func scanPayment(row pgx.Row) (*managers.Payment, error) {
+	item := &managers.Payment{}
+	err := row.Scan(&item.ID, &item.Sale_id, &item.Method, &item.Provider, &item.Reference, &item.Amount,
+		&item.Refunded, &item.Status, &item.Manager_id, &item.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *Managers) CreatePayment(ctx context.Context, payment *managers.Payment) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO payments (sale_id, method, provider, reference, amount, refunded, status, manager_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created
+	`, payment.Sale_id, payment.Method, payment.Provider, payment.Reference, payment.Amount, payment.Refunded,
+		payment.Status, payment.Manager_id).Scan(&payment.ID, &payment.Created)
+}
+
+func (r *Managers) UpdatePayment(ctx context.Context, payment *managers.Payment) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE payments SET provider = $2, reference = $3, refunded = $4, status = $5 WHERE id = $1
+	`, payment.ID, payment.Provider, payment.Reference, payment.Refunded, payment.Status)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return managers.ErrPaymentNotFound
+	}
+	return nil
+}
+
+func (r *Managers) LockPayment(ctx context.Context, id int64) (*managers.Payment, error) {
+	return scanPayment(r.db.QueryRow(ctx, `
+		SELECT `+paymentColumns+` FROM payments WHERE id = $1 FOR UPDATE
+	`, id))
+}
+
+func (r *Managers) PaymentByReference(ctx context.Context, provider string, reference string) (*managers.Payment, error) {
+	return scanPayment(r.db.QueryRow(ctx, `
+		SELECT `+paymentColumns+` FROM payments
+		WHERE method = 'card' AND provider = $1 AND reference = $2
+		FOR UPDATE
+	`, provider, reference))
+}
+
+func (r *Managers) SalePayments(ctx context.Context, saleID int64) ([]*managers.Payment, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM sales WHERE id = $1)`, saleID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, managers.ErrSaleNotFound
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+paymentColumns+` FROM payments WHERE sale_id = $1 ORDER BY id
+	`, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Payment, 0)
+	for rows.Next() {
+		item, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) Reconciliation(ctx context.Context, period managers.Period) ([]*managers.Reconciliation, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT s.id, s.receipt_no, s.status, s.created,
+			CASE WHEN s.status = 'cancelled' THEN 0
+				ELSE COALESCE((SELECT SUM(sp.price * sp.qty) FROM sales_positions sp WHERE sp.sale_id = s.id), 0) END,
+			COALESCE((SELECT SUM(rt.refund) FROM returns rt WHERE rt.sale_id = s.id), 0),
+			COALESCE((SELECT SUM(p.amount - p.refunded) FROM payments p
+				WHERE p.sale_id = s.id AND p.status NOT IN ('voided', 'failed')), 0)
+		FROM sales s
+		WHERE (`+countedSales+` OR EXISTS (SELECT 1 FROM payments p WHERE p.sale_id = s.id))
+			AND ($1::TIMESTAMP IS NULL OR s.created >= $1) AND ($2::TIMESTAMP IS NULL OR s.created <= $2)
+		ORDER BY s.id
+	`, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Reconciliation, 0)
+	for rows.Next() {
+		item := &managers.Reconciliation{}
+		err = rows.Scan(&item.Sale_id, &item.Receipt_no, &item.Status, &item.Created,
+			&item.Total, &item.Returned, &item.Paid)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
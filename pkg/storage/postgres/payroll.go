@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) ManagerSales(ctx context.Context, managerID int64, period managers.Period) ([]*managers.Sale, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT s.id, s.manager_id, s.customer_id, s.created,
+			sp.id, sp.product_id, sp.name, sp.qty, sp.price, sp.base_price, sp.discount_percent, sp.discount_amount,
+			sp.promo_discount, sp.points_discount
+		FROM sales s
+		INNER JOIN sales_positions sp ON sp.sale_id = s.id
+		WHERE s.manager_id = $1 AND `+countedSales+`
+			AND ($2::TIMESTAMP IS NULL OR s.created >= $2) AND ($3::TIMESTAMP IS NULL OR s.created <= $3)
+		ORDER BY s.id, sp.id
+	`, managerID, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Sale, 0)
+	for rows.Next() {
+		sale := &managers.Sale{}
+		position := &managers.SalePosition{}
+		err = rows.Scan(&sale.ID, &sale.Manager_id, &sale.Customer_id, &sale.Created,
+			&position.ID, &position.Product_id, &position.Name, &position.Qty, &position.Price,
+			&position.Base_price, &position.Discount_percent, &position.Discount_amount, &position.Promo_discount,
+			&position.Points_discount)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 || items[len(items)-1].ID != sale.ID {
+			items = append(items, sale)
+		}
+		last := items[len(items)-1]
+		last.Positions = append(last.Positions, position)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) CreateCommissionRule(ctx context.Context, rule *managers.CommissionRule) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO commission_rules (kind, rate_bp, min_attainment, category_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created
+	`, rule.Kind, rule.Rate_bp, rule.Min_attainment, rule.Category_id).Scan(&rule.ID, &rule.Created)
+}
+
+func (r *Managers) CommissionRules(ctx context.Context) ([]*managers.CommissionRule, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, kind, rate_bp, min_attainment, category_id, created FROM commission_rules ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.CommissionRule, 0)
+	for rows.Next() {
+		item := &managers.CommissionRule{}
+		err = rows.Scan(&item.ID, &item.Kind, &item.Rate_bp, &item.Min_attainment, &item.Category_id, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) DeleteCommissionRule(ctx context.Context, id int64) (*managers.CommissionRule, error) {
+	item := &managers.CommissionRule{}
+	err := r.db.QueryRow(ctx, `
+		DELETE FROM commission_rules WHERE id = $1
+		RETURNING id, kind, rate_bp, min_attainment, category_id, created
+	`, id).Scan(&item.ID, &item.Kind, &item.Rate_bp, &item.Min_attainment, &item.Category_id, &item.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrCommissionRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *Managers) CreateStatement(ctx context.Context, statement *managers.Statement) error {
+	var finalized time.Time
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO payroll_statements (manager_id, name, month, salary, plan, actual, attainment, commission, total, finalized_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (manager_id, month) DO NOTHING
+		RETURNING id, finalized
+	`, statement.Manager_id, statement.Name, statement.Month, statement.Salary, statement.Plan, statement.Actual,
+		statement.Attainment, statement.Commission, statement.Total, statement.Finalized_by).Scan(&statement.ID, &finalized)
+	if err == pgx.ErrNoRows {
+		return managers.ErrStatementFinalized
+	}
+	if err != nil {
+		return err
+	}
+	statement.Finalized = &finalized
+
+	batch := &pgx.Batch{}
+	for _, v := range statement.Lines {
+		batch.Queue(`
+			INSERT INTO payroll_lines (statement_id, sale_id, position_id, product_id, name, qty, amount, rule_id, rate_bp, commission)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, statement.ID, v.Sale_id, v.Position_id, v.Product_id, v.Name, v.Qty, v.Amount, v.Rule_id, v.Rate_bp, v.Commission)
+	}
+	results := r.db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for range statement.Lines {
+		_, err = results.Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return results.Close()
+}
+
+func (r *Managers) Statement(ctx context.Context, managerID int64, month time.Time) (*managers.Statement, error) {
+	items, err := r.statements(ctx, `WHERE manager_id = $1 AND month = $2`, managerID, month)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, managers.ErrStatementNotFound
+	}
+	return items[0], nil
+}
+
+func (r *Managers) Statements(ctx context.Context, month time.Time) ([]*managers.Statement, error) {
+	return r.statements(ctx, `WHERE month = $1`, month)
+}
+
+func (r *Managers) statements(ctx context.Context, where string, args ...interface{}) ([]*managers.Statement, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, manager_id, name, month, salary, plan, actual, attainment, commission, total, finalized_by, finalized
+		FROM payroll_statements `+where+` ORDER BY manager_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Statement, 0)
+	byID := make(map[int64]*managers.Statement)
+	ids := make([]int64, 0)
+	for rows.Next() {
+		item := &managers.Statement{Status: managers.StatementFinal, Lines: make([]*managers.CommissionLine, 0)}
+		var finalized time.Time
+		err = rows.Scan(&item.ID, &item.Manager_id, &item.Name, &item.Month, &item.Salary, &item.Plan, &item.Actual,
+			&item.Attainment, &item.Commission, &item.Total, &item.Finalized_by, &finalized)
+		if err != nil {
+			return nil, err
+		}
+		item.Finalized = &finalized
+		items = append(items, item)
+		byID[item.ID] = item
+		ids = append(ids, item.ID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return items, nil
+	}
+
+	lines, err := r.db.Query(ctx, `
+		SELECT statement_id, sale_id, position_id, product_id, name, qty, amount, rule_id, rate_bp, commission
+		FROM payroll_lines WHERE statement_id = ANY($1) ORDER BY id
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var statementID int64
+		line := &managers.CommissionLine{}
+		err = lines.Scan(&statementID, &line.Sale_id, &line.Position_id, &line.Product_id, &line.Name, &line.Qty,
+			&line.Amount, &line.Rule_id, &line.Rate_bp, &line.Commission)
+		if err != nil {
+			return nil, err
+		}
+		byID[statementID].Lines = append(byID[statementID].Lines, line)
+	}
+	err = lines.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
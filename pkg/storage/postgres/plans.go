@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreatePlan(ctx context.Context, plan *managers.Plan) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO managers_plans (manager_id, period, start, amount, set_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created
+	`, plan.Manager_id, plan.Period, plan.Start, plan.Amount, plan.Set_by).Scan(&plan.ID, &plan.Created)
+}
+
+func (r *Managers) Plans(ctx context.Context, managerID int64) ([]*managers.Plan, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, manager_id, period, start, amount, set_by, created
+		FROM managers_plans WHERE manager_id = $1
+		ORDER BY id DESC
+	`, managerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Plan, 0)
+	for rows.Next() {
+		item := &managers.Plan{}
+		err = rows.Scan(&item.ID, &item.Manager_id, &item.Period, &item.Start, &item.Amount, &item.Set_by, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) PlanTargets(ctx context.Context, period string, start time.Time) (map[int64]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.id, COALESCE(p.amount, m.plan * CASE WHEN $1 = 'quarter' THEN 3 ELSE 1 END)
+		FROM managers m
+		LEFT JOIN (
+			SELECT DISTINCT ON (manager_id) manager_id, amount
+			FROM managers_plans
+			WHERE period = $1 AND start = $2
+			ORDER BY manager_id, id DESC
+		) p ON p.manager_id = m.id
+	`, period, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var amount int
+		err = rows.Scan(&id, &amount)
+		if err != nil {
+			return nil, err
+		}
+		targets[id] = amount
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
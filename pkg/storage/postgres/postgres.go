@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx, so repositories
+// work the same way inside and outside of a transaction.
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults
+}
+
+// countedSales limits queries over sales s to the ones totals and reports
+// count: drafts have taken no stock yet and cancelled orders gave it back.
+const countedSales = `s.status NOT IN ('draft', 'cancelled')`
+
+// inTx begins a transaction on db (a savepoint if db is already a
+// transaction), runs fn and commits if fn succeeds.
+func inTx(ctx context.Context, db querier, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
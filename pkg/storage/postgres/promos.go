@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// promoColumns selects a promo code p with its uses for sales that were not
+// cancelled.
+const promoColumns = `p.id, p.code, p.kind, p.value, p.min_basket, p.product_id, p.category_id, p.starts, p.ends,
+	p.max_uses, p.max_uses_per_customer, p.active, p.created,
+	(SELECT COUNT(*) FROM promo_redemptions pr INNER JOIN sales s ON s.id = pr.sale_id
+		WHERE pr.promo_id = p.id AND s.status <> 'cancelled'),
+	(SELECT COALESCE(SUM(pr.discount), 0) FROM promo_redemptions pr INNER JOIN sales s ON s.id = pr.sale_id
+		WHERE pr.promo_id = p.id AND s.status <> 'cancelled')`
+
+func scanPromo(row pgx.Row) (*managers.Promo, error) {
+	item := &managers.Promo{}
+	err := row.Scan(&item.ID, &item.Code, &item.Kind, &item.Value, &item.Min_basket, &item.Product_id,
+		&item.Category_id, &item.Starts, &item.Ends, &item.Max_uses, &item.Max_uses_per_customer, &item.Active,
+		&item.Created, &item.Uses, &item.Discount)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *Managers) CreatePromo(ctx context.Context, promo *managers.Promo) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO promos (code, kind, value, min_basket, product_id, category_id, starts, ends, max_uses,
+			max_uses_per_customer, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING id, created
+	`, promo.Code, promo.Kind, promo.Value, promo.Min_basket, promo.Product_id, promo.Category_id, promo.Starts,
+		promo.Ends, promo.Max_uses, promo.Max_uses_per_customer, promo.Active).Scan(&promo.ID, &promo.Created)
+	if err == pgx.ErrNoRows {
+		return managers.ErrPromoCodeUsed
+	}
+	return err
+}
+
+func (r *Managers) Promos(ctx context.Context) ([]*managers.Promo, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+promoColumns+` FROM promos p ORDER BY p.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Promo, 0)
+	for rows.Next() {
+		item, err := scanPromo(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) Promo(ctx context.Context, id int64) (*managers.Promo, error) {
+	return scanPromo(r.db.QueryRow(ctx, `SELECT `+promoColumns+` FROM promos p WHERE p.id = $1`, id))
+}
+
+func (r *Managers) LockPromo(ctx context.Context, code string) (*managers.Promo, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `SELECT id FROM promos WHERE code = $1 FOR UPDATE`, code).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.Promo(ctx, id)
+}
+
+func (r *Managers) SetPromoActive(ctx context.Context, id int64, active bool) error {
+	tag, err := r.db.Exec(ctx, `UPDATE promos SET active = $2 WHERE id = $1`, id, active)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return managers.ErrPromoNotFound
+	}
+	return nil
+}
+
+func (r *Managers) CustomerPromoUses(ctx context.Context, promoID int64, customerID int64) (int, error) {
+	var uses int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM promo_redemptions pr
+		INNER JOIN sales s ON s.id = pr.sale_id
+		WHERE pr.promo_id = $1 AND pr.customer_id = $2 AND s.status <> 'cancelled'
+	`, promoID, customerID).Scan(&uses)
+	return uses, err
+}
+
+func (r *Managers) CreatePromoRedemption(ctx context.Context, redemption *managers.PromoRedemption) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO promo_redemptions (promo_id, customer_id, sale_id, discount)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, (SELECT status FROM sales WHERE id = $3), created
+	`, redemption.Promo_id, redemption.Customer_id, redemption.Sale_id, redemption.Discount).Scan(&redemption.ID,
+		&redemption.Status, &redemption.Created)
+}
+
+func (r *Managers) PromoRedemptions(ctx context.Context, promoID int64) ([]*managers.PromoRedemption, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT pr.id, pr.promo_id, pr.customer_id, pr.sale_id, s.status, pr.discount, pr.created
+		FROM promo_redemptions pr
+		INNER JOIN sales s ON s.id = pr.sale_id
+		WHERE pr.promo_id = $1
+		ORDER BY pr.id DESC
+	`, promoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.PromoRedemption, 0)
+	for rows.Next() {
+		item := &managers.PromoRedemption{}
+		err = rows.Scan(&item.ID, &item.Promo_id, &item.Customer_id, &item.Sale_id, &item.Status, &item.Discount,
+			&item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/receipts"
+)
+
+func (r *Managers) SaleReceipt(ctx context.Context, id int64) (*receipts.Receipt, error) {
+	return saleReceipt(ctx, r.db, id)
+}
+
+func (r *Customers) SaleReceipt(ctx context.Context, id int64) (*receipts.Receipt, error) {
+	return saleReceipt(ctx, r.db, id)
+}
+
+func saleReceipt(ctx context.Context, db querier, id int64) (*receipts.Receipt, error) {
+	receipt := &receipts.Receipt{}
+	err := db.QueryRow(ctx, `
+		SELECT s.id, s.receipt_no, s.created, COALESCE(s.manager_id, 0), COALESCE(m.name, ''), s.customer_id,
+			COALESCE(c.name, '')
+		FROM sales s
+		LEFT JOIN managers m ON m.id = s.manager_id
+		LEFT JOIN customers c ON c.id = s.customer_id
+		WHERE s.id = $1
+	`, id).Scan(&receipt.Sale_id, &receipt.Number, &receipt.Created, &receipt.Manager_id, &receipt.Manager,
+		&receipt.Customer_id, &receipt.Customer)
+	if err == pgx.ErrNoRows {
+		return nil, receipts.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT product_id, name, qty, base_price, price FROM sales_positions WHERE sale_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipt.Lines = make([]*receipts.Line, 0)
+	for rows.Next() {
+		line := &receipts.Line{}
+		err = rows.Scan(&line.Product_id, &line.Name, &line.Qty, &line.Base_price, &line.Price)
+		if err != nil {
+			return nil, err
+		}
+		receipt.Lines = append(receipt.Lines, line)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) ManagerTeam(ctx context.Context, id int64) (*managers.Team, error) {
+	team := &managers.Team{}
+	err := r.db.QueryRow(ctx, `
+		SELECT boss_id, COALESCE(department, '') FROM managers WHERE id = $1
+	`, id).Scan(&team.Boss_id, &team.Department)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (r *Managers) SetManagerTeam(ctx context.Context, id int64, team *managers.Team) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE managers SET boss_id = $2, department = NULLIF($3, '') WHERE id = $1
+	`, id, team.Boss_id, team.Department)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return managers.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *Managers) Subordinates(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM managers WHERE id = $1
+			UNION
+			SELECT m.id FROM managers m INNER JOIN tree t ON m.boss_id = t.id
+		)
+		SELECT id FROM tree ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// SalesByManager attributes returns to the manager who made the sale, so
+// that net totals show what each manager's sales were finally worth.
+func (r *Managers) SalesByManager(ctx context.Context, period managers.Period) ([]*managers.ManagerSales, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT m.id, m.name, m.boss_id, COALESCE(m.department, ''),
+			COALESCE(s.count, 0), COALESCE(s.total, 0), COALESCE(rt.total, 0)
+		FROM managers m
+		LEFT JOIN (
+			SELECT s.manager_id, COUNT(DISTINCT s.id) AS count, SUM(sp.price * sp.qty) AS total
+			FROM sales s
+			INNER JOIN sales_positions sp ON sp.sale_id = s.id
+			WHERE `+countedSales+`
+				AND ($1::TIMESTAMP IS NULL OR s.created >= $1) AND ($2::TIMESTAMP IS NULL OR s.created <= $2)
+			GROUP BY s.manager_id
+		) s ON s.manager_id = m.id
+		LEFT JOIN (
+			SELECT s.manager_id, SUM(rt.refund) AS total
+			FROM returns rt
+			INNER JOIN sales s ON s.id = rt.sale_id
+			WHERE ($1::TIMESTAMP IS NULL OR rt.created >= $1) AND ($2::TIMESTAMP IS NULL OR rt.created <= $2)
+			GROUP BY s.manager_id
+		) rt ON rt.manager_id = m.id
+		ORDER BY m.id
+	`, period.From, period.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.ManagerSales, 0)
+	for rows.Next() {
+		item := &managers.ManagerSales{}
+		err = rows.Scan(&item.Manager_id, &item.Name, &item.Boss_id, &item.Department,
+			&item.Sales_count, &item.Sales_total, &item.Returns_total)
+		if err != nil {
+			return nil, err
+		}
+		item.Net_total = item.Sales_total - item.Returns_total
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// salesReportGroups holds the key, label, join and order of each grouping of
+// the sales report; local is replaced with the sale time in the report zone.
+var salesReportGroups = map[string]struct{ key, label, join, order string }{
+	managers.ReportByDay:      {key: `to_char(date_trunc('day', local), 'YYYY-MM-DD')`, order: `1`},
+	managers.ReportByWeek:     {key: `to_char(date_trunc('week', local), 'YYYY-MM-DD')`, order: `1`},
+	managers.ReportByMonth:    {key: `to_char(date_trunc('month', local), 'YYYY-MM-DD')`, order: `1`},
+	managers.ReportByProduct:  {key: `sp.product_id`, label: `p.name`, join: `INNER JOIN products p ON p.id = sp.product_id`, order: `sp.product_id`},
+	managers.ReportByCustomer: {key: `s.customer_id`, label: `COALESCE(c.name, '')`, join: `LEFT JOIN customers c ON c.id = s.customer_id`, order: `s.customer_id`},
+	managers.ReportByManager:  {key: `COALESCE(s.manager_id, 0)`, label: `COALESCE(m.name, '')`, join: `LEFT JOIN managers m ON m.id = s.manager_id`, order: `COALESCE(s.manager_id, 0)`},
+}
+
+func (r *Managers) SalesReport(ctx context.Context, query *managers.SalesReportQuery, fn func(row *managers.SalesReportRow) error) error {
+	group := salesReportGroups[query.Group_by]
+	args := []interface{}{query.Period.From, query.Period.To}
+	key := group.key
+	if strings.Contains(key, "local") {
+		key = strings.ReplaceAll(key, "local", `((s.created AT TIME ZONE 'UTC') AT TIME ZONE $3)`)
+		args = append(args, query.Location.String())
+	}
+	label := group.label
+	if label == "" {
+		label = key
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT `+key+`::TEXT, `+label+`, COUNT(DISTINCT s.id), SUM(sp.qty), SUM(sp.price * sp.qty)
+		FROM sales s
+		INNER JOIN sales_positions sp ON sp.sale_id = s.id
+		`+group.join+`
+		WHERE `+countedSales+`
+			AND ($1::TIMESTAMP IS NULL OR s.created >= $1) AND ($2::TIMESTAMP IS NULL OR s.created <= $2)
+		GROUP BY `+key+`, `+label+`
+		ORDER BY `+group.order, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := &managers.SalesReportRow{}
+		err = rows.Scan(&row.Key, &row.Label, &row.Sales_count, &row.Units, &row.Revenue)
+		if err != nil {
+			return err
+		}
+		err = fn(row)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
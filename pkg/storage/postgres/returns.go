@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) LockSale(ctx context.Context, id int64) (*managers.Sale, error) {
+	sale := &managers.Sale{}
+	err := r.db.QueryRow(ctx, `
+		SELECT id, COALESCE(manager_id, 0), customer_id, status, created FROM sales WHERE id = $1 FOR UPDATE
+	`, id).Scan(&sale.ID, &sale.Manager_id, &sale.Customer_id, &sale.Status, &sale.Created)
+	if err == pgx.ErrNoRows {
+		return nil, managers.ErrSaleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, product_id, name, qty, price, base_price, discount_percent, discount_amount, promo_discount, points_discount
+		FROM sales_positions WHERE sale_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		position := &managers.SalePosition{}
+		err = rows.Scan(&position.ID, &position.Product_id, &position.Name, &position.Qty, &position.Price,
+			&position.Base_price, &position.Discount_percent, &position.Discount_amount, &position.Promo_discount,
+			&position.Points_discount)
+		if err != nil {
+			return nil, err
+		}
+		sale.Positions = append(sale.Positions, position)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return sale, nil
+}
+
+func (r *Managers) ReturnedQty(ctx context.Context, saleID int64) (map[int64]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT rp.sale_position_id, SUM(rp.qty)
+		FROM returns_positions rp
+		INNER JOIN returns rt ON rt.id = rp.return_id
+		WHERE rt.sale_id = $1
+		GROUP BY rp.sale_position_id
+	`, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	returned := make(map[int64]int)
+	for rows.Next() {
+		var positionID int64
+		var qty int
+		err = rows.Scan(&positionID, &qty)
+		if err != nil {
+			return nil, err
+		}
+		returned[positionID] = qty
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return returned, nil
+}
+
+func (r *Managers) CreateReturn(ctx context.Context, ret *managers.Return) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO returns (sale_id, manager_id, reason, refund) VALUES ($1, $2, $3, $4)
+		RETURNING id, created
+	`, ret.Sale_id, ret.Manager_id, ret.Reason, ret.Refund).Scan(&ret.ID, &ret.Created)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, v := range ret.Positions {
+		batch.Queue(`
+			INSERT INTO returns_positions (return_id, sale_position_id, product_id, price, qty)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, ret.ID, v.Sale_position_id, v.Product_id, v.Price, v.Qty)
+	}
+	results := r.db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, v := range ret.Positions {
+		err = results.QueryRow().Scan(&v.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return results.Close()
+}
+
+func (r *Managers) Returns(ctx context.Context, saleID int64) ([]*managers.Return, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT rt.id, rt.sale_id, rt.manager_id, rt.reason, rt.refund, rt.created,
+			rp.id, rp.sale_position_id, rp.product_id, rp.qty, rp.price
+		FROM returns rt
+		INNER JOIN returns_positions rp ON rp.return_id = rt.id
+		WHERE $1 = 0 OR rt.sale_id = $1
+		ORDER BY rt.id, rp.id
+	`, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.Return, 0)
+	var last *managers.Return
+	for rows.Next() {
+		ret := &managers.Return{}
+		position := &managers.ReturnPosition{}
+		err = rows.Scan(&ret.ID, &ret.Sale_id, &ret.Manager_id, &ret.Reason, &ret.Refund, &ret.Created,
+			&position.ID, &position.Sale_position_id, &position.Product_id, &position.Qty, &position.Price)
+		if err != nil {
+			return nil, err
+		}
+		if last == nil || last.ID != ret.ID {
+			last = ret
+			items = append(items, ret)
+		}
+		last.Positions = append(last.Positions, position)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
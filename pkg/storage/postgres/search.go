@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/listing"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+// searchProducts runs the full-text search over products.search and calls
+// scan for every row of id, name, price, qty, active, created, rank, highlight.
+func searchProducts(ctx context.Context, db querier, search *listing.Search, scan func(rows pgx.Rows) error) error {
+	c := &conditions{}
+	c.add("search @@ to_tsquery('simple', ?)", search.TSQuery())
+	if search.Active != nil {
+		c.add("active = ?", *search.Active)
+	}
+	rows, err := db.Query(ctx, `
+		SELECT id, name, price, qty, active, created,
+			ts_rank(search, to_tsquery('simple', $1)),
+			ts_headline('simple', name, to_tsquery('simple', $1), 'StartSel=<b>, StopSel=</b>, HighlightAll=true')
+		FROM products`+c.where()+`
+		ORDER BY 7 DESC, id
+		LIMIT `+strconv.Itoa(search.Limit)+` OFFSET `+strconv.Itoa(search.Offset), c.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *Managers) SearchProducts(ctx context.Context, search *listing.Search) ([]*managers.ProductMatch, error) {
+	items := make([]*managers.ProductMatch, 0)
+	err := searchProducts(ctx, r.db, search, func(rows pgx.Rows) error {
+		item := &managers.ProductMatch{Product: &managers.Product{}}
+		err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Qty, &item.Active, &item.Created, &item.Rank, &item.Highlight)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Customers) SearchProducts(ctx context.Context, search *listing.Search) ([]*customers.ProductMatch, error) {
+	items := make([]*customers.ProductMatch, 0)
+	err := searchProducts(ctx, r.db, search, func(rows pgx.Rows) error {
+		item := &customers.ProductMatch{Product: &customers.Product{}}
+		err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Qty, &item.Active, &item.Created, &item.Rank, &item.Highlight)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) CreateStockMovement(ctx context.Context, movement *managers.StockMovement) error {
+	var managerID *int64
+	if movement.Manager_id != 0 {
+		managerID = &movement.Manager_id
+	}
+	return r.db.QueryRow(ctx, `
+		INSERT INTO stock_movements (product_id, manager_id, kind, qty, balance, reason, sale_id, return_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created
+	`, movement.Product_id, managerID, movement.Kind, movement.Qty, movement.Balance, movement.Reason,
+		movement.Sale_id, movement.Return_id).Scan(&movement.ID, &movement.Created)
+}
+
+func (r *Managers) StockMovements(ctx context.Context, productID int64) ([]*managers.StockMovement, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, managers.ErrProductNotFound
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, product_id, COALESCE(manager_id, 0), kind, qty, balance, reason, sale_id, return_id, created
+		FROM stock_movements WHERE product_id = $1 ORDER BY id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.StockMovement, 0)
+	for rows.Next() {
+		item := &managers.StockMovement{}
+		err = rows.Scan(&item.ID, &item.Product_id, &item.Manager_id, &item.Kind, &item.Qty, &item.Balance,
+			&item.Reason, &item.Sale_id, &item.Return_id, &item.Created)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *Managers) StockDiscrepancies(ctx context.Context) ([]*managers.StockDiscrepancy, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT p.id, p.name, p.qty, COALESCE(SUM(m.qty), 0)::INTEGER AS ledger_qty
+		FROM products p
+		LEFT JOIN stock_movements m ON m.product_id = p.id
+		GROUP BY p.id
+		HAVING p.qty <> COALESCE(SUM(m.qty), 0)
+		ORDER BY p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.StockDiscrepancy, 0)
+	for rows.Next() {
+		item := &managers.StockDiscrepancy{}
+		err = rows.Scan(&item.Product_id, &item.Name, &item.Qty, &item.Ledger_qty)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
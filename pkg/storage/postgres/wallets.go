@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/khiki1995/crud/pkg/customers"
+	"github.com/khiki1995/crud/pkg/managers"
+)
+
+func (r *Managers) LockWallet(ctx context.Context, customerID int64) (int, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)`, customerID).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, customers.ErrUserNotFound
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO wallets (customer_id) VALUES ($1) ON CONFLICT (customer_id) DO NOTHING
+	`, customerID)
+	if err != nil {
+		return 0, err
+	}
+	var balance int
+	err = r.db.QueryRow(ctx, `
+		SELECT balance FROM wallets WHERE customer_id = $1 FOR UPDATE
+	`, customerID).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+func (r *Managers) CreateWalletTransaction(ctx context.Context, transaction *managers.WalletTransaction) error {
+	var managerID *int64
+	if transaction.Manager_id != 0 {
+		managerID = &transaction.Manager_id
+	}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO wallet_transactions (customer_id, kind, amount, balance, sale_id, payment_id, manager_id, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created
+	`, transaction.Customer_id, transaction.Kind, transaction.Amount, transaction.Balance, transaction.Sale_id,
+		transaction.Payment_id, managerID, transaction.Comment).Scan(&transaction.ID, &transaction.Created)
+	if err != nil {
+		return err
+	}
+	for _, entry := range transaction.Entries {
+		entry.Transaction_id = transaction.ID
+		err = r.db.QueryRow(ctx, `
+			INSERT INTO wallet_entries (transaction_id, account, amount) VALUES ($1, $2, $3)
+			RETURNING id
+		`, transaction.ID, entry.Account, entry.Amount).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = r.db.Exec(ctx, `
+		UPDATE wallets SET balance = $2 WHERE customer_id = $1
+	`, transaction.Customer_id, transaction.Balance)
+	return err
+}
+
+func (r *Managers) WalletTransactions(ctx context.Context, customerID int64) ([]*managers.WalletTransaction, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)`, customerID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, customers.ErrUserNotFound
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT t.id, t.customer_id, t.kind, t.amount, t.balance, t.sale_id, t.payment_id,
+			COALESCE(t.manager_id, 0), t.comment, t.created, e.id, e.account, e.amount
+		FROM wallet_transactions t
+		INNER JOIN wallet_entries e ON e.transaction_id = t.id
+		WHERE t.customer_id = $1
+		ORDER BY t.id DESC, e.id
+	`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]*managers.WalletTransaction, 0)
+	for rows.Next() {
+		item := &managers.WalletTransaction{}
+		entry := &managers.WalletEntry{}
+		err = rows.Scan(&item.ID, &item.Customer_id, &item.Kind, &item.Amount, &item.Balance, &item.Sale_id,
+			&item.Payment_id, &item.Manager_id, &item.Comment, &item.Created, &entry.ID, &entry.Account, &entry.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 || items[len(items)-1].ID != item.ID {
+			items = append(items, item)
+		}
+		last := items[len(items)-1]
+		entry.Transaction_id = last.ID
+		last.Entries = append(last.Entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}